@@ -0,0 +1,48 @@
+// File: backend/cmd/auditverify/main.go
+
+// Command auditverify checks the hash chain of an audit log and reports
+// the first gap or edited entry it finds.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/audit"
+)
+
+func main() {
+	// Parse command line flags
+	path := flag.String("file", "data/audit.log", "path to audit log")
+	keyFile := flag.String("key-file", "", "path to the HMAC key the log was written with")
+	flag.Parse()
+
+	var key []byte
+	if *keyFile != "" {
+		var err error
+		if key, err = audit.ReadKey(*keyFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit log: %v\n", err)
+		os.Exit(2)
+	}
+	defer file.Close()
+
+	summary, err := audit.Verify(file, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit log %s is NOT intact: %v\n", *path, err)
+		fmt.Fprintf(os.Stderr, "%d entries verified before the failure\n", summary.Entries)
+		os.Exit(1)
+	}
+
+	// Print the chain head so it can be recorded elsewhere and compared
+	// later, which catches entries removed from the end
+	json.NewEncoder(os.Stdout).Encode(summary)
+}
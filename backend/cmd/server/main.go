@@ -6,7 +6,7 @@ import (
 	"context"
 	"encoding/json"
 	"flag"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,28 +14,67 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/himbojo/net-tools-gui/backend/internal/alert"
+	"github.com/himbojo/net-tools-gui/backend/internal/audit"
 	"github.com/himbojo/net-tools-gui/backend/internal/executor"
 	"github.com/himbojo/net-tools-gui/backend/internal/handlers"
+	"github.com/himbojo/net-tools-gui/backend/internal/history"
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/internal/jobs"
+	"github.com/himbojo/net-tools-gui/backend/internal/logger"
+	"github.com/himbojo/net-tools-gui/backend/internal/metrics"
 	"github.com/himbojo/net-tools-gui/backend/internal/middleware"
+	"github.com/himbojo/net-tools-gui/backend/internal/monitor"
+	"github.com/himbojo/net-tools-gui/backend/internal/policy"
+	"github.com/himbojo/net-tools-gui/backend/internal/server"
+	"github.com/himbojo/net-tools-gui/backend/internal/tracing"
 	"github.com/himbojo/net-tools-gui/backend/internal/validator"
+	"github.com/himbojo/net-tools-gui/backend/pkg/tools"
 )
 
 // Config holds server configuration
 type Config struct {
-	Port            string        `json:"port"`
-	ReadTimeout     time.Duration `json:"readTimeout"`
-	WriteTimeout    time.Duration `json:"writeTimeout"`
-	ShutdownTimeout time.Duration `json:"shutdownTimeout"`
-	AllowedOrigins  []string      `json:"allowedOrigins"`
+	Port            string          `json:"port"`
+	ReadTimeout     time.Duration   `json:"readTimeout"`
+	WriteTimeout    time.Duration   `json:"writeTimeout"`
+	ShutdownTimeout time.Duration   `json:"shutdownTimeout"`
+	AllowedOrigins  []string        `json:"allowedOrigins"`
+	Tools           tools.Config    `json:"tools"`
+	Jobs            jobs.Config     `json:"jobs"`
+	History         history.Config  `json:"history"`
+	Monitors        monitor.Config  `json:"monitors"`
+	Alerts          alert.Config    `json:"alerts"`
+	Tracing         tracing.Config  `json:"tracing"`
+	Logging         logger.Config   `json:"logging"`
+	Audit           audit.Config    `json:"audit"`
+	Identity        identity.Config `json:"identity"`
+	Policy          policy.Config   `json:"policy"`
+
+	// RateLimit applies to interactive requests, ScrapeRateLimit to
+	// Prometheus scrapes of /metrics and /probe
+	RateLimit       middleware.RateLimitConfig `json:"rateLimit"`
+	ScrapeRateLimit middleware.RateLimitConfig `json:"scrapeRateLimit"`
 }
 
 // Server represents the HTTP server and its dependencies
 type Server struct {
-	config     Config
-	httpServer *http.Server
-	executor   *executor.CommandExecutor
-	validator  *validator.Validator
-	wsHandler  *handlers.WSHandler
+	config          Config
+	httpServer      *http.Server
+	registry        *tools.Registry
+	executor        *executor.CommandExecutor
+	jobs            *jobs.Manager
+	history         *history.Store
+	monitors        *monitor.Manager
+	alerts          *alert.Engine
+	audit           *audit.Log
+	identity        *identity.Resolver
+	validator       *validator.Validator
+	wsHandler       *handlers.WSHandler
+	httpHandler     *handlers.HTTPHandler
+	monHandler      *handlers.MonitorHandler
+	alHandler       *handlers.AlertHandler
+	shutdownTracing func(context.Context) error
+	closeLog        func() error
 }
 
 func main() {
@@ -46,7 +85,7 @@ func main() {
 	// Load configuration
 	config, err := loadConfig(*configFile)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("failed to load configuration", err)
 	}
 
 	// Initialize components
@@ -64,6 +103,8 @@ func loadConfig(configFile string) (Config, error) {
 		WriteTimeout:    15 * time.Second,
 		ShutdownTimeout: 5 * time.Second,
 		AllowedOrigins:  []string{"http://localhost:3000"},
+		RateLimit:       middleware.RateLimitConfig{Limit: 10, Window: time.Minute},
+		ScrapeRateLimit: middleware.RateLimitConfig{Limit: 600, Window: time.Minute},
 	}
 
 	// Try to load from file
@@ -83,18 +124,86 @@ func loadConfig(configFile string) (Config, error) {
 	return config, nil
 }
 
+// fatal logs an error that prevents the server from running and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
 func newServer(config Config) *Server {
+	closeLog, err := logger.Setup(config.Logging)
+	if err != nil {
+		fatal("failed to set up logging", err)
+	}
+	shutdownTracing, err := tracing.Setup(config.Tracing, nil)
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+
 	// Initialize components
-	validator := validator.NewValidator()
-	executor := executor.NewExecutor()
-	wsHandler := handlers.NewWSHandler(executor, validator)
+	registry := tools.NewDefaultRegistry(config.Tools)
+	targetPolicy, err := policy.New(config.Policy, registry)
+	if err != nil {
+		fatal("failed to set up target policy", err)
+	}
+	validator := validator.NewValidator(registry, targetPolicy)
+	executor := executor.NewExecutor(registry, targetPolicy)
+	jobManager := jobs.NewManager(executor, config.Jobs)
+	resolver, err := identity.NewResolver(config.Identity)
+	if err != nil {
+		fatal("failed to set up identity", err)
+	}
+	auditLog, err := audit.Open(config.Audit)
+	if err != nil {
+		fatal("failed to open audit log", err)
+	}
+	jobManager.OnFinish(auditLog.ObserveJob)
+	historyStore, err := history.Open(config.History)
+	if err != nil {
+		fatal("failed to open history", err)
+	}
+	jobManager.OnFinish(historyStore.Record)
+	jobManager.OnFinish(metrics.ObserveJob)
+	metrics.SetTools(registry.Names())
+	metrics.Default.NewGaugeFunc("nettools_queue_depth", "Jobs waiting for an execution slot.", func() float64 {
+		return float64(jobManager.QueueLength())
+	})
+	metrics.Default.NewGaugeFunc("nettools_jobs_running", "Jobs currently executing.", func() float64 {
+		return float64(jobManager.Running())
+	})
+	monitors, err := monitor.Open(jobManager, config.Monitors)
+	if err != nil {
+		fatal("failed to open monitors", err)
+	}
+	alertEngine, err := alert.Open(config.Alerts)
+	if err != nil {
+		fatal("failed to open alerts", err)
+	}
+	monitors.OnResult(alertEngine.Evaluate)
+	monitors.Start()
+	wsHandler := handlers.NewWSHandler(jobManager, validator, auditLog)
+	httpHandler := handlers.NewHTTPHandler(registry, validator, jobManager, historyStore, auditLog)
+	monHandler := handlers.NewMonitorHandler(monitors, validator, auditLog)
+	alHandler := handlers.NewAlertHandler(alertEngine, monitors)
 
 	// Create server instance
 	server := &Server{
-		config:    config,
-		executor:  executor,
-		validator: validator,
-		wsHandler: wsHandler,
+		config:          config,
+		registry:        registry,
+		executor:        executor,
+		jobs:            jobManager,
+		history:         historyStore,
+		monitors:        monitors,
+		alerts:          alertEngine,
+		audit:           auditLog,
+		identity:        resolver,
+		validator:       validator,
+		wsHandler:       wsHandler,
+		httpHandler:     httpHandler,
+		monHandler:      monHandler,
+		alHandler:       alHandler,
+		shutdownTracing: shutdownTracing,
+		closeLog:        closeLog,
 	}
 
 	// Create router and set up routes
@@ -114,31 +223,69 @@ func newServer(config Config) *Server {
 
 func (s *Server) setupRoutes(mux *http.ServeMux) {
 	// Health check endpoint
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		json.NewEncoder(w).Encode(map[string]string{"status": "OK"})
-	})
+	mux.HandleFunc("/health", s.httpHandler.HandleHealth)
 
 	// WebSocket endpoint
 	mux.HandleFunc("/ws", s.wsHandler.HandleConnection)
 
+	// Prometheus metrics
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.HandleFunc("GET /probe", s.httpHandler.HandleProbe)
+
 	// API endpoints
-	mux.HandleFunc("/api/v1/tools", func(w http.ResponseWriter, r *http.Request) {
-		tools := []string{"ping", "dig", "traceroute"}
-		json.NewEncoder(w).Encode(map[string][]string{"tools": tools})
-	})
+	mux.HandleFunc("/api/v1/tools", s.httpHandler.HandleToolsList)
+	mux.HandleFunc("POST /api/v1/jobs", s.httpHandler.HandleCreateJob)
+	mux.HandleFunc("GET /api/v1/jobs/{id}", s.httpHandler.HandleGetJob)
+	mux.HandleFunc("DELETE /api/v1/jobs/{id}", s.httpHandler.HandleCancelJob)
+	mux.HandleFunc("GET /api/v1/history", s.httpHandler.HandleHistory)
+	mux.HandleFunc("GET /api/v1/history/{id}", s.httpHandler.HandleHistoryRecord)
+	mux.HandleFunc("GET /api/v1/monitors", s.monHandler.HandleList)
+	mux.HandleFunc("POST /api/v1/monitors", s.monHandler.HandleCreate)
+	mux.HandleFunc("GET /api/v1/monitors/{id}", s.monHandler.HandleGet)
+	mux.HandleFunc("PUT /api/v1/monitors/{id}", s.monHandler.HandleUpdate)
+	mux.HandleFunc("DELETE /api/v1/monitors/{id}", s.monHandler.HandleDelete)
+	mux.HandleFunc("POST /api/v1/monitors/{id}/pause", s.monHandler.HandlePause)
+	mux.HandleFunc("POST /api/v1/monitors/{id}/resume", s.monHandler.HandleResume)
+	mux.HandleFunc("GET /api/v1/monitors/{id}/results", s.monHandler.HandleResults)
+	mux.HandleFunc("GET /api/v1/alerts", s.alHandler.HandleAlerts)
+	mux.HandleFunc("GET /api/v1/alerts/rules", s.alHandler.HandleListRules)
+	mux.HandleFunc("POST /api/v1/alerts/rules", s.alHandler.HandleCreateRule)
+	mux.HandleFunc("GET /api/v1/alerts/rules/{id}", s.alHandler.HandleGetRule)
+	mux.HandleFunc("PUT /api/v1/alerts/rules/{id}", s.alHandler.HandleUpdateRule)
+	mux.HandleFunc("DELETE /api/v1/alerts/rules/{id}", s.alHandler.HandleDeleteRule)
 }
 
 func (s *Server) middlewareChain(handler http.Handler) http.Handler {
-	// Create rate limiter
-	rateLimiter := middleware.NewRateLimiter()
+	// Create rate limiters. Prometheus scrapes /metrics and /probe on a
+	// schedule, often for many targets at once, so they have their own
+	// allowance rather than the interactive one.
+	rateLimiter := middleware.NewRateLimiter(s.config.RateLimit)
+	rateLimiter.OnReject(s.audit.RateLimited)
+	scrapeLimiter := middleware.NewRateLimiter(s.config.ScrapeRateLimit)
+	scrapeLimiter.OnReject(s.audit.RateLimited)
+	limited := rateLimiter.Limit(handler)
+	scraped := scrapeLimiter.Limit(handler)
+	limit := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics" || r.URL.Path == "/probe" {
+			scraped.ServeHTTP(w, r)
+			return
+		}
+		limited.ServeHTTP(w, r)
+	})
 
 	// Create security middleware
 	security := middleware.NewSecurityMiddleware(s.config.AllowedOrigins)
 
 	// Chain middleware
-	return security.Secure(
-		rateLimiter.Limit(
-			handler,
+	return s.identity.Middleware(
+		server.LoggingMiddleware(
+			server.TracingMiddleware(
+				server.MetricsMiddleware(
+					security.Secure(
+						limit,
+					),
+				),
+			),
 		),
 	)
 }
@@ -149,9 +296,20 @@ func (s *Server) start() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		log.Printf("Server listening on port %s", s.config.Port)
+		slog.Info("server listening", "port", s.config.Port)
 		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server error: %v", err)
+			fatal("server error", err)
+		}
+	}()
+
+	// Reopen the log file when an external rotator asks
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := logger.Reopen(); err != nil {
+				slog.Error("failed to reopen log file", "error", err)
+			}
 		}
 	}()
 
@@ -161,15 +319,32 @@ func (s *Server) start() {
 	<-stop
 
 	// Graceful shutdown
-	log.Printf("Shutting down server...")
+	slog.Info("shutting down server")
 	ctx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
 	defer cancel()
 
 	if err := s.httpServer.Shutdown(ctx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
+		slog.Error("server shutdown failed", "error", err)
 	}
 
 	// Wait for server goroutine to finish
 	wg.Wait()
-	log.Printf("Server shutdown complete")
+
+	if err := s.monitors.Close(); err != nil {
+		slog.Error("failed to close monitors", "error", err)
+	}
+	if err := s.alerts.Close(); err != nil {
+		slog.Error("failed to close alerts", "error", err)
+	}
+	if err := s.history.Close(); err != nil {
+		slog.Error("failed to close history", "error", err)
+	}
+	if err := s.audit.Close(); err != nil {
+		slog.Error("failed to close audit log", "error", err)
+	}
+	if err := s.shutdownTracing(ctx); err != nil {
+		slog.Error("failed to shut down tracing", "error", err)
+	}
+	slog.Info("server shutdown complete")
+	s.closeLog()
 }
@@ -0,0 +1,464 @@
+// File: backend/internal/alert/alert.go
+
+package alert
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/errors"
+	"github.com/himbojo/net-tools-gui/backend/internal/monitor"
+	bolt "go.etcd.io/bbolt"
+)
+
+// rulesBucket holds rules keyed by ID
+var rulesBucket = []byte("rules")
+
+// Config holds alerting settings. Zero values select the defaults.
+type Config struct {
+	Path      string          `json:"path"`
+	Channels  []ChannelConfig `json:"channels"`
+	Throttle  time.Duration   `json:"throttle"`
+	Timeout   time.Duration   `json:"timeout"`
+	Retries   int             `json:"retries"`
+	QueueSize int             `json:"queueSize"`
+}
+
+// withDefaults fills in unset settings
+func (c Config) withDefaults() Config {
+	if c.Path == "" {
+		c.Path = "data/alerts.db"
+	}
+	if c.Throttle <= 0 {
+		c.Throttle = 5 * time.Minute
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.Retries <= 0 {
+		c.Retries = 3
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 100
+	}
+	return c
+}
+
+// StatePending is the state of an alert whose condition holds but has
+// not yet held for the rule's For runs
+const StatePending = "pending"
+
+// Alert is the state of one rule for one monitor
+type Alert struct {
+	RuleID      string    `json:"ruleId"`
+	RuleName    string    `json:"ruleName"`
+	MonitorID   string    `json:"monitorId"`
+	MonitorName string    `json:"monitorName"`
+	State       string    `json:"state"`
+	Value       string    `json:"value"`
+	Count       int       `json:"count"`
+	Since       time.Time `json:"since"`
+	LastResult  time.Time `json:"lastResult"`
+
+	firing     bool
+	prev       string
+	hasPrev    bool
+	notified   string
+	notifiedAt time.Time
+}
+
+// delivery is a notification queued for a channel
+type delivery struct {
+	channel string
+	n       Notification
+}
+
+// Engine evaluates alert rules against monitor results and delivers
+// notifications when alerts fire or resolve. Notifications are only sent
+// on state changes, or every RepeatInterval while firing. Reminders are
+// never sent more often than the configured throttle for the same alert,
+// while state changes always go out. Alert state is
+// kept in memory and rebuilt from new results after a restart.
+type Engine struct {
+	db        *bolt.DB
+	config    Config
+	notifiers map[string]notifier
+	queue     chan delivery
+	wg        sync.WaitGroup
+
+	mu     sync.Mutex
+	rules  map[string]Rule
+	alerts map[string]*Alert
+	closed bool
+}
+
+// Open loads the stored rules and starts the notification worker
+func Open(config Config) (*Engine, error) {
+	config = config.withDefaults()
+
+	notifiers := make(map[string]notifier)
+	for _, c := range config.Channels {
+		if _, ok := notifiers[c.Name]; ok || c.Name == "" {
+			return nil, fmt.Errorf("channel names must be unique and non-empty: %q", c.Name)
+		}
+		n, err := newNotifier(c)
+		if err != nil {
+			return nil, err
+		}
+		notifiers[c.Name] = n
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create alert directory: %v", err)
+	}
+	db, err := bolt.Open(config.Path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open alert database: %v", err)
+	}
+
+	rules := make(map[string]Rule)
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(rulesBucket)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var r Rule
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("rule %s: %v", k, err)
+			}
+			rules[r.ID] = r
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to load alert rules: %v", err)
+	}
+
+	e := &Engine{
+		db:        db,
+		config:    config,
+		notifiers: notifiers,
+		queue:     make(chan delivery, config.QueueSize),
+		rules:     rules,
+		alerts:    make(map[string]*Alert),
+	}
+	e.wg.Add(1)
+	go e.deliverLoop()
+	return e, nil
+}
+
+// Close sends any queued notifications and closes the database
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	e.closed = true
+	close(e.queue)
+	e.mu.Unlock()
+
+	e.wg.Wait()
+	return e.db.Close()
+}
+
+// Channels returns the names of the configured channels
+func (e *Engine) Channels() []string {
+	names := make([]string, 0, len(e.notifiers))
+	for name := range e.notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Rules returns all rules, oldest first
+func (e *Engine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rules := make([]Rule, 0, len(e.rules))
+	for _, r := range e.rules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+	return rules
+}
+
+// Rule returns the rule with the given ID
+func (e *Engine) Rule(id string) (Rule, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r, ok := e.rules[id]
+	return r, ok
+}
+
+// CreateRule validates and stores a new rule
+func (e *Engine) CreateRule(r Rule) (Rule, error) {
+	if err := e.check(&r); err != nil {
+		return Rule{}, err
+	}
+
+	now := time.Now()
+	r.ID = newRuleID()
+	r.CreatedAt = now
+	r.UpdatedAt = now
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.put(r); err != nil {
+		return Rule{}, err
+	}
+	e.rules[r.ID] = r
+	return r, nil
+}
+
+// UpdateRule replaces a rule, keeping its owner and resetting the state
+// of its alerts
+func (e *Engine) UpdateRule(id string, r Rule) (Rule, error) {
+	if err := e.check(&r); err != nil {
+		return Rule{}, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	old, ok := e.rules[id]
+	if !ok {
+		return Rule{}, errors.NewNotFoundError("rule not found")
+	}
+	r.ID = id
+	r.Owner = old.Owner
+	r.CreatedAt = old.CreatedAt
+	r.UpdatedAt = time.Now()
+	if err := e.put(r); err != nil {
+		return Rule{}, err
+	}
+	e.rules[id] = r
+	e.clearAlerts(id)
+	return r, nil
+}
+
+// DeleteRule removes a rule and its alerts
+func (e *Engine) DeleteRule(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.rules[id]; !ok {
+		return errors.NewNotFoundError("rule not found")
+	}
+	err := e.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(rulesBucket).Delete([]byte(id))
+	})
+	if err != nil {
+		return errors.NewInternalError(fmt.Errorf("failed to delete rule: %v", err))
+	}
+	delete(e.rules, id)
+	e.clearAlerts(id)
+	return nil
+}
+
+// Alerts returns the alerts that are pending or firing
+func (e *Engine) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := []Alert{}
+	for _, a := range e.alerts {
+		if a.State != StateResolved {
+			alerts = append(alerts, *a)
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Since.Before(alerts[j].Since)
+	})
+	return alerts
+}
+
+// Evaluate updates the alerts for a monitor result. It is registered as
+// a monitor.Manager observer.
+func (e *Engine) Evaluate(mon monitor.Monitor, result monitor.Result) {
+	if result.Status == monitor.StatusSkipped {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	for _, r := range e.rules {
+		if !r.appliesTo(mon.ID) {
+			continue
+		}
+		value, ok := r.observe(result)
+		if !ok {
+			continue
+		}
+
+		key := r.ID + "/" + mon.ID
+		a, ok := e.alerts[key]
+		if !ok {
+			a = &Alert{RuleID: r.ID, MonitorID: mon.ID, State: StateResolved}
+			e.alerts[key] = a
+		}
+		a.RuleName = r.Name
+		a.MonitorName = mon.Name
+
+		matched := r.matches(value, a.prev, a.hasPrev)
+		a.prev, a.hasPrev = value, true
+		a.Value = value
+		a.LastResult = result.Time
+
+		if matched {
+			if a.Count == 0 {
+				a.Since = result.Time
+			}
+			a.Count++
+			a.firing = a.firing || a.Count >= r.For
+		} else {
+			if a.firing || a.Count > 0 {
+				a.Since = result.Time
+			}
+			a.Count = 0
+			a.firing = false
+		}
+
+		switch {
+		case a.firing:
+			a.State = StateFiring
+		case a.Count > 0:
+			a.State = StatePending
+		default:
+			a.State = StateResolved
+		}
+		e.notify(r, mon, a, now)
+	}
+}
+
+// notify queues a notification for an alert if its state differs from
+// the one last sent, or a firing alert is due a reminder that is not
+// being throttled. The caller must hold e.mu.
+func (e *Engine) notify(r Rule, mon monitor.Monitor, a *Alert, now time.Time) {
+	state := StateResolved
+	if a.firing {
+		state = StateFiring
+	}
+
+	// A resolution is only news if the firing notification went out
+	if state == StateResolved && a.notified != StateFiring {
+		return
+	}
+	repeat := time.Duration(r.RepeatInterval) * time.Second
+	since := now.Sub(a.notifiedAt)
+	due := state != a.notified ||
+		(state == StateFiring && repeat > 0 && since >= repeat && since >= e.config.Throttle)
+	if !due || e.closed {
+		return
+	}
+
+	n := Notification{
+		Status:      state,
+		RuleID:      r.ID,
+		RuleName:    r.Name,
+		Condition:   r.Condition(),
+		MonitorID:   mon.ID,
+		MonitorName: mon.Name,
+		Tool:        mon.Request.Tool,
+		Target:      mon.Request.Target,
+		Value:       a.Value,
+		Since:       a.Since,
+		Time:        now,
+	}
+	for _, channel := range r.Channels {
+		select {
+		case e.queue <- delivery{channel: channel, n: n}:
+		default:
+			slog.Warn("alert queue full, dropping notification", "state", state, "rule_id", r.ID, "channel", channel)
+		}
+	}
+	a.notified = state
+	a.notifiedAt = now
+}
+
+// deliverLoop sends queued notifications until Close, retrying failed
+// deliveries with a growing delay
+func (e *Engine) deliverLoop() {
+	defer e.wg.Done()
+
+	for d := range e.queue {
+		n := e.notifiers[d.channel]
+		if n == nil {
+			slog.Warn("alert channel is not configured", "channel", d.channel)
+			continue
+		}
+
+		var err error
+		for attempt := 0; attempt < e.config.Retries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt) * time.Second)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), e.config.Timeout)
+			err = n.notify(ctx, d.n)
+			cancel()
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			slog.Error("failed to deliver alert", "rule_id", d.n.RuleID, "channel", d.channel, "error", err)
+		}
+	}
+}
+
+// check validates a rule and its channels
+func (e *Engine) check(r *Rule) error {
+	if err := r.validate(); err != nil {
+		return errors.NewValidationError(err.Error())
+	}
+	for _, channel := range r.Channels {
+		if _, ok := e.notifiers[channel]; !ok {
+			return errors.NewValidationError(fmt.Sprintf("unknown channel: %s", channel))
+		}
+	}
+	return nil
+}
+
+// put stores a rule. The caller must hold e.mu.
+func (e *Engine) put(r Rule) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return errors.NewInternalError(err)
+	}
+	err = e.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(rulesBucket).Put([]byte(r.ID), data)
+	})
+	if err != nil {
+		return errors.NewInternalError(fmt.Errorf("failed to store rule: %v", err))
+	}
+	return nil
+}
+
+// clearAlerts drops the alerts of a rule. The caller must hold e.mu.
+func (e *Engine) clearAlerts(ruleID string) {
+	for key, a := range e.alerts {
+		if a.RuleID == ruleID {
+			delete(e.alerts, key)
+		}
+	}
+}
+
+// newRuleID returns a random identifier for a rule
+func newRuleID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
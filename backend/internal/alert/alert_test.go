@@ -0,0 +1,118 @@
+// File: backend/internal/alert/alert_test.go
+
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/executor"
+	"github.com/himbojo/net-tools-gui/backend/internal/monitor"
+)
+
+// openTestEngine opens an engine with one webhook channel named "hook"
+// and returns the notifications it delivers
+func openTestEngine(t *testing.T, throttle time.Duration) (*Engine, <-chan Notification) {
+	s, requests := newWebhookServer(t, http.StatusOK)
+	e, err := Open(Config{
+		Path:     filepath.Join(t.TempDir(), "alerts.db"),
+		Channels: []ChannelConfig{{Name: "hook", Type: ChannelWebhook, URL: s.URL}},
+		Throttle: throttle,
+	})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { e.Close() })
+
+	notifications := make(chan Notification, 10)
+	go func() {
+		for req := range requests {
+			var n Notification
+			if err := json.Unmarshal(req.body, &n); err != nil {
+				t.Errorf("bad notification: %v", err)
+				continue
+			}
+			notifications <- n
+		}
+	}()
+	return e, notifications
+}
+
+// nextNotification waits for a delivery
+func nextNotification(t *testing.T, notifications <-chan Notification) Notification {
+	t.Helper()
+	select {
+	case n := <-notifications:
+		return n
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification delivered")
+		return Notification{}
+	}
+}
+
+func TestEngineResolvesWithinThrottle(t *testing.T) {
+	e, notifications := openTestEngine(t, time.Hour)
+	rule, err := e.CreateRule(Rule{Name: "packet loss", Metric: "loss", Op: OpGreater, Value: "10", Channels: []string{"hook"}})
+	if err != nil {
+		t.Fatalf("CreateRule: %v", err)
+	}
+	mon := monitor.Monitor{ID: "m1", Name: "gateway", Request: executor.CommandRequest{Tool: "ping", Target: "192.0.2.1"}}
+
+	result := func(loss float64) monitor.Result {
+		return monitor.Result{MonitorID: mon.ID, Time: time.Now(), Status: "completed", Values: map[string]float64{"loss": loss}}
+	}
+
+	e.Evaluate(mon, result(50))
+	n := nextNotification(t, notifications)
+	if n.Status != StateFiring || n.RuleID != rule.ID || n.Value != "50" || n.Target != "192.0.2.1" {
+		t.Errorf("first notification = %+v, want loss 50 firing", n)
+	}
+
+	// Still firing: no reminder without a repeat interval
+	e.Evaluate(mon, result(60))
+
+	// The resolution must not wait for the hour-long throttle
+	e.Evaluate(mon, result(0))
+	n = nextNotification(t, notifications)
+	if n.Status != StateResolved || n.Value != "0" {
+		t.Errorf("second notification = %+v, want a resolution at loss 0", n)
+	}
+
+	select {
+	case n := <-notifications:
+		t.Errorf("unexpected notification %+v", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		ok   bool
+	}{
+		{"numeric threshold", Rule{Metric: "loss", Op: OpGreater, Value: "10", Channels: []string{"hook"}}, true},
+		{"status change", Rule{Metric: MetricStatus, Op: OpChanged, Channels: []string{"hook"}}, true},
+		{"missing metric", Rule{Op: OpGreater, Value: "10", Channels: []string{"hook"}}, false},
+		{"non-numeric threshold", Rule{Metric: "loss", Op: OpGreater, Value: "high", Channels: []string{"hook"}}, false},
+		{"no channels", Rule{Metric: "loss", Op: OpGreater, Value: "10"}, false},
+		{"for out of range", Rule{Metric: "loss", Op: OpGreater, Value: "10", For: maxFor + 1, Channels: []string{"hook"}}, false},
+		{"header injection in name", Rule{Name: "loss\r\nBcc: victim@example.com", Metric: "loss", Op: OpGreater, Value: "10", Channels: []string{"hook"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.rule
+			err := r.validate()
+			if (err == nil) != tt.ok {
+				t.Fatalf("validate() error = %v, want ok = %v", err, tt.ok)
+			}
+			if tt.ok && (r.For != 1 || r.Name == "") {
+				t.Errorf("defaults not applied: For = %d, Name = %q", r.For, r.Name)
+			}
+		})
+	}
+}
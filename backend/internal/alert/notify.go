@@ -0,0 +1,228 @@
+// File: backend/internal/alert/notify.go
+
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Channel types
+const (
+	ChannelWebhook = "webhook"
+	ChannelSlack   = "slack"
+	ChannelEmail   = "email"
+)
+
+// Notification states
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// ChannelConfig configures a notification channel. Webhook and Slack
+// channels post to URL; email channels send through an SMTP server.
+type ChannelConfig struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	URL      string            `json:"url,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Host     string            `json:"host,omitempty"`
+	Port     int               `json:"port,omitempty"`
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+	From     string            `json:"from,omitempty"`
+	To       []string          `json:"to,omitempty"`
+}
+
+// Notification is sent when an alert starts firing or resolves
+type Notification struct {
+	Status      string    `json:"status"`
+	RuleID      string    `json:"ruleId"`
+	RuleName    string    `json:"ruleName"`
+	Condition   string    `json:"condition"`
+	MonitorID   string    `json:"monitorId"`
+	MonitorName string    `json:"monitorName"`
+	Tool        string    `json:"tool"`
+	Target      string    `json:"target"`
+	Value       string    `json:"value"`
+	Since       time.Time `json:"since"`
+	Time        time.Time `json:"time"`
+}
+
+// summary returns a one-line description of the notification
+func (n Notification) summary() string {
+	return fmt.Sprintf("[%s] %s: %s (%s %s) value %s",
+		strings.ToUpper(n.Status), n.RuleName, n.MonitorName, n.Tool, n.Target, n.Value)
+}
+
+// notifier delivers notifications to one channel
+type notifier interface {
+	notify(ctx context.Context, n Notification) error
+}
+
+// newNotifier creates the notifier for a channel
+func newNotifier(c ChannelConfig) (notifier, error) {
+	switch c.Type {
+	case ChannelWebhook, ChannelSlack:
+		if c.URL == "" {
+			return nil, fmt.Errorf("channel %s: url is required", c.Name)
+		}
+		return &webhookNotifier{config: c, client: &http.Client{}}, nil
+	case ChannelEmail:
+		if c.Host == "" || c.From == "" || len(c.To) == 0 {
+			return nil, fmt.Errorf("channel %s: host, from and to are required", c.Name)
+		}
+		if c.Port == 0 {
+			c.Port = 25
+		}
+		return &emailNotifier{config: c}, nil
+	default:
+		return nil, fmt.Errorf("channel %s: unsupported type %q", c.Name, c.Type)
+	}
+}
+
+// webhookNotifier posts notifications as JSON, either as-is or in the
+// Slack incoming webhook format
+type webhookNotifier struct {
+	config ChannelConfig
+	client *http.Client
+}
+
+// notify implements notifier
+func (w *webhookNotifier) notify(ctx context.Context, n Notification) error {
+	var payload interface{} = n
+	if w.config.Type == ChannelSlack {
+		payload = slackPayload(n)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// slackPayload formats a notification as a Slack message
+func slackPayload(n Notification) map[string]interface{} {
+	color := "danger"
+	if n.Status == StateResolved {
+		color = "good"
+	}
+	return map[string]interface{}{
+		"text": n.summary(),
+		"attachments": []map[string]interface{}{{
+			"color": color,
+			"fields": []map[string]interface{}{
+				{"title": "Monitor", "value": n.MonitorName, "short": true},
+				{"title": "Target", "value": n.Tool + " " + n.Target, "short": true},
+				{"title": "Condition", "value": n.Condition, "short": true},
+				{"title": "Value", "value": n.Value, "short": true},
+			},
+			"ts": n.Time.Unix(),
+		}},
+	}
+}
+
+// emailNotifier sends notifications through an SMTP server, upgrading to
+// TLS when the server offers STARTTLS
+type emailNotifier struct {
+	config ChannelConfig
+}
+
+// notify implements notifier
+func (e *emailNotifier) notify(ctx context.Context, n Notification) error {
+	c := e.config
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: c.Host}); err != nil {
+			return err
+		}
+	}
+	if c.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, c.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(c.From); err != nil {
+		return err
+	}
+	for _, to := range c.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(emailMessage(c, n)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// emailMessage formats a notification as a plain text email
+func emailMessage(c ChannelConfig, n Notification) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", c.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.summary()))
+	fmt.Fprintf(&b, "Date: %s\r\n", n.Time.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "Status: %s\r\n", n.Status)
+	fmt.Fprintf(&b, "Rule: %s\r\n", n.RuleName)
+	fmt.Fprintf(&b, "Condition: %s\r\n", n.Condition)
+	fmt.Fprintf(&b, "Monitor: %s (%s %s)\r\n", n.MonitorName, n.Tool, n.Target)
+	fmt.Fprintf(&b, "Value: %s\r\n", n.Value)
+	fmt.Fprintf(&b, "Since: %s\r\n", n.Since.Format(time.RFC3339))
+	return []byte(b.String())
+}
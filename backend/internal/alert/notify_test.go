@@ -0,0 +1,315 @@
+// File: backend/internal/alert/notify_test.go
+
+package alert
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNotification is a firing alert for a ping monitor
+var testNotification = Notification{
+	Status:      StateFiring,
+	RuleID:      "r1",
+	RuleName:    "packet loss",
+	Condition:   "loss > 10",
+	MonitorID:   "m1",
+	MonitorName: "gateway",
+	Tool:        "ping",
+	Target:      "192.0.2.1",
+	Value:       "25",
+	Since:       time.Date(2024, 10, 14, 10, 0, 0, 0, time.UTC),
+	Time:        time.Date(2024, 10, 14, 10, 5, 0, 0, time.UTC),
+}
+
+// capturedRequest is a request received by a webhook stand-in
+type capturedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newWebhookServer answers every request with status and passes what
+// it received to the returned channel
+func newWebhookServer(t *testing.T, status int) (*httptest.Server, <-chan capturedRequest) {
+	requests := make(chan capturedRequest, 10)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- capturedRequest{header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s, requests
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	s, requests := newWebhookServer(t, http.StatusNoContent)
+	n, err := newNotifier(ChannelConfig{
+		Name:    "hook",
+		Type:    ChannelWebhook,
+		URL:     s.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	if err != nil {
+		t.Fatalf("newNotifier: %v", err)
+	}
+
+	if err := n.notify(context.Background(), testNotification); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	req := <-requests
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := req.header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q, want the configured header", got)
+	}
+	var got Notification
+	if err := json.Unmarshal(req.body, &got); err != nil {
+		t.Fatalf("body is not a notification: %v", err)
+	}
+	if got != testNotification {
+		t.Errorf("body = %+v, want %+v", got, testNotification)
+	}
+}
+
+func TestWebhookNotifierError(t *testing.T) {
+	s, _ := newWebhookServer(t, http.StatusBadGateway)
+	n, err := newNotifier(ChannelConfig{Name: "hook", Type: ChannelWebhook, URL: s.URL})
+	if err != nil {
+		t.Fatalf("newNotifier: %v", err)
+	}
+	if err := n.notify(context.Background(), testNotification); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("notify error = %v, want the 502 status", err)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	s, requests := newWebhookServer(t, http.StatusOK)
+	n, err := newNotifier(ChannelConfig{Name: "slack", Type: ChannelSlack, URL: s.URL})
+	if err != nil {
+		t.Fatalf("newNotifier: %v", err)
+	}
+
+	for _, tt := range []struct {
+		status string
+		color  string
+	}{
+		{StateFiring, "danger"},
+		{StateResolved, "good"},
+	} {
+		n2 := testNotification
+		n2.Status = tt.status
+		if err := n.notify(context.Background(), n2); err != nil {
+			t.Fatalf("notify: %v", err)
+		}
+
+		var payload struct {
+			Text        string `json:"text"`
+			Attachments []struct {
+				Color  string `json:"color"`
+				Fields []struct {
+					Title string `json:"title"`
+					Value string `json:"value"`
+				} `json:"fields"`
+				TS int64 `json:"ts"`
+			} `json:"attachments"`
+		}
+		if err := json.Unmarshal((<-requests).body, &payload); err != nil {
+			t.Fatalf("body is not a Slack message: %v", err)
+		}
+		if want := n2.summary(); payload.Text != want {
+			t.Errorf("text = %q, want %q", payload.Text, want)
+		}
+		if len(payload.Attachments) != 1 {
+			t.Fatalf("got %d attachments, want 1", len(payload.Attachments))
+		}
+		a := payload.Attachments[0]
+		if a.Color != tt.color {
+			t.Errorf("%s color = %q, want %q", tt.status, a.Color, tt.color)
+		}
+		if a.TS != n2.Time.Unix() {
+			t.Errorf("ts = %d, want %d", a.TS, n2.Time.Unix())
+		}
+		if len(a.Fields) != 4 || a.Fields[1].Value != "ping 192.0.2.1" {
+			t.Errorf("fields = %+v, want monitor, target, condition and value", a.Fields)
+		}
+	}
+}
+
+// smtpMessage is a message received by the SMTP stand-in
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// newSMTPServer starts a minimal SMTP server that accepts one message
+// per connection and passes it to the returned channel
+func newSMTPServer(t *testing.T) (string, int, <-chan smtpMessage) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	messages := make(chan smtpMessage, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, messages)
+		}
+	}()
+
+	addr := l.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, messages
+}
+
+// serveSMTP speaks just enough SMTP for net/smtp's client
+func serveSMTP(conn net.Conn, messages chan<- smtpMessage) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+
+	var msg smtpMessage
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			reply("250 OK")
+		case "RCPT":
+			msg.to = append(msg.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.data = data.String()
+			messages <- msg
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	host, port, messages := newSMTPServer(t)
+	n, err := newNotifier(ChannelConfig{
+		Name: "mail",
+		Type: ChannelEmail,
+		Host: host,
+		Port: port,
+		From: "alerts@example.com",
+		To:   []string{"ops@example.com", "oncall@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("newNotifier: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.notify(ctx, testNotification); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+
+	msg := <-messages
+	if msg.from != "alerts@example.com" {
+		t.Errorf("MAIL FROM = %q, want alerts@example.com", msg.from)
+	}
+	if strings.Join(msg.to, ",") != "ops@example.com,oncall@example.com" {
+		t.Errorf("RCPT TO = %v, want both recipients", msg.to)
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(msg.data))
+	if err != nil {
+		t.Fatalf("message does not parse: %v", err)
+	}
+	if got, want := m.Header.Get("Subject"), testNotification.summary(); got != want {
+		t.Errorf("Subject = %q, want %q", got, want)
+	}
+	if got := m.Header.Get("To"); got != "ops@example.com, oncall@example.com" {
+		t.Errorf("To = %q", got)
+	}
+	body, _ := io.ReadAll(m.Body)
+	for _, want := range []string{"Status: firing", "Condition: loss > 10", "Monitor: gateway (ping 192.0.2.1)", "Value: 25"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("body lacks %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestEmailMessageSubjectEncoding(t *testing.T) {
+	n := testNotification
+	n.MonitorName = "gateway\r\nBcc: victim@example.com"
+	n.RuleName = "Paketverlust über 10%"
+
+	m, err := mail.ReadMessage(strings.NewReader(string(emailMessage(ChannelConfig{From: "a@example.com", To: []string{"b@example.com"}}, n))))
+	if err != nil {
+		t.Fatalf("message does not parse: %v", err)
+	}
+	if bcc := m.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("name injected a Bcc header: %q", bcc)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decode subject: %v", err)
+	}
+	if subject != n.summary() {
+		t.Errorf("decoded subject = %q, want %q", subject, n.summary())
+	}
+}
+
+func TestNewNotifierValidation(t *testing.T) {
+	for _, c := range []ChannelConfig{
+		{Name: "hook", Type: ChannelWebhook},
+		{Name: "mail", Type: ChannelEmail, Host: "smtp.example.com", From: "a@example.com"},
+		{Name: "pager", Type: "pager"},
+	} {
+		if _, err := newNotifier(c); err == nil {
+			t.Errorf("newNotifier(%+v) succeeded, want an error", c)
+		}
+	}
+
+	n, err := newNotifier(ChannelConfig{Name: "mail", Type: ChannelEmail, Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}})
+	if err != nil {
+		t.Fatalf("newNotifier: %v", err)
+	}
+	if port := n.(*emailNotifier).config.Port; port != 25 {
+		t.Errorf("default port = %d, want 25", port)
+	}
+}
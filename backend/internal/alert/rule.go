@@ -0,0 +1,178 @@
+// File: backend/internal/alert/rule.go
+
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/internal/monitor"
+)
+
+// Comparison operators
+const (
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpEqual        = "=="
+	OpNotEqual     = "!="
+	OpChanged      = "changed"
+)
+
+// MetricStatus selects a result's status rather than one of its values
+// or labels
+const MetricStatus = "status"
+
+// maxFor bounds the number of consecutive runs a rule may wait for
+const maxFor = 100
+
+// Rule raises an alert when a monitor's results match a condition for
+// For consecutive runs. Metric names a result value (such as loss or
+// days_to_expiry), a label (such as rcode or path) or the run status.
+// A rule without a MonitorID watches every monitor. Owner is the user,
+// or client address, that created the rule.
+type Rule struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	MonitorID      string    `json:"monitorId,omitempty"`
+	Metric         string    `json:"metric"`
+	Op             string    `json:"op"`
+	Value          string    `json:"value,omitempty"`
+	For            int       `json:"for,omitempty"`
+	Channels       []string  `json:"channels"`
+	RepeatInterval int       `json:"repeatInterval,omitempty"`
+	Owner          string    `json:"owner"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// validate checks a rule's condition and fills in defaults
+func (r *Rule) validate() error {
+	if r.Metric == "" {
+		return fmt.Errorf("metric is required")
+	}
+
+	switch r.Op {
+	case OpGreater, OpGreaterEqual, OpLess, OpLessEqual:
+		if _, err := strconv.ParseFloat(r.Value, 64); err != nil {
+			return fmt.Errorf("operator %s requires a numeric value", r.Op)
+		}
+	case OpEqual, OpNotEqual:
+		if r.Value == "" {
+			return fmt.Errorf("operator %s requires a value", r.Op)
+		}
+	case OpChanged:
+		r.Value = ""
+	default:
+		return fmt.Errorf("unsupported operator: %q", r.Op)
+	}
+
+	if r.For == 0 {
+		r.For = 1
+	}
+	if r.For < 1 || r.For > maxFor {
+		return fmt.Errorf("for must be between 1 and %d", maxFor)
+	}
+	if r.RepeatInterval < 0 {
+		return fmt.Errorf("repeatInterval must not be negative")
+	}
+	if len(r.Channels) == 0 {
+		return fmt.Errorf("at least one channel is required")
+	}
+	// Names end up in notification headers, such as email subjects
+	if strings.IndexFunc(r.Name, unicode.IsControl) >= 0 {
+		return fmt.Errorf("name must not contain control characters")
+	}
+	if r.Name == "" {
+		r.Name = r.Condition()
+	}
+	return nil
+}
+
+// Condition returns the rule's condition in readable form
+func (r Rule) Condition() string {
+	cond := r.Metric + " " + r.Op
+	if r.Value != "" {
+		cond += " " + r.Value
+	}
+	if r.For > 1 {
+		cond += fmt.Sprintf(" for %d runs", r.For)
+	}
+	return cond
+}
+
+// OwnedBy reports whether a request from client by id comes from the
+// rule's owner: the same user, or the same client address when the rule
+// was created without one
+func (r Rule) OwnedBy(id identity.Identity, client string) bool {
+	if id.User != "" {
+		return r.Owner == id.User
+	}
+	return r.Owner == client
+}
+
+// appliesTo reports whether the rule watches the given monitor
+func (r Rule) appliesTo(monitorID string) bool {
+	return r.MonitorID == "" || r.MonitorID == monitorID
+}
+
+// observe returns the rule's metric from a result, or false if the
+// result does not carry it
+func (r Rule) observe(result monitor.Result) (string, bool) {
+	if r.Metric == MetricStatus {
+		return result.Status, true
+	}
+	if v, ok := result.Values[r.Metric]; ok {
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+	if v, ok := result.Labels[r.Metric]; ok {
+		return v, true
+	}
+	return "", false
+}
+
+// matches reports whether an observed value satisfies the condition.
+// prev is the previous observation, if any, for the changed operator.
+func (r Rule) matches(value, prev string, hasPrev bool) bool {
+	switch r.Op {
+	case OpChanged:
+		return hasPrev && value != prev
+	case OpEqual:
+		return equal(value, r.Value)
+	case OpNotEqual:
+		return !equal(value, r.Value)
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	threshold, _ := strconv.ParseFloat(r.Value, 64)
+	switch r.Op {
+	case OpGreater:
+		return v > threshold
+	case OpGreaterEqual:
+		return v >= threshold
+	case OpLess:
+		return v < threshold
+	case OpLessEqual:
+		return v <= threshold
+	}
+	return false
+}
+
+// equal compares numerically when both sides are numbers and otherwise
+// ignores case, so that "noerror" matches an rcode of NOERROR
+func equal(a, b string) bool {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return x == y
+	}
+	return strings.EqualFold(a, b)
+}
@@ -0,0 +1,306 @@
+// File: backend/internal/audit/audit.go
+
+// Package audit keeps a tamper-evident record of every run request, its
+// validation and rate-limit outcome and its result. Entries are JSON
+// lines in a file of their own; each carries a sequence number and the
+// hash of the previous entry, and its own hash covers both, so Verify
+// detects edited, inserted, removed or reordered entries. With a key the
+// hashes are HMACs, so rewriting the chain also needs the key.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/internal/jobs"
+	"github.com/himbojo/net-tools-gui/backend/internal/tracing"
+)
+
+// Event types
+const (
+	EventRequest   = "request"
+	EventRateLimit = "rate_limit"
+	EventResult    = "result"
+	EventMonitor   = "monitor"
+)
+
+// Outcomes of requests and rate-limit decisions. Result entries use the
+// job's final status.
+const (
+	OutcomeAccepted = "accepted"
+	OutcomeRejected = "rejected"
+	OutcomeDenied   = "denied"
+)
+
+// Channels through which requests arrive
+const (
+	ChannelWebSocket = "websocket"
+	ChannelHTTP      = "http"
+	ChannelProbe     = "probe"
+	ChannelMonitor   = "monitor"
+)
+
+// Config holds audit log settings. Zero values select the defaults.
+type Config struct {
+	Path string `json:"path"`
+	// KeyFile names a file holding the HMAC key for entry hashes. Without
+	// one the hashes are plain SHA-256.
+	KeyFile string `json:"keyFile,omitempty"`
+}
+
+// withDefaults fills in unset settings
+func (c Config) withDefaults() Config {
+	if c.Path == "" {
+		c.Path = "data/audit.log"
+	}
+	return c
+}
+
+// Entry is one audit record
+type Entry struct {
+	Seq        uint64            `json:"seq"`
+	Time       time.Time         `json:"time"`
+	Event      string            `json:"event"`
+	User       string            `json:"user,omitempty"`
+	Roles      []string          `json:"roles,omitempty"`
+	Client     string            `json:"client,omitempty"`
+	Channel    string            `json:"channel,omitempty"`
+	Method     string            `json:"method,omitempty"`
+	Path       string            `json:"path,omitempty"`
+	Tool       string            `json:"tool,omitempty"`
+	Target     string            `json:"target,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	JobID      string            `json:"jobId,omitempty"`
+	MonitorID  string            `json:"monitorId,omitempty"`
+	Outcome    string            `json:"outcome"`
+	Reason     string            `json:"reason,omitempty"`
+	DurationMs int64             `json:"durationMs,omitempty"`
+	TraceID    string            `json:"traceId,omitempty"`
+	Prev       string            `json:"prev"`
+	Hash       string            `json:"hash,omitempty"`
+}
+
+// NewEntry starts an entry for a request, filling in the identity and
+// trace found in ctx
+func NewEntry(ctx context.Context, event, channel, client string) Entry {
+	id := identity.FromContext(ctx)
+	return Entry{
+		Event:   event,
+		User:    id.User,
+		Roles:   id.Roles,
+		Client:  client,
+		Channel: channel,
+		TraceID: tracing.TraceID(ctx),
+	}
+}
+
+// hashField is how an entry's hash is appended to its JSON. The hash
+// covers the exact bytes of the entry before it, so verification does
+// not depend on how a reader decodes and re-encodes the JSON.
+const hashField = `,"hash":"`
+
+// encode serialises an entry as a line of JSON ending with its hash
+func encode(e Entry, key []byte) ([]byte, string, error) {
+	e.Hash = ""
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, "", err
+	}
+	hash := hashBytes(body, key)
+
+	line := append(body[:len(body)-1:len(body)-1], hashField...)
+	line = append(line, hash...)
+	line = append(line, "\"}\n"...)
+	return line, hash, nil
+}
+
+// hashBytes returns the hex SHA-256 of an entry body, or its HMAC-SHA256
+// when key is set
+func hashBytes(body, key []byte) string {
+	if len(key) == 0 {
+		sum := sha256.Sum256(body)
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ReadKey reads an HMAC key from a file, ignoring surrounding whitespace
+func ReadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read audit key: %w", err)
+	}
+	key := bytes.TrimSpace(data)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("audit key file %s is empty", path)
+	}
+	return key, nil
+}
+
+// Log appends hash-chained entries to the audit file
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+	key  []byte
+	seq  uint64
+	prev string
+}
+
+// Open opens the audit file and continues the chain from its last entry
+func Open(config Config) (*Log, error) {
+	config = config.withDefaults()
+
+	var key []byte
+	if config.KeyFile != "" {
+		var err error
+		if key, err = ReadKey(config.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0755); err != nil {
+		return nil, fmt.Errorf("create audit directory: %w", err)
+	}
+	file, err := os.OpenFile(config.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+
+	l := &Log{file: file, key: key}
+	if err := l.resume(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// resume finds the last complete entry so new entries chain onto it. A
+// final line without a newline is a write cut short by a crash, which
+// was never a complete entry, so it is truncated away. Unreadable
+// complete lines are left for Verify to report.
+func (l *Log) resume() error {
+	if _, err := l.file.Seek(0, 0); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(l.file)
+
+	var offset int64
+	for {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(data) > 0 {
+				slog.Warn("truncating incomplete audit entry", "seq", l.seq+1, "bytes", len(data))
+				if err := l.file.Truncate(offset); err != nil {
+					return fmt.Errorf("truncate audit log: %w", err)
+				}
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read audit log: %w", err)
+		}
+		offset += int64(len(data))
+
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			continue
+		}
+		l.seq = e.Seq
+		l.prev = e.Hash
+	}
+}
+
+// Record appends an entry, numbering and chaining it. Failures are
+// logged, since the request being audited has already been handled.
+func (l *Log) Record(e Entry) {
+	if err := l.append(e); err != nil {
+		slog.Error("failed to write audit entry", "event", e.Event, "error", err)
+	}
+}
+
+// append writes an entry and syncs it to disk
+func (l *Log) append(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return os.ErrClosed
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	e.Seq = l.seq + 1
+	e.Prev = l.prev
+
+	line, hash, err := encode(e, l.key)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(line); err != nil {
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.seq = e.Seq
+	l.prev = hash
+	return nil
+}
+
+// ObserveJob records the result of a finished job. It is meant to be
+// registered with jobs.Manager.OnFinish.
+func (l *Log) ObserveJob(s jobs.Snapshot) {
+	l.Record(Entry{
+		Event:      EventResult,
+		User:       s.User,
+		Roles:      s.Roles,
+		Client:     s.Client,
+		Tool:       s.Tool,
+		Target:     s.Target,
+		Parameters: s.Parameters,
+		JobID:      s.ID,
+		Outcome:    s.Status,
+		Reason:     s.Error,
+		DurationMs: s.DurationMs,
+		TraceID:    s.TraceID,
+	})
+}
+
+// RateLimited records a request turned away by the rate limiter. It is
+// meant to be registered with middleware.RateLimiter.OnReject.
+func (l *Log) RateLimited(r *http.Request) {
+	e := NewEntry(r.Context(), EventRateLimit, "", identity.ClientAddr(r))
+	e.Method = r.Method
+	e.Path = r.URL.Path
+	e.Outcome = OutcomeDenied
+	l.Record(e)
+}
+
+// Close closes the audit file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
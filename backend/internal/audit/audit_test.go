@@ -0,0 +1,217 @@
+// File: backend/internal/audit/audit_test.go
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeLog records n entries in a new log signed with key and returns
+// the log's path
+func writeLog(t *testing.T, key string, n int) string {
+	t.Helper()
+	dir := t.TempDir()
+	config := Config{Path: filepath.Join(dir, "audit.log")}
+	if key != "" {
+		config.KeyFile = filepath.Join(dir, "audit.key")
+		if err := os.WriteFile(config.KeyFile, []byte(key+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, err := Open(config)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		l.Record(Entry{Event: EventRequest, Tool: "ping", Target: "192.0.2.1", Outcome: OutcomeAccepted})
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return config.Path
+}
+
+// readLines returns the entry lines of a log
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// reencode decodes an entry line, changes it and encodes it again with
+// a valid hash for its new contents
+func reencode(t *testing.T, line string, key []byte, change func(*Entry)) string {
+	t.Helper()
+	var e Entry
+	if err := json.Unmarshal([]byte(line), &e); err != nil {
+		t.Fatal(err)
+	}
+	change(&e)
+	data, _, err := encode(e, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestVerify(t *testing.T) {
+	const key = "secret"
+	path := writeLog(t, key, 4)
+	lines := readLines(t, path)
+
+	summary, err := Verify(strings.NewReader(strings.Join(lines, "")), []byte(key))
+	if err != nil {
+		t.Fatalf("verify untouched log: %v", err)
+	}
+	if summary.Entries != 4 || summary.LastSeq != 4 {
+		t.Errorf("summary = %+v, want 4 entries ending at seq 4", summary)
+	}
+
+	tests := []struct {
+		name   string
+		lines  func() []string
+		key    string
+		line   int
+		reason string
+	}{
+		{
+			name: "edited entry",
+			lines: func() []string {
+				l := append([]string{}, lines...)
+				l[1] = strings.Replace(l[1], "192.0.2.1", "192.0.2.9", 1)
+				return l
+			},
+			key: key, line: 2, reason: "hash does not match",
+		},
+		{
+			name: "deleted entry",
+			lines: func() []string {
+				return append(append([]string{}, lines[:1]...), lines[2:]...)
+			},
+			key: key, line: 2, reason: "expected seq 2",
+		},
+		{
+			name: "inserted entry",
+			lines: func() []string {
+				return append(append([]string{}, lines[:2]...), lines[1:]...)
+			},
+			key: key, line: 3, reason: "expected seq 3",
+		},
+		{
+			name: "reordered entries",
+			lines: func() []string {
+				l := append([]string{}, lines...)
+				l[1], l[2] = l[2], l[1]
+				return l
+			},
+			key: key, line: 2, reason: "expected seq 2",
+		},
+		{
+			name: "sequence gap",
+			lines: func() []string {
+				l := append([]string{}, lines...)
+				l[2] = reencode(t, l[2], []byte(key), func(e *Entry) { e.Seq = 7 })
+				return l
+			},
+			key: key, line: 3, reason: "expected seq 3",
+		},
+		{
+			name: "rehashed entry breaks the chain",
+			lines: func() []string {
+				l := append([]string{}, lines...)
+				l[1] = reencode(t, l[1], []byte(key), func(e *Entry) { e.Target = "192.0.2.9" })
+				return l
+			},
+			key: key, line: 3, reason: "previous hash",
+		},
+		{
+			name:  "wrong key",
+			lines: func() []string { return lines },
+			key:   "other", line: 1, reason: "hash does not match",
+		},
+		{
+			name:  "missing key",
+			lines: func() []string { return lines },
+			key:   "", line: 1, reason: "hash does not match",
+		},
+		{
+			name: "entry without hash",
+			lines: func() []string {
+				l := append([]string{}, lines...)
+				l[3] = `{"seq":4}` + "\n"
+				return l
+			},
+			key: key, line: 4, reason: "no hash",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(strings.NewReader(strings.Join(tt.lines(), "")), []byte(tt.key))
+			var verr *VerifyError
+			if !errors.As(err, &verr) {
+				t.Fatalf("err = %v, want a VerifyError", err)
+			}
+			if verr.Line != tt.line || !strings.Contains(verr.Reason, tt.reason) {
+				t.Errorf("err = %v, want line %d: %s", verr, tt.line, tt.reason)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutKey(t *testing.T) {
+	path := writeLog(t, "", 3)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(bytes.NewReader(data), nil); err != nil {
+		t.Errorf("verify unkeyed log: %v", err)
+	}
+	if _, err := Verify(bytes.NewReader(data), []byte("secret")); err == nil {
+		t.Error("unkeyed log verified with a key")
+	}
+}
+
+func TestOpenTruncatesTornEntry(t *testing.T) {
+	path := writeLog(t, "", 3)
+	lines := readLines(t, path)
+
+	// A crash part way through writing a fourth entry leaves half a line
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(lines[2][:len(lines[2])/2])
+	f.Close()
+
+	l, err := Open(Config{Path: path})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	l.Record(Entry{Event: EventRequest, Outcome: OutcomeAccepted})
+	l.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte(strings.Join(lines, ""))) {
+		t.Error("complete entries changed when reopening")
+	}
+	summary, err := Verify(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("verify after reopen: %v", err)
+	}
+	if summary.Entries != 4 || summary.LastSeq != 4 {
+		t.Errorf("summary = %+v, want the chain to continue at seq 4", summary)
+	}
+}
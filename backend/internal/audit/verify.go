@@ -0,0 +1,88 @@
+// File: backend/internal/audit/verify.go
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// maxLineSize bounds a single audit entry
+const maxLineSize = 1 << 20
+
+// hashSuffix matches the hash that ends every entry line
+var hashSuffix = regexp.MustCompile(`,"hash":"([0-9a-f]{64})"}$`)
+
+// Summary describes a verified audit log
+type Summary struct {
+	Entries  int    `json:"entries"`
+	LastSeq  uint64 `json:"lastSeq"`
+	LastHash string `json:"lastHash"`
+}
+
+// VerifyError reports the first entry that breaks the chain
+type VerifyError struct {
+	Line   int
+	Seq    uint64
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	if e.Seq == 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+	}
+	return fmt.Sprintf("line %d (seq %d): %s", e.Line, e.Seq, e.Reason)
+}
+
+// Verify reads an audit log and checks that every entry's hash matches
+// its contents, that sequence numbers run from 1 without gaps and that
+// each entry names the hash of the one before. It returns a *VerifyError
+// for the first entry that fails. Entries removed from the end cannot be
+// detected from the file alone; compare the summary's last sequence
+// number and hash with a copy kept elsewhere. key must be the HMAC key
+// the log was written with, or nil if it was written without one.
+func Verify(r io.Reader, key []byte) (Summary, error) {
+	var summary Summary
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := scanner.Bytes()
+
+		m := hashSuffix.FindSubmatchIndex(data)
+		if m == nil {
+			return summary, &VerifyError{Line: line, Reason: "entry has no hash"}
+		}
+		hash := string(data[m[2]:m[3]])
+
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return summary, &VerifyError{Line: line, Reason: "malformed entry: " + err.Error()}
+		}
+
+		body := append(bytes.Clone(data[:m[0]]), '}')
+		if hashBytes(body, key) != hash {
+			return summary, &VerifyError{Line: line, Seq: e.Seq, Reason: "hash does not match contents"}
+		}
+		if e.Seq != summary.LastSeq+1 {
+			return summary, &VerifyError{Line: line, Seq: e.Seq, Reason: fmt.Sprintf("expected seq %d", summary.LastSeq+1)}
+		}
+		if e.Prev != summary.LastHash {
+			return summary, &VerifyError{Line: line, Seq: e.Seq, Reason: "previous hash does not match the preceding entry"}
+		}
+
+		summary.Entries++
+		summary.LastSeq = e.Seq
+		summary.LastHash = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return summary, fmt.Errorf("read audit log: %w", err)
+	}
+	return summary, nil
+}
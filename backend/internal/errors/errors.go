@@ -16,8 +16,33 @@ const (
 	ErrorTypeRateLimit
 	ErrorTypeTimeout
 	ErrorTypeExecution
+	ErrorTypeNotFound
+	ErrorTypeQueueFull
+	ErrorTypeForbidden
 )
 
+// String returns a short name for the error type
+func (t ErrorType) String() string {
+	switch t {
+	case ErrorTypeValidation:
+		return "validation"
+	case ErrorTypeRateLimit:
+		return "rate_limit"
+	case ErrorTypeTimeout:
+		return "timeout"
+	case ErrorTypeExecution:
+		return "execution"
+	case ErrorTypeNotFound:
+		return "not_found"
+	case ErrorTypeQueueFull:
+		return "queue_full"
+	case ErrorTypeForbidden:
+		return "forbidden"
+	default:
+		return "internal"
+	}
+}
+
 // AppError represents an application error
 type AppError struct {
 	Type    ErrorType
@@ -43,6 +68,12 @@ func (e *AppError) HTTPStatusCode() int {
 		return http.StatusGatewayTimeout
 	case ErrorTypeExecution:
 		return http.StatusInternalServerError
+	case ErrorTypeNotFound:
+		return http.StatusNotFound
+	case ErrorTypeQueueFull:
+		return http.StatusServiceUnavailable
+	case ErrorTypeForbidden:
+		return http.StatusForbidden
 	default:
 		return http.StatusInternalServerError
 	}
@@ -78,6 +109,20 @@ func NewExecutionError(err error) *AppError {
 	}
 }
 
+func NewNotFoundError(message string) *AppError {
+	return &AppError{
+		Type:    ErrorTypeNotFound,
+		Message: message,
+	}
+}
+
+func NewQueueFullError(message string) *AppError {
+	return &AppError{
+		Type:    ErrorTypeQueueFull,
+		Message: message,
+	}
+}
+
 func NewInternalError(err error) *AppError {
 	return &AppError{
 		Type:    ErrorTypeInternal,
@@ -85,3 +130,10 @@ func NewInternalError(err error) *AppError {
 		Err:     err,
 	}
 }
+
+func NewForbiddenError(message string) *AppError {
+	return &AppError{
+		Type:    ErrorTypeForbidden,
+		Message: message,
+	}
+}
@@ -6,10 +6,18 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os/exec"
-	"runtime"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/policy"
+	"github.com/himbojo/net-tools-gui/backend/internal/tracing"
+	"github.com/himbojo/net-tools-gui/backend/pkg/tools"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // CommandRequest represents a request to execute a network tool
@@ -21,164 +29,106 @@ type CommandRequest struct {
 
 // CommandResult represents the result of a command execution
 type CommandResult struct {
-	Tool      string    `json:"tool"`
-	Target    string    `json:"target"`
-	Output    string    `json:"output"`
-	Error     string    `json:"error,omitempty"`
-	StartTime time.Time `json:"startTime"`
-	EndTime   time.Time `json:"endTime"`
+	JobID         string       `json:"jobId,omitempty"`
+	QueuePosition int          `json:"queuePosition,omitempty"`
+	Tool          string       `json:"tool"`
+	Target        string       `json:"target"`
+	Output        string       `json:"output"`
+	Event         *tools.Event `json:"event,omitempty"`
+	Error         string       `json:"error,omitempty"`
+	StartTime     time.Time    `json:"startTime"`
+	EndTime       time.Time    `json:"endTime"`
 }
 
 // CommandExecutor handles the execution of network tools
 type CommandExecutor struct {
-	toolPaths map[string]string
-	timeout   time.Duration
+	registry *tools.Registry
+	policy   *policy.Policy
 }
 
-// NewExecutor creates a new CommandExecutor instance
-func NewExecutor() *CommandExecutor {
-	// Initialize with appropriate paths based on the operating system
-	var pingPath, digPath, traceroutePath string
-
-	switch runtime.GOOS {
-	case "windows":
-		pingPath = "ping"
-		digPath = "dig"
-		traceroutePath = "tracert"
-	default: // linux, darwin, etc.
-		pingPath = "/usr/bin/ping"
-		digPath = "/usr/bin/dig"
-		traceroutePath = "/usr/bin/traceroute"
-	}
-
+// NewExecutor creates a new CommandExecutor instance. Runs are checked
+// against pol when they start; a nil pol allows every target.
+func NewExecutor(registry *tools.Registry, pol *policy.Policy) *CommandExecutor {
 	return &CommandExecutor{
-		toolPaths: map[string]string{
-			"ping":       pingPath,
-			"dig":        digPath,
-			"traceroute": traceroutePath,
-		},
-		timeout: 60 * time.Second,
+		registry: registry,
+		policy:   pol,
 	}
 }
 
-// buildCommandString creates a human-readable command string
-func (e *CommandExecutor) buildCommandString(tool, target string, params map[string]string) string {
-	switch tool {
-	case "ping":
-		count := "4"
-		if c, ok := params["count"]; ok && c != "" {
-			count = c
-		}
-		switch runtime.GOOS {
-		case "windows":
-			return fmt.Sprintf("ping -n %s -w 2000 %s", count, target)
-		case "darwin":
-			return fmt.Sprintf("ping -c %s -t 2 %s", count, target)
-		default: // linux
-			return fmt.Sprintf("ping -c %s -W 2 -O %s", count, target)
-		}
-
-	case "dig":
-		recordType := "A"
-		if t, ok := params["type"]; ok && t != "" {
-			recordType = t
-		}
-		return fmt.Sprintf("dig +nocomments +noquestion %s %s", recordType, target)
+// Execute runs a network tool command and streams the output. The run
+// is traced as an "execute" span with children for starting the process,
+// waiting for its first output and waiting for it to exit. ctx bounds the
+// run; the executor sets no deadline of its own.
+func (e *CommandExecutor) Execute(ctx context.Context, tool, target string, params map[string]string, outputChan chan<- CommandResult) {
+	ctx, span := tracing.Start(ctx, "execute",
+		attribute.String("tool", tool),
+		attribute.String("target", target),
+	)
+	defer span.End()
 
-	case "traceroute":
-		maxHops := "30"
-		if h, ok := params["maxHops"]; ok && h != "" {
-			maxHops = h
-		}
-		switch runtime.GOOS {
-		case "windows":
-			return fmt.Sprintf("tracert -h %s -w 2000 %s", maxHops, target)
-		default:
-			return fmt.Sprintf("traceroute -m %s -w 2 %s", maxHops, target)
-		}
+	result := CommandResult{
+		Tool:      tool,
+		Target:    target,
+		StartTime: time.Now(),
 	}
-	return ""
-}
 
-func (e *CommandExecutor) buildCommand(tool, target string, params map[string]string) (*exec.Cmd, error) {
-	toolPath, exists := e.toolPaths[tool]
-	if !exists {
-		return nil, fmt.Errorf("unsupported tool: %s", tool)
+	t, ok := e.registry.Get(tool)
+	if !ok {
+		result.Error = fmt.Sprintf("unsupported tool: %s", tool)
+		result.EndTime = time.Now()
+		tracing.Fail(span, fmt.Errorf("%s", result.Error))
+		outputChan <- result
+		return
 	}
 
-	args := []string{}
-
-	switch tool {
-	case "ping":
-		count := "4"
-		if c, ok := params["count"]; ok && c != "" {
-			count = c
-		}
-
-		switch runtime.GOOS {
-		case "windows":
-			args = []string{"-n", count, "-w", "2000", target}
-		case "darwin":
-			args = []string{"-c", count, "-t", "2", target}
-		default: // linux
-			args = []string{"-c", count, "-W", "2", "-O", target}
-		}
-
-	case "dig":
-		recordType := "A"
-		if t, ok := params["type"]; ok && t != "" {
-			recordType = t
-		}
-		args = []string{"+nocomments", "+noquestion", recordType, target}
-
-	case "traceroute":
-		maxHops := "30"
-		if h, ok := params["maxHops"]; ok && h != "" {
-			maxHops = h
-		}
-		switch runtime.GOOS {
-		case "windows":
-			args = []string{"-h", maxHops, "-w", "2000", target}
-		default:
-			args = []string{"-m", maxHops, "-w", "2", target}
+	// Check the target again as the run starts, since its DNS records
+	// may have changed since validation, and pin the addresses found
+	commandTarget := target
+	if e.policy != nil {
+		access, err := e.policy.Check(ctx, tool, target, params)
+		if err != nil {
+			result.Error = err.Error()
+			result.EndTime = time.Now()
+			tracing.Fail(span, err)
+			outputChan <- result
+			return
 		}
+		ctx = tools.WithGuard(ctx, access)
+		commandTarget = access.Target(target)
+	}
 
-	default:
-		return nil, fmt.Errorf("invalid tool specified")
+	if runner, ok := t.(tools.Runner); ok && runner.Native(params) {
+		span.SetAttributes(attribute.Bool("native", true))
+		e.executeNative(ctx, span, runner, tool, target, params, outputChan)
+		return
 	}
 
-	return exec.Command(toolPath, args...), nil
-}
+	// Build the command
+	if commandTarget != target {
+		span.SetAttributes(attribute.String("target.address", commandTarget))
+	}
+	command, err := t.Command(commandTarget, params)
+	if err != nil {
+		result.Error = err.Error()
+		result.EndTime = time.Now()
+		tracing.Fail(span, err)
+		outputChan <- result
+		return
+	}
+	span.SetAttributes(attribute.String("process.executable.path", command.Path))
 
-// Execute runs a network tool command and streams the output
-func (e *CommandExecutor) Execute(ctx context.Context, tool, target string, params map[string]string, outputChan chan<- CommandResult) {
 	// Send command string as first output
-	cmdString := e.buildCommandString(tool, target, params)
 	outputChan <- CommandResult{
 		Tool:      tool,
 		Target:    target,
-		Output:    fmt.Sprintf("$ %s", cmdString),
+		Output:    fmt.Sprintf("$ %s", command),
 		StartTime: time.Now(),
 	}
 
-	result := CommandResult{
-		Tool:      tool,
-		Target:    target,
-		StartTime: time.Now(),
-	}
-
-	// Create a context with timeout
-	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	// Build the command
-	cmd, err := e.buildCommand(tool, target, params)
-	if err != nil {
-		result.Error = err.Error()
-		result.EndTime = time.Now()
-		outputChan <- result
-		return
-	}
+	cmd := exec.Command(command.Path, command.Args...)
 
 	// Create pipes for stdout and stderr
 	stdout, err := cmd.StdoutPipe()
@@ -198,46 +148,50 @@ func (e *CommandExecutor) Execute(ctx context.Context, tool, target string, para
 	}
 
 	// Start the command
+	_, startSpan := tracing.Start(ctx, "process.start")
 	if err := cmd.Start(); err != nil {
 		result.Error = fmt.Sprintf("failed to start command: %v", err)
 		result.EndTime = time.Now()
+		slog.ErrorContext(ctx, "failed to start process", "path", command.Path, "error", err)
+		tracing.Fail(startSpan, err)
+		startSpan.End()
+		tracing.Fail(span, err)
 		outputChan <- result
 		return
 	}
+	startSpan.SetAttributes(attribute.Int("process.pid", cmd.Process.Pid))
+	startSpan.End()
+	slog.DebugContext(ctx, "process started", "path", command.Path, "args", command.Args, "pid", cmd.Process.Pid)
 
-	// Channel to signal when reading is complete
-	done := make(chan error, 1)
+	// The first output span ends with the first line on either stream,
+	// or with the process if it prints nothing
+	_, firstSpan := tracing.Start(ctx, "process.first_output")
+	var firstOnce sync.Once
+	firstOutput := func() { firstOnce.Do(func() { firstSpan.End() }) }
+	defer firstOutput()
+
+	_, waitSpan := tracing.Start(ctx, "process.wait")
+	defer waitSpan.End()
+
+	// Readers must finish before Wait closes the pipes
+	var readers sync.WaitGroup
+	readers.Add(2)
 
 	// Read stdout in a goroutine
 	go func() {
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if line = strings.TrimSpace(line); line != "" {
-				select {
-				case outputChan <- CommandResult{
-					Tool:      tool,
-					Target:    target,
-					Output:    line,
-					StartTime: time.Now(),
-				}:
-				case <-ctx.Done():
-					return
-				}
-			}
-		}
-		if err := scanner.Err(); err != nil {
-			done <- err
-		}
+		defer readers.Done()
+		e.streamStdout(ctx, stdout, t.NewParser(), tool, target, firstOutput, outputChan)
 	}()
 
 	// Read stderr in a goroutine
 	go func() {
+		defer readers.Done()
 		scanner := bufio.NewScanner(stderr)
 		var errOutput strings.Builder
 		for scanner.Scan() {
 			line := scanner.Text()
 			if strings.TrimSpace(line) != "" {
+				firstOutput()
 				errOutput.WriteString(line + "\n")
 			}
 		}
@@ -255,7 +209,9 @@ func (e *CommandExecutor) Execute(ctx context.Context, tool, target string, para
 	}()
 
 	// Wait for command completion or context cancellation
+	done := make(chan error, 1)
 	go func() {
+		readers.Wait()
 		done <- cmd.Wait()
 	}()
 
@@ -264,15 +220,24 @@ func (e *CommandExecutor) Execute(ctx context.Context, tool, target string, para
 		if cmd.Process != nil {
 			cmd.Process.Kill()
 		}
-		result.Error = "command execution timed out"
+		// The readers may still be sending; the caller closes outputChan
+		// once Execute returns, so they must be gone first
+		<-done
+		result.Error = contextErrorMessage(ctx)
 		result.EndTime = time.Now()
+		tracing.Fail(waitSpan, ctx.Err())
+		tracing.Fail(span, ctx.Err())
 		outputChan <- result
 		return
 
 	case err := <-done:
 		result.EndTime = time.Now()
+		waitSpan.SetAttributes(attribute.Int("process.exit.code", cmd.ProcessState.ExitCode()))
+		slog.DebugContext(ctx, "process exited", "pid", cmd.Process.Pid, "exit_code", cmd.ProcessState.ExitCode())
 		if err != nil && result.Error == "" {
 			result.Error = err.Error()
+			tracing.Fail(waitSpan, err)
+			tracing.Fail(span, err)
 			outputChan <- result
 		}
 		// Send a final result to indicate completion
@@ -285,3 +250,105 @@ func (e *CommandExecutor) Execute(ctx context.Context, tool, target string, para
 		return
 	}
 }
+
+// executeNative runs an in-process tool and streams its output, marking
+// the first output on span
+func (e *CommandExecutor) executeNative(ctx context.Context, span trace.Span, runner tools.Runner, tool, target string, params map[string]string, outputChan chan<- CommandResult) {
+	result := CommandResult{
+		Tool:      tool,
+		Target:    target,
+		StartTime: time.Now(),
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The caller reads until outputChan is closed, so output emitted
+	// after cancellation, such as a final summary, is still delivered
+	var firstOnce sync.Once
+	emit := func(line string, event *tools.Event) {
+		firstOnce.Do(func() { span.AddEvent("first_output") })
+		outputChan <- CommandResult{
+			Tool:      tool,
+			Target:    target,
+			Output:    line,
+			Event:     event,
+			StartTime: time.Now(),
+		}
+	}
+
+	err := runner.Run(ctx, target, params, emit)
+	result.EndTime = time.Now()
+
+	if ctx.Err() != nil {
+		result.Error = contextErrorMessage(ctx)
+		tracing.Fail(span, ctx.Err())
+		outputChan <- result
+		return
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+		tracing.Fail(span, err)
+		outputChan <- result
+	}
+	// Send a final result to indicate completion
+	outputChan <- CommandResult{
+		Tool:      tool,
+		Target:    target,
+		EndTime:   time.Now(),
+		StartTime: result.StartTime,
+	}
+}
+
+// streamStdout sends each stdout line, with any parsed event, to
+// outputChan, calling firstOutput before each line
+func (e *CommandExecutor) streamStdout(ctx context.Context, stdout io.Reader, parser tools.Parser, tool, target string, firstOutput func(), outputChan chan<- CommandResult) {
+	send := func(r CommandResult) bool {
+		select {
+		case outputChan <- r:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		firstOutput()
+		if !send(CommandResult{
+			Tool:      tool,
+			Target:    target,
+			Output:    line,
+			Event:     parser.ParseLine(line),
+			StartTime: time.Now(),
+		}) {
+			return
+		}
+	}
+
+	// Flush events that only complete once output ends
+	for _, event := range parser.Finish() {
+		event := event
+		if !send(CommandResult{
+			Tool:      tool,
+			Target:    target,
+			Event:     &event,
+			StartTime: time.Now(),
+		}) {
+			return
+		}
+	}
+}
+
+// contextErrorMessage describes why ctx ended a command
+func contextErrorMessage(ctx context.Context) string {
+	if ctx.Err() == context.Canceled {
+		return "command execution cancelled"
+	}
+	return "command execution timed out"
+}
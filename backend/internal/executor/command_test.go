@@ -0,0 +1,64 @@
+// File: backend/internal/executor/command_test.go
+
+package executor
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/pkg/tools"
+)
+
+// yesTool runs yes against the target, printing it until killed
+type yesTool struct {
+	path string
+}
+
+func (t yesTool) Name() string                            { return "yes" }
+func (t yesTool) Description() string                     { return "Prints the target forever" }
+func (t yesTool) Parameters() []tools.Parameter           { return nil }
+func (t yesTool) Validate(params map[string]string) error { return nil }
+func (t yesTool) NewParser() tools.Parser                 { return tools.NopParser{} }
+
+func (t yesTool) Command(target string, params map[string]string) (tools.Command, error) {
+	return tools.Command{Path: t.path, Args: []string{target}}, nil
+}
+
+// newYesExecutor returns an executor whose only tool is yes
+func newYesExecutor(t *testing.T) *CommandExecutor {
+	path, err := exec.LookPath("yes")
+	if err != nil {
+		t.Skipf("yes not available: %v", err)
+	}
+	registry := tools.NewRegistry()
+	registry.MustRegister(yesTool{path: path})
+	return NewExecutor(registry, nil)
+}
+
+// TestExecuteCancelClosesSafely closes the output channel as soon as
+// Execute returns, as the job manager does, while the process is still
+// writing. A reader left sending would panic on the closed channel.
+func TestExecuteCancelClosesSafely(t *testing.T) {
+	e := newYesExecutor(t)
+
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		outputChan := make(chan CommandResult)
+		go func() {
+			defer close(outputChan)
+			e.Execute(ctx, "yes", "y", nil, outputChan)
+		}()
+
+		var last CommandResult
+		for result := range outputChan {
+			last = result
+		}
+		cancel()
+
+		if last.Error != contextErrorMessage(ctx) || last.EndTime.IsZero() {
+			t.Fatalf("last result = %+v, want the timeout error", last)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// File: backend/internal/handlers/alert.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/alert"
+	"github.com/himbojo/net-tools-gui/backend/internal/errors"
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/internal/monitor"
+)
+
+// AlertHandler handles the alert and alert rule endpoints
+type AlertHandler struct {
+	alerts   *alert.Engine
+	monitors *monitor.Manager
+}
+
+// NewAlertHandler creates a new AlertHandler instance
+func NewAlertHandler(engine *alert.Engine, manager *monitor.Manager) *AlertHandler {
+	return &AlertHandler{
+		alerts:   engine,
+		monitors: manager,
+	}
+}
+
+// HandleAlerts returns the pending or firing alerts on the monitors the
+// caller may access
+func (h *AlertHandler) HandleAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts := []alert.Alert{}
+	for _, a := range h.alerts.Alerts() {
+		if mon, ok := h.monitors.Get(a.MonitorID); ok && mayAccessMonitor(r.Context(), clientID(r), mon) {
+			alerts = append(alerts, a)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"alerts": alerts})
+}
+
+// HandleListRules returns the caller's rules, or every rule for admins,
+// and the channels they may notify
+func (h *AlertHandler) HandleListRules(w http.ResponseWriter, r *http.Request) {
+	rules := []alert.Rule{}
+	for _, rule := range h.alerts.Rules() {
+		if mayAccessRule(r.Context(), clientID(r), rule) {
+			rules = append(rules, rule)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"rules":    rules,
+		"channels": h.alerts.Channels(),
+	})
+}
+
+// HandleCreateRule creates a rule owned by the caller
+func (h *AlertHandler) HandleCreateRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+	rule.Owner = clientID(r)
+	if user := identity.FromContext(r.Context()).User; user != "" {
+		rule.Owner = user
+	}
+
+	created, err := h.alerts.CreateRule(rule)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/alerts/rules/"+created.ID)
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// HandleGetRule returns a single rule
+func (h *AlertHandler) HandleGetRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+// HandleUpdateRule replaces a rule
+func (h *AlertHandler) HandleUpdateRule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.lookup(w, r); !ok {
+		return
+	}
+	rule, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := h.alerts.UpdateRule(r.PathValue("id"), rule)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// HandleDeleteRule removes a rule
+func (h *AlertHandler) HandleDeleteRule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.lookup(w, r); !ok {
+		return
+	}
+	if err := h.alerts.DeleteRule(r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookup returns the rule named in the path if the caller may access
+// it. Other users' rules are reported as not found.
+func (h *AlertHandler) lookup(w http.ResponseWriter, r *http.Request) (alert.Rule, bool) {
+	rule, ok := h.alerts.Rule(r.PathValue("id"))
+	if !ok || !mayAccessRule(r.Context(), clientID(r), rule) {
+		writeError(w, errors.NewNotFoundError("rule not found"))
+		return alert.Rule{}, false
+	}
+	return rule, true
+}
+
+// mayAccessRule reports whether a request from client may read or change
+// rule: its owner and admins may
+func mayAccessRule(ctx context.Context, client string, rule alert.Rule) bool {
+	id := identity.FromContext(ctx)
+	return id.Admin || rule.OwnedBy(id, client)
+}
+
+// decode reads a rule from the request body and checks that the caller
+// may watch its monitor. Only admins may write rules for every monitor.
+func (h *AlertHandler) decode(w http.ResponseWriter, r *http.Request) (alert.Rule, bool) {
+	var rule alert.Rule
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&rule); err != nil {
+		writeError(w, errors.NewValidationError(fmt.Sprintf("invalid request body: %v", err)))
+		return rule, false
+	}
+	if rule.MonitorID == "" {
+		if !identity.FromContext(r.Context()).Admin {
+			writeError(w, errors.NewForbiddenError("only admins may create rules for every monitor"))
+			return rule, false
+		}
+		return rule, true
+	}
+	// Monitors the caller may not access are unknown to them
+	if mon, ok := h.monitors.Get(rule.MonitorID); !ok || !mayAccessMonitor(r.Context(), clientID(r), mon) {
+		writeError(w, errors.NewValidationError("unknown monitor: "+rule.MonitorID))
+		return rule, false
+	}
+	return rule, true
+}
@@ -0,0 +1,133 @@
+// File: backend/internal/handlers/alert_test.go
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/alert"
+	"github.com/himbojo/net-tools-gui/backend/internal/executor"
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/internal/monitor"
+)
+
+// newTestAlertHandler builds an alert handler over the monitors of mh,
+// with one webhook channel that accepts every notification
+func newTestAlertHandler(t *testing.T, mh *MonitorHandler) *AlertHandler {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(hook.Close)
+	engine, err := alert.Open(alert.Config{
+		Path:     filepath.Join(t.TempDir(), "alerts.db"),
+		Channels: []alert.ChannelConfig{{Name: "hook", Type: alert.ChannelWebhook, URL: hook.URL}},
+	})
+	if err != nil {
+		t.Fatalf("open alerts: %v", err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	return NewAlertHandler(engine, mh.monitors)
+}
+
+// createRule posts a rule for monitorID as caller and returns the status
+// and the created rule
+func createRule(h *AlertHandler, monitorID string, caller identity.Identity) (int, alert.Rule) {
+	body := `{"metric":"loss","op":">","value":"10","channels":["hook"],"monitorId":"` + monitorID + `"}`
+	rec := httptest.NewRecorder()
+	h.HandleCreateRule(rec, monitorRequest(http.MethodPost, "/api/v1/alerts/rules", "", body, caller))
+	var rule alert.Rule
+	json.Unmarshal(rec.Body.Bytes(), &rule)
+	return rec.Code, rule
+}
+
+func TestAlertRuleOwnership(t *testing.T) {
+	mh := newTestMonitorHandler(t)
+	h := newTestAlertHandler(t, mh)
+	alice := identity.Identity{User: "alice"}
+	bob := identity.Identity{User: "bob"}
+	admin := identity.Identity{User: "root", Admin: true}
+
+	mon, err := mh.monitors.Create(monitor.Monitor{
+		Name:     "gateway",
+		Request:  executor.CommandRequest{Tool: "echo", Target: "127.0.0.1"},
+		Schedule: monitor.Schedule{Interval: 60},
+		Owner:    "alice",
+	})
+	if err != nil {
+		t.Fatalf("create monitor: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		caller    identity.Identity
+		monitorID string
+		status    int
+	}{
+		{"owner watches own monitor", alice, mon.ID, http.StatusCreated},
+		{"other user cannot watch it", bob, mon.ID, http.StatusBadRequest},
+		{"unknown monitor", alice, "missing", http.StatusBadRequest},
+		{"global rule needs admin", alice, "", http.StatusForbidden},
+		{"admin writes global rule", admin, "", http.StatusCreated},
+	}
+	rules := map[string]alert.Rule{}
+	for _, tt := range tests {
+		code, rule := createRule(h, tt.monitorID, tt.caller)
+		if code != tt.status {
+			t.Errorf("%s: create = %d, want %d", tt.name, code, tt.status)
+		}
+		if code == http.StatusCreated {
+			if rule.Owner != tt.caller.User {
+				t.Errorf("%s: owner = %q, want %q", tt.name, rule.Owner, tt.caller.User)
+			}
+			rules[tt.caller.User] = rule
+		}
+	}
+
+	// Each user sees their own rules; admins see all
+	for caller, want := range map[*identity.Identity]int{&alice: 1, &bob: 0, &admin: 2} {
+		rec := httptest.NewRecorder()
+		h.HandleListRules(rec, monitorRequest(http.MethodGet, "/api/v1/alerts/rules", "", "", *caller))
+		var body struct {
+			Rules []alert.Rule `json:"rules"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if len(body.Rules) != want {
+			t.Errorf("%s lists %d rules, want %d", caller.User, len(body.Rules), want)
+		}
+	}
+
+	// Other users' rules are not found, whatever the method
+	id := rules["alice"].ID
+	for name, handle := range map[string]http.HandlerFunc{
+		"get":    h.HandleGetRule,
+		"update": h.HandleUpdateRule,
+		"delete": h.HandleDeleteRule,
+	} {
+		rec := httptest.NewRecorder()
+		body := `{"metric":"loss","op":">","value":"1","channels":["hook"]}`
+		handle(rec, monitorRequest(http.MethodPut, "/api/v1/alerts/rules/"+id, id, body, bob))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("bob %s = %d, want 404", name, rec.Code)
+		}
+	}
+	if rule, _ := h.alerts.Rule(id); rule.Value != "10" {
+		t.Errorf("rule changed by another user: %+v", rule)
+	}
+
+	// Alerts are only shown to those who may see the monitor
+	h.alerts.Evaluate(mon, monitor.Result{MonitorID: mon.ID, Time: time.Now(), Status: "success", Values: map[string]float64{"loss": 50}})
+	for caller, want := range map[*identity.Identity]int{&alice: 2, &bob: 0, &admin: 2} {
+		rec := httptest.NewRecorder()
+		h.HandleAlerts(rec, monitorRequest(http.MethodGet, "/api/v1/alerts", "", "", *caller))
+		var body struct {
+			Alerts []alert.Alert `json:"alerts"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if len(body.Alerts) != want {
+			t.Errorf("%s sees %d alerts, want %d", caller.User, len(body.Alerts), want)
+		}
+	}
+}
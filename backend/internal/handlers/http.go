@@ -1,23 +1,424 @@
 package handlers
 
-import "net/http"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/audit"
+	"github.com/himbojo/net-tools-gui/backend/internal/errors"
+	"github.com/himbojo/net-tools-gui/backend/internal/executor"
+	"github.com/himbojo/net-tools-gui/backend/internal/history"
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/internal/jobs"
+	"github.com/himbojo/net-tools-gui/backend/internal/logger"
+	"github.com/himbojo/net-tools-gui/backend/internal/metrics"
+	"github.com/himbojo/net-tools-gui/backend/internal/policy"
+	"github.com/himbojo/net-tools-gui/backend/internal/protocol"
+	"github.com/himbojo/net-tools-gui/backend/internal/tracing"
+	"github.com/himbojo/net-tools-gui/backend/internal/validator"
+	"github.com/himbojo/net-tools-gui/backend/pkg/tools"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxRequestBody caps the size of JSON request bodies
+const maxRequestBody = 64 << 10
 
 // HTTPHandler handles standard HTTP endpoints
 type HTTPHandler struct {
-	// TODO: Add handler dependencies
+	registry      *tools.Registry
+	validator     *validator.Validator
+	jobs          *jobs.Manager
+	history       *history.Store
+	audit         *audit.Log
+	cancelTimeout time.Duration
 }
 
 // NewHTTPHandler creates a new HTTPHandler instance
-func NewHTTPHandler() *HTTPHandler {
-	return &HTTPHandler{}
+func NewHTTPHandler(registry *tools.Registry, val *validator.Validator, manager *jobs.Manager, store *history.Store, auditLog *audit.Log) *HTTPHandler {
+	return &HTTPHandler{
+		registry:      registry,
+		validator:     val,
+		jobs:          manager,
+		history:       store,
+		audit:         auditLog,
+		cancelTimeout: 5 * time.Second,
+	}
 }
 
 // HandleHealth processes health check requests
 func (h *HTTPHandler) HandleHealth(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement health check
+	writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
 }
 
 // HandleToolsList processes tool listing requests
 func (h *HTTPHandler) HandleToolsList(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement tools list
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"tools":       h.registry.Names(),
+		"definitions": h.registry.Definitions(),
+	})
+}
+
+// HandleCreateJob runs a tool. By default it waits for the job and
+// returns its result; with "Accept: text/event-stream" it streams
+// envelopes as Server-Sent Events, and with "?wait=false" it returns
+// immediately so the job can be polled.
+func (h *HTTPHandler) HandleCreateJob(w http.ResponseWriter, r *http.Request) {
+	var req executor.CommandRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&req); err != nil {
+		appErr := errors.NewValidationError(fmt.Sprintf("invalid request body: %v", err))
+		auditRequest(r.Context(), h.audit, audit.ChannelHTTP, clientID(r), req, nil, appErr)
+		writeError(w, appErr)
+		return
+	}
+	if err := validateCommand(r.Context(), h.validator, &req); err != nil {
+		logRejected(r.Context(), req, err)
+		auditRequest(r.Context(), h.audit, audit.ChannelHTTP, clientID(r), req, nil, err)
+		appErr := rejection(err)
+		metrics.RecordError(req.Tool, appErr)
+		writeError(w, appErr)
+		return
+	}
+
+	job, err := h.jobs.Submit(r.Context(), req, clientID(r))
+	auditRequest(r.Context(), h.audit, audit.ChannelHTTP, clientID(r), req, job, err)
+	if err != nil {
+		logRejected(r.Context(), req, err)
+		metrics.RecordError(req.Tool, err)
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+
+	switch {
+	case wantsEventStream(r):
+		h.streamEvents(w, r, job, 0, true)
+	case r.URL.Query().Get("wait") == "false":
+		writeJSON(w, http.StatusAccepted, job.Snapshot())
+	default:
+		extendWriteDeadline(w)
+		select {
+		case <-job.Done():
+		case <-r.Context().Done():
+			job.Cancel()
+			return
+		}
+		writeJSON(w, http.StatusOK, job.Snapshot())
+	}
+}
+
+// HandleGetJob returns the state of a job, or follows it as
+// Server-Sent Events when the client asks for an event stream. A
+// reconnecting stream resumes after its Last-Event-ID.
+func (h *HTTPHandler) HandleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobs.Get(r.PathValue("id"))
+	if !ok || !mayAccess(r.Context(), clientID(r), job) {
+		writeError(w, errors.NewNotFoundError("job not found"))
+		return
+	}
+
+	if wantsEventStream(r) {
+		after, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+		h.streamEvents(w, r, job, after, false)
+		return
+	}
+	writeJSON(w, http.StatusOK, job.Snapshot())
+}
+
+// HandleCancelJob cancels a job and returns its state once it has stopped
+func (h *HTTPHandler) HandleCancelJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobs.Get(r.PathValue("id"))
+	if !ok || !mayAccess(r.Context(), clientID(r), job) {
+		writeError(w, errors.NewNotFoundError("job not found"))
+		return
+	}
+
+	job.Cancel()
+	select {
+	case <-job.Done():
+		writeJSON(w, http.StatusOK, job.Snapshot())
+	case <-time.After(h.cancelTimeout):
+		writeJSON(w, http.StatusAccepted, job.Snapshot())
+	case <-r.Context().Done():
+	}
+}
+
+// HandleHistory searches finished jobs. Supported query parameters are
+// tool, target (substring), user, role, client (address), status, since
+// and until (RFC 3339) and limit. Users other than admins only see
+// their own jobs, whatever user and client they ask for.
+func (h *HTTPHandler) HandleHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := history.Filter{
+		Tool:   q.Get("tool"),
+		Target: q.Get("target"),
+		User:   q.Get("user"),
+		Role:   q.Get("role"),
+		Client: q.Get("client"),
+		Status: q.Get("status"),
+	}
+	if id := identity.FromContext(r.Context()); !id.Admin {
+		filter.User = id.User
+		if id.User == "" {
+			filter.Client = clientID(r)
+			filter.Anonymous = true
+		}
+	}
+
+	var err error
+	if filter.Since, err = parseTimeParam(q.Get("since")); err != nil {
+		writeError(w, errors.NewValidationError("invalid since: "+err.Error()))
+		return
+	}
+	if filter.Until, err = parseTimeParam(q.Get("until")); err != nil {
+		writeError(w, errors.NewValidationError("invalid until: "+err.Error()))
+		return
+	}
+	if limit := q.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 1 {
+			writeError(w, errors.NewValidationError("invalid limit"))
+			return
+		}
+	}
+
+	records, err := h.history.Query(filter)
+	if err != nil {
+		writeError(w, errors.NewInternalError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"records": records})
+}
+
+// HandleHistoryRecord returns the stored record of a single job. Other
+// users' records are reported as not found.
+func (h *HTTPHandler) HandleHistoryRecord(w http.ResponseWriter, r *http.Request) {
+	record, ok, err := h.history.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, errors.NewInternalError(err))
+		return
+	}
+	if !ok || !mayAccessRecord(r.Context(), clientID(r), record) {
+		writeError(w, errors.NewNotFoundError("job not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+// streamEvents writes a job's envelopes after seq as Server-Sent Events
+// until it finishes. If owned is set the job is cancelled when the
+// client leaves.
+func (h *HTTPHandler) streamEvents(w http.ResponseWriter, r *http.Request, job *jobs.Job, after uint64, owned bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, errors.NewInternalError(fmt.Errorf("streaming not supported")))
+		return
+	}
+	extendWriteDeadline(w)
+
+	span := startStream(r.Context(), job)
+	defer span.End()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	lastSeq := after
+	for {
+		sub := job.Subscribe(lastSeq)
+		if sub.Missed {
+			writeEvent(w, protocol.NewError("resume gap", fmt.Sprintf("output after seq %d is no longer available", lastSeq)))
+		}
+		for _, env := range sub.Replay {
+			writeEvent(w, env)
+			lastSeq = env.Seq
+		}
+		flusher.Flush()
+
+		if !h.forwardEvents(w, flusher, r, sub, &lastSeq) {
+			sub.Close()
+			if owned {
+				job.Cancel()
+			}
+			span.SetAttributes(attribute.Int64("stream.last_seq", int64(lastSeq)))
+			return
+		}
+
+		// The subscription ends when the job finishes or the client
+		// falls behind; in both cases catch up from the buffer
+		select {
+		case <-job.Done():
+			if sub := job.Subscribe(lastSeq); len(sub.Replay) > 0 {
+				for _, env := range sub.Replay {
+					writeEvent(w, env)
+					lastSeq = env.Seq
+				}
+				flusher.Flush()
+			}
+			span.SetAttributes(attribute.Int64("stream.last_seq", int64(lastSeq)))
+			return
+		default:
+		}
+	}
+}
+
+// forwardEvents writes updates until the subscription ends, returning
+// false if the client went away first
+func (h *HTTPHandler) forwardEvents(w http.ResponseWriter, flusher http.Flusher, r *http.Request, sub *jobs.Subscription, lastSeq *uint64) bool {
+	for {
+		select {
+		case update, ok := <-sub.Updates:
+			if !ok {
+				return true
+			}
+			for _, env := range update.Envelopes {
+				writeEvent(w, env)
+				*lastSeq = env.Seq
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return false
+		}
+	}
+}
+
+// writeEvent writes an envelope as a single Server-Sent Event
+func writeEvent(w http.ResponseWriter, env protocol.Envelope) {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return
+	}
+	if env.Seq > 0 {
+		fmt.Fprintf(w, "id: %d\n", env.Seq)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", env.Type, data)
+}
+
+// wantsEventStream reports whether the client asked for Server-Sent Events
+func wantsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream") || r.URL.Query().Get("stream") == "true"
+}
+
+// extendWriteDeadline lifts the server write timeout for responses that
+// last as long as a job does
+func extendWriteDeadline(w http.ResponseWriter) {
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter
+func parseTimeParam(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// validateCommand checks a request and the access policy within a
+// validation span. A valid request's target is put in canonical form.
+func validateCommand(ctx context.Context, val *validator.Validator, req *executor.CommandRequest) error {
+	ctx, span := tracing.Start(ctx, "validate",
+		attribute.String("tool", req.Tool),
+		attribute.String("target", req.Target),
+	)
+	defer span.End()
+
+	target, err := val.ValidateCommand(req.Tool, req.Target, req.Parameters)
+	if err == nil {
+		err = val.ValidateAccess(ctx, req.Tool, target, req.Parameters)
+	}
+	if err != nil {
+		tracing.Fail(span, err)
+		return err
+	}
+	req.Target = target
+	return nil
+}
+
+// rejection converts an error from validateCommand to the error returned
+// to the client
+func rejection(err error) *errors.AppError {
+	if _, ok := err.(*policy.DeniedError); ok {
+		return errors.NewForbiddenError(err.Error())
+	}
+	return errors.NewValidationError(err.Error())
+}
+
+// logRejected logs a run request that was refused before it became a job
+func logRejected(ctx context.Context, req executor.CommandRequest, err error) {
+	slog.InfoContext(ctx, "run rejected",
+		logger.KeyTool, req.Tool,
+		logger.KeyTarget, req.Target,
+		"reason", err.Error(),
+	)
+}
+
+// auditRequest records whether a run request became a job. A nil err
+// means the request was accepted as job.
+func auditRequest(ctx context.Context, auditLog *audit.Log, channel, client string, req executor.CommandRequest, job *jobs.Job, err error) {
+	e := audit.NewEntry(ctx, audit.EventRequest, channel, client)
+	e.Tool = req.Tool
+	e.Target = req.Target
+	e.Parameters = req.Parameters
+	e.Outcome = audit.OutcomeAccepted
+	if err != nil {
+		e.Outcome = audit.OutcomeRejected
+		e.Reason = err.Error()
+	}
+	if job != nil {
+		e.JobID = job.ID
+	}
+	auditLog.Record(e)
+}
+
+// startStream begins the span covering delivery of a job's envelopes to
+// one client. It links to the job's span, which may belong to another
+// trace when the client is resuming.
+func startStream(ctx context.Context, job *jobs.Job) trace.Span {
+	_, span := tracing.Tracer().Start(ctx, "stream",
+		trace.WithAttributes(attribute.String("job.id", job.ID)),
+		trace.WithLinks(trace.Link{SpanContext: job.SpanContext()}),
+	)
+	return span
+}
+
+// clientID identifies the client a request came from for concurrency limits
+func clientID(r *http.Request) string {
+	return identity.ClientAddr(r)
+}
+
+// mayAccess reports whether a request from client may follow or cancel
+// job. Other users' jobs are reported as not found, so their IDs cannot
+// be probed.
+func mayAccess(ctx context.Context, client string, job *jobs.Job) bool {
+	id := identity.FromContext(ctx)
+	return id.Admin || job.OwnedBy(id, client)
+}
+
+// mayAccessRecord reports whether a request from client may read a
+// finished job's record, by the same rule as mayAccess
+func mayAccessRecord(ctx context.Context, client string, record history.Record) bool {
+	id := identity.FromContext(ctx)
+	return id.Admin || record.OwnedBy(id, client)
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err as a JSON error response
+func writeError(w http.ResponseWriter, err error) {
+	appErr, ok := err.(*errors.AppError)
+	if !ok {
+		appErr = errors.NewInternalError(err)
+	}
+	writeJSON(w, appErr.HTTPStatusCode(), map[string]string{"error": appErr.Error()})
 }
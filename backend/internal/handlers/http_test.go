@@ -0,0 +1,220 @@
+// File: backend/internal/handlers/http_test.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/audit"
+	"github.com/himbojo/net-tools-gui/backend/internal/executor"
+	"github.com/himbojo/net-tools-gui/backend/internal/history"
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/internal/jobs"
+	"github.com/himbojo/net-tools-gui/backend/internal/tracing"
+	"github.com/himbojo/net-tools-gui/backend/internal/validator"
+	"github.com/himbojo/net-tools-gui/backend/pkg/tools"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// echoTool runs echo against the target, so jobs start a real process
+// without needing network tools in the test environment
+type echoTool struct {
+	path string
+}
+
+func (t echoTool) Name() string                            { return "echo" }
+func (t echoTool) Description() string                     { return "Prints the target" }
+func (t echoTool) Parameters() []tools.Parameter           { return nil }
+func (t echoTool) Validate(params map[string]string) error { return nil }
+func (t echoTool) NewParser() tools.Parser                 { return tools.NopParser{} }
+
+func (t echoTool) Command(target string, params map[string]string) (tools.Command, error) {
+	return tools.Command{Path: t.path, Args: []string{target}}, nil
+}
+
+// newTestHTTPHandler builds a handler whose jobs run echo, with no
+// access policy and the audit log and history in a temporary directory
+func newTestHTTPHandler(t *testing.T) *HTTPHandler {
+	path, err := exec.LookPath("echo")
+	if err != nil {
+		t.Skipf("echo not available: %v", err)
+	}
+	registry := tools.NewRegistry()
+	registry.MustRegister(echoTool{path: path})
+
+	auditLog, err := audit.Open(audit.Config{Path: filepath.Join(t.TempDir(), "audit.log")})
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	t.Cleanup(func() { auditLog.Close() })
+
+	store, err := history.Open(history.Config{Path: filepath.Join(t.TempDir(), "history.db")})
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	manager := jobs.NewManager(executor.NewExecutor(registry, nil), jobs.Config{})
+	return NewHTTPHandler(registry, validator.NewValidator(registry, nil), manager, store, auditLog)
+}
+
+func TestCreateJobSpanTree(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := tracing.Setup(tracing.Config{}, exporter)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	t.Cleanup(func() { shutdown(context.Background()) })
+
+	h := newTestHTTPHandler(t)
+
+	// Stand in for the request span the server's middleware starts
+	ctx, root := tracing.Start(context.Background(), "request")
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(`{"tool":"echo","target":"127.0.0.1"}`))
+	req = req.WithContext(ctx)
+	rec := httptest.NewRecorder()
+	h.HandleCreateJob(rec, req)
+	root.End()
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	spans := exporter.GetSpans()
+	byName := make(map[string]tracetest.SpanStub)
+	for _, s := range spans {
+		if _, ok := byName[s.Name]; ok {
+			t.Errorf("span %s recorded twice", s.Name)
+		}
+		byName[s.Name] = s
+	}
+
+	// Each span and the span it should be a child of
+	parents := []struct {
+		name   string
+		parent string
+	}{
+		{"validate", "request"},
+		{"job", "request"},
+		{"execute", "job"},
+		{"process.start", "execute"},
+		{"process.first_output", "execute"},
+		{"process.wait", "execute"},
+	}
+	traceID := root.SpanContext().TraceID()
+	for _, p := range parents {
+		span, ok := byName[p.name]
+		if !ok {
+			t.Errorf("no %s span among %v", p.name, spanNames(spans))
+			continue
+		}
+		if span.SpanContext.TraceID() != traceID {
+			t.Errorf("%s span is in trace %s, want %s", p.name, span.SpanContext.TraceID(), traceID)
+		}
+		if want := byName[p.parent].SpanContext.SpanID(); span.Parent.SpanID() != want {
+			t.Errorf("%s span parent = %s, want the %s span %s", p.name, span.Parent.SpanID(), p.parent, want)
+		}
+	}
+
+	// The job outlives the request, so its span must not end before the
+	// process it ran
+	if job, wait := byName["job"], byName["process.wait"]; job.EndTime.Before(wait.EndTime) {
+		t.Errorf("job span ended at %v, before the process.wait span at %v", job.EndTime, wait.EndTime)
+	}
+}
+
+// spanNames lists the names of recorded spans for failure messages
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, len(spans))
+	for i, s := range spans {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// asUser returns r as sent by user from client, with admin rights if set
+func asUser(r *http.Request, user, client string, admin bool) *http.Request {
+	ctx := identity.NewContext(r.Context(), identity.Identity{User: user, Admin: admin})
+	return r.WithContext(identity.NewClientContext(ctx, client))
+}
+
+func TestHistoryOwnership(t *testing.T) {
+	h := newTestHTTPHandler(t)
+	created := time.Now()
+	for i, rec := range []history.Record{
+		{ID: "alice-job", User: "alice", Client: "10.0.0.1"},
+		{ID: "bob-job", User: "bob", Client: "10.0.0.1"},
+		{ID: "anon-job", Client: "10.0.0.1"},
+		{ID: "other-anon-job", Client: "10.0.0.2"},
+	} {
+		rec.CreatedAt = created.Add(time.Duration(i) * time.Second)
+		if err := h.history.Put(rec); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		user   string
+		client string
+		admin  bool
+		query  string
+		list   []string
+		denied string
+	}{
+		{"user sees own jobs", "alice", "10.0.0.1", false, "", []string{"alice-job"}, "bob-job"},
+		{"user cannot ask for another user", "alice", "10.0.0.1", false, "?user=bob", []string{"alice-job"}, "anon-job"},
+		{"anonymous client sees its own jobs", "", "10.0.0.1", false, "?client=10.0.0.2", []string{"anon-job"}, "alice-job"},
+		{"admin sees everyone", "root", "10.0.0.9", true, "", []string{"other-anon-job", "anon-job", "bob-job", "alice-job"}, ""},
+		{"admin filters by user", "root", "10.0.0.9", true, "?user=bob", []string{"bob-job"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.HandleHistory(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/v1/history"+tt.query, nil), tt.user, tt.client, tt.admin))
+			var body struct {
+				Records []history.Record `json:"records"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			ids := []string{}
+			for _, r := range body.Records {
+				ids = append(ids, r.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.list) {
+				t.Errorf("listed %v, want %v", ids, tt.list)
+			}
+
+			for _, id := range tt.list {
+				if code := getHistoryRecord(h, id, tt.user, tt.client, tt.admin); code != http.StatusOK {
+					t.Errorf("GET %s = %d, want 200", id, code)
+				}
+			}
+			if tt.denied != "" {
+				if code := getHistoryRecord(h, tt.denied, tt.user, tt.client, tt.admin); code != http.StatusNotFound {
+					t.Errorf("GET %s = %d, want 404", tt.denied, code)
+				}
+			}
+		})
+	}
+}
+
+// getHistoryRecord fetches one history record and returns the status
+func getHistoryRecord(h *HTTPHandler, id, user, client string, admin bool) int {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/history/"+id, nil)
+	r.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+	h.HandleHistoryRecord(rec, asUser(r, user, client, admin))
+	return rec.Code
+}
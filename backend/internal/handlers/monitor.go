@@ -0,0 +1,222 @@
+// File: backend/internal/handlers/monitor.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/audit"
+	"github.com/himbojo/net-tools-gui/backend/internal/errors"
+	"github.com/himbojo/net-tools-gui/backend/internal/executor"
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/internal/monitor"
+	"github.com/himbojo/net-tools-gui/backend/internal/validator"
+)
+
+// MonitorHandler handles the monitor endpoints
+type MonitorHandler struct {
+	monitors  *monitor.Manager
+	validator *validator.Validator
+	audit     *audit.Log
+}
+
+// NewMonitorHandler creates a new MonitorHandler instance
+func NewMonitorHandler(manager *monitor.Manager, val *validator.Validator, auditLog *audit.Log) *MonitorHandler {
+	return &MonitorHandler{
+		monitors:  manager,
+		validator: val,
+		audit:     auditLog,
+	}
+}
+
+// HandleList returns the caller's monitors, or every monitor for admins
+func (h *MonitorHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	monitors := []monitor.Monitor{}
+	for _, mon := range h.monitors.List() {
+		if mayAccessMonitor(r.Context(), clientID(r), mon) {
+			monitors = append(monitors, mon)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"monitors": monitors})
+}
+
+// HandleCreate creates a monitor from a name, request, schedule and
+// optional jitter
+func (h *MonitorHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	mon, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+	mon.Owner = clientID(r)
+	if user := identity.FromContext(r.Context()).User; user != "" {
+		mon.Owner = user
+	}
+
+	created, err := h.monitors.Create(mon)
+	h.record(r, created.ID, mon.Request, err)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/monitors/"+created.ID)
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// HandleGet returns a single monitor
+func (h *MonitorHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	mon, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, mon)
+}
+
+// HandleUpdate replaces a monitor's definition. Its runs are then
+// checked against the policy with the roles of the caller.
+func (h *MonitorHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.lookup(w, r); !ok {
+		return
+	}
+	mon, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := h.monitors.Update(r.PathValue("id"), mon)
+	h.record(r, r.PathValue("id"), mon.Request, err)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// HandleDelete removes a monitor and its results
+func (h *MonitorHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.lookup(w, r); !ok {
+		return
+	}
+	err := h.monitors.Delete(r.PathValue("id"))
+	h.record(r, r.PathValue("id"), executor.CommandRequest{}, err)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePause stops a monitor from running until it is resumed
+func (h *MonitorHandler) HandlePause(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, true)
+}
+
+// HandleResume restarts a paused monitor
+func (h *MonitorHandler) HandleResume(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, false)
+}
+
+// HandleResults returns a monitor's results, oldest first. Supported
+// query parameters are since and until (RFC 3339) and limit, which keeps
+// the most recent results.
+func (h *MonitorHandler) HandleResults(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.lookup(w, r); !ok {
+		return
+	}
+	q := r.URL.Query()
+
+	since, err := parseTimeParam(q.Get("since"))
+	if err != nil {
+		writeError(w, errors.NewValidationError("invalid since: "+err.Error()))
+		return
+	}
+	until, err := parseTimeParam(q.Get("until"))
+	if err != nil {
+		writeError(w, errors.NewValidationError("invalid until: "+err.Error()))
+		return
+	}
+	limit := 0
+	if s := q.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 {
+			writeError(w, errors.NewValidationError("invalid limit"))
+			return
+		}
+	}
+
+	results, err := h.monitors.Results(r.PathValue("id"), since, until, limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// setPaused pauses or resumes the monitor named in the path
+func (h *MonitorHandler) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if _, ok := h.lookup(w, r); !ok {
+		return
+	}
+	mon, err := h.monitors.SetPaused(r.PathValue("id"), paused)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, mon)
+}
+
+// lookup returns the monitor named in the path if the caller may access
+// it. Other users' monitors are reported as not found.
+func (h *MonitorHandler) lookup(w http.ResponseWriter, r *http.Request) (monitor.Monitor, bool) {
+	mon, ok := h.monitors.Get(r.PathValue("id"))
+	if !ok || !mayAccessMonitor(r.Context(), clientID(r), mon) {
+		writeError(w, errors.NewNotFoundError("monitor not found"))
+		return monitor.Monitor{}, false
+	}
+	return mon, true
+}
+
+// mayAccessMonitor reports whether a request from client may read or
+// change mon: its owner and admins may
+func mayAccessMonitor(ctx context.Context, client string, mon monitor.Monitor) bool {
+	id := identity.FromContext(ctx)
+	return id.Admin || mon.OwnedBy(id, client)
+}
+
+// decode reads and validates a monitor definition from the request body
+func (h *MonitorHandler) decode(w http.ResponseWriter, r *http.Request) (monitor.Monitor, bool) {
+	var mon monitor.Monitor
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&mon); err != nil {
+		writeError(w, errors.NewValidationError(fmt.Sprintf("invalid request body: %v", err)))
+		return mon, false
+	}
+	if err := validateCommand(r.Context(), h.validator, &mon.Request); err != nil {
+		h.record(r, r.PathValue("id"), mon.Request, err)
+		writeError(w, rejection(err))
+		return mon, false
+	}
+	// Runs are checked against the policy as the user who last defined
+	// the request
+	mon.Roles = identity.FromContext(r.Context()).Roles
+	return mon, true
+}
+
+// record audits a change to the monitor with the given ID. The monitors
+// run their requests later, so who scheduled them is recorded here.
+func (h *MonitorHandler) record(r *http.Request, id string, req executor.CommandRequest, err error) {
+	e := audit.NewEntry(r.Context(), audit.EventMonitor, audit.ChannelMonitor, clientID(r))
+	e.Method = r.Method
+	e.Path = r.URL.Path
+	e.MonitorID = id
+	e.Tool = req.Tool
+	e.Target = req.Target
+	e.Parameters = req.Parameters
+	e.Outcome = audit.OutcomeAccepted
+	if err != nil {
+		e.Outcome = audit.OutcomeRejected
+		e.Reason = err.Error()
+	}
+	h.audit.Record(e)
+}
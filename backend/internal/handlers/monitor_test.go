@@ -0,0 +1,127 @@
+// File: backend/internal/handlers/monitor_test.go
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/internal/monitor"
+)
+
+// newTestMonitorHandler builds a monitor handler over the jobs of a
+// test HTTP handler. The monitors are never started, so nothing runs.
+func newTestMonitorHandler(t *testing.T) *MonitorHandler {
+	h := newTestHTTPHandler(t)
+	monitors, err := monitor.Open(h.jobs, monitor.Config{Path: filepath.Join(t.TempDir(), "monitors.db")})
+	if err != nil {
+		t.Fatalf("open monitors: %v", err)
+	}
+	t.Cleanup(func() { monitors.Close() })
+	return NewMonitorHandler(monitors, h.validator, h.audit)
+}
+
+// monitorRequest builds a request for the monitor id sent by caller
+func monitorRequest(method, path, id, body string, caller identity.Identity) *http.Request {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.SetPathValue("id", id)
+	ctx := identity.NewContext(r.Context(), caller)
+	return r.WithContext(identity.NewClientContext(ctx, "10.0.0.1"))
+}
+
+func TestMonitorOwnership(t *testing.T) {
+	h := newTestMonitorHandler(t)
+	alice := identity.Identity{User: "alice", Roles: []string{"ops"}}
+	bob := identity.Identity{User: "bob", Roles: []string{"guest"}}
+	admin := identity.Identity{User: "root", Roles: []string{"admin"}, Admin: true}
+
+	body := `{"name":"gateway","request":{"tool":"echo","target":"127.0.0.1"},"schedule":{"interval":60}}`
+	rec := httptest.NewRecorder()
+	h.HandleCreate(rec, monitorRequest(http.MethodPost, "/api/v1/monitors", "", body, alice))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create = %d: %s", rec.Code, rec.Body)
+	}
+	var created monitor.Monitor
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	id := created.ID
+
+	endpoints := []struct {
+		name   string
+		method string
+		body   string
+		handle http.HandlerFunc
+	}{
+		{"get", http.MethodGet, "", h.HandleGet},
+		{"results", http.MethodGet, "", h.HandleResults},
+		{"update", http.MethodPut, strings.Replace(body, "gateway", "renamed", 1), h.HandleUpdate},
+		{"pause", http.MethodPost, "", h.HandlePause},
+		{"resume", http.MethodPost, "", h.HandleResume},
+		{"delete", http.MethodDelete, "", h.HandleDelete},
+	}
+
+	// Another user cannot see or change the monitor, nor its roles
+	for _, e := range endpoints {
+		rec := httptest.NewRecorder()
+		e.handle(rec, monitorRequest(e.method, "/api/v1/monitors/"+id, id, e.body, bob))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("bob %s = %d, want 404", e.name, rec.Code)
+		}
+	}
+	if mon, _ := h.monitors.Get(id); mon.Name != "gateway" || mon.Paused || !reflect.DeepEqual(mon.Roles, alice.Roles) {
+		t.Errorf("monitor changed by another user: %+v", mon)
+	}
+	if got := listMonitors(t, h, bob); len(got) != 0 {
+		t.Errorf("bob lists %v, want nothing", got)
+	}
+
+	// The owner and admins can use every endpoint except delete, which
+	// runs last
+	for _, caller := range []identity.Identity{alice, admin} {
+		for _, e := range endpoints[:len(endpoints)-1] {
+			rec := httptest.NewRecorder()
+			e.handle(rec, monitorRequest(e.method, "/api/v1/monitors/"+id, id, e.body, caller))
+			if rec.Code != http.StatusOK {
+				t.Errorf("%s %s = %d, want 200: %s", caller.User, e.name, rec.Code, rec.Body)
+			}
+		}
+		if got := listMonitors(t, h, caller); !reflect.DeepEqual(got, []string{id}) {
+			t.Errorf("%s lists %v, want %s", caller.User, got, id)
+		}
+	}
+
+	// The owner stays the same whoever last edited the monitor
+	mon, _ := h.monitors.Get(id)
+	if mon.Owner != "alice" || !reflect.DeepEqual(mon.Roles, admin.Roles) {
+		t.Errorf("after admin update owner = %s, roles = %v", mon.Owner, mon.Roles)
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleDelete(rec, monitorRequest(http.MethodDelete, "/api/v1/monitors/"+id, id, "", alice))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("alice delete = %d, want 204", rec.Code)
+	}
+}
+
+// listMonitors returns the IDs of the monitors caller is shown
+func listMonitors(t *testing.T, h *MonitorHandler, caller identity.Identity) []string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.HandleList(rec, monitorRequest(http.MethodGet, "/api/v1/monitors", "", "", caller))
+	var body struct {
+		Monitors []monitor.Monitor `json:"monitors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	ids := []string{}
+	for _, mon := range body.Monitors {
+		ids = append(ids, mon.ID)
+	}
+	return ids
+}
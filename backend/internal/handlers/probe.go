@@ -0,0 +1,178 @@
+// File: backend/internal/handlers/probe.go
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/audit"
+	"github.com/himbojo/net-tools-gui/backend/internal/executor"
+	"github.com/himbojo/net-tools-gui/backend/internal/jobs"
+	"github.com/himbojo/net-tools-gui/backend/internal/metrics"
+	"github.com/himbojo/net-tools-gui/backend/internal/monitor"
+	"github.com/himbojo/net-tools-gui/backend/internal/protocol"
+)
+
+// probeTimeoutMargin is kept back from Prometheus' scrape timeout so the
+// probe can still answer after cancelling a slow run. Short scrape
+// timeouts give up at most half of their time to the margin.
+const probeTimeoutMargin = 500 * time.Millisecond
+
+// probeGauge maps a summarized result value onto a probe metric
+type probeGauge struct {
+	value string
+	name  string
+	help  string
+	scale float64
+}
+
+// Probe metrics taken directly from result values. Times are reported by
+// the tools in milliseconds.
+var probeGauges = []probeGauge{
+	{"transmitted", "probe_packets_sent", "Number of packets sent.", 1},
+	{"received", "probe_packets_received", "Number of packets received.", 1},
+	{"loss", "probe_packet_loss_ratio", "Fraction of packets lost.", 0.01},
+	{"query_time", "probe_dns_query_time_seconds", "DNS query time in seconds.", 0.001},
+	{"answers", "probe_dns_answer_rrs", "Number of records in the DNS answer section.", 1},
+	{"hops", "probe_traceroute_hops", "Number of hops to the target.", 1},
+	{"status_code", "probe_http_status_code", "HTTP response status code.", 1},
+	{"redirects", "probe_http_redirects", "Number of HTTP redirects followed.", 1},
+	{"days_to_expiry", "probe_tls_days_to_expiry", "Days until the leaf certificate expires.", 1},
+	{"chain_valid", "probe_tls_chain_valid", "Whether the certificate chain verified.", 1},
+	{"hostname_valid", "probe_tls_hostname_valid", "Whether the certificate matches the host name.", 1},
+}
+
+// Probe metrics with a label per result value
+var (
+	probeRTTStats   = []string{"min", "avg", "max", "mdev"}
+	probeHTTPPhases = []string{"dns", "connect", "tls", "ttfb", "total"}
+)
+
+// HandleProbe runs a tool synchronously and reports the outcome as
+// Prometheus probe metrics, in the style of blackbox_exporter. The tool
+// and target come from the query string and any other query parameters
+// become tool parameters. Requests pass through the validator, the job
+// limits and the rate limiter like any other run; a failed probe still
+// returns 200 with probe_success 0.
+func (h *HTTPHandler) HandleProbe(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := executor.CommandRequest{
+		Tool:       q.Get("tool"),
+		Target:     q.Get("target"),
+		Parameters: make(map[string]string),
+	}
+	for name, values := range q {
+		if name != "tool" && name != "target" && len(values) > 0 {
+			req.Parameters[name] = values[0]
+		}
+	}
+
+	if err := validateCommand(r.Context(), h.validator, &req); err != nil {
+		logRejected(r.Context(), req, err)
+		auditRequest(r.Context(), h.audit, audit.ChannelProbe, clientID(r), req, nil, err)
+		appErr := rejection(err)
+		metrics.RecordError(req.Tool, appErr)
+		writeError(w, appErr)
+		return
+	}
+
+	job, err := h.jobs.Submit(r.Context(), req, clientID(r))
+	auditRequest(r.Context(), h.audit, audit.ChannelProbe, clientID(r), req, job, err)
+	if err != nil {
+		logRejected(r.Context(), req, err)
+		metrics.RecordError(req.Tool, err)
+		writeError(w, err)
+		return
+	}
+
+	// The run may outlast the server's write timeout
+	extendWriteDeadline(w)
+
+	ctx := r.Context()
+	if seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64); err == nil && seconds > 0 {
+		scrape := time.Duration(seconds * float64(time.Second))
+		timeout := scrape - probeTimeoutMargin
+		if timeout < scrape/2 {
+			timeout = scrape / 2
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	select {
+	case <-job.Done():
+	case <-ctx.Done():
+		job.Cancel()
+		<-job.Done()
+	}
+
+	registry := metrics.NewRegistry()
+	probeMetrics(registry, job.Snapshot())
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	registry.WriteText(w)
+}
+
+// probeMetrics fills a registry with the metrics of a finished probe
+func probeMetrics(registry *metrics.Registry, s jobs.Snapshot) {
+	values, labels := monitor.Summarize(s.Events)
+
+	registry.NewGauge("probe_success", "Whether the probe succeeded.").Set(boolGauge(probeSucceeded(s, values, labels)))
+	registry.NewGauge("probe_duration_seconds", "How long the probe took in seconds.").Set(float64(s.DurationMs) / 1000)
+
+	for _, g := range probeGauges {
+		if v, ok := values[g.value]; ok {
+			registry.NewGauge(g.name, g.help).Set(v * g.scale)
+		}
+	}
+	if _, ok := values["rtt_avg"]; ok {
+		rtt := registry.NewGauge("probe_rtt_seconds", "Round trip time statistics in seconds.", "stat")
+		for _, stat := range probeRTTStats {
+			rtt.Set(values["rtt_"+stat]/1000, stat)
+		}
+	}
+	if _, ok := values["total"]; ok {
+		phases := registry.NewGauge("probe_http_duration_seconds", "Duration of each HTTP request phase in seconds.", "phase")
+		for _, phase := range probeHTTPPhases {
+			phases.Set(values[phase]/1000, phase)
+		}
+	}
+	if rcode, ok := labels["rcode"]; ok {
+		registry.NewGauge("probe_dns_rcode", "DNS response code of the answer.", "rcode").Set(1, rcode)
+	}
+}
+
+// probeSucceeded reports whether the run completed and its results show
+// the target healthy: replies received, a NOERROR answer, a 2xx
+// response or a valid certificate, depending on the tool
+func probeSucceeded(s jobs.Snapshot, values map[string]float64, labels map[string]string) bool {
+	if s.Status != protocol.StatusSuccess {
+		return false
+	}
+	if v, ok := values["received"]; ok && v == 0 {
+		return false
+	}
+	if rcode, ok := labels["rcode"]; ok && rcode != "NOERROR" {
+		return false
+	}
+	if v, ok := values["status_code"]; ok && (v < 200 || v > 299) {
+		return false
+	}
+	if v, ok := values["chain_valid"]; ok && v == 0 {
+		return false
+	}
+	if v, ok := values["hostname_valid"]; ok && v == 0 {
+		return false
+	}
+	return true
+}
+
+// boolGauge converts a boolean into a 0 or 1 sample
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -4,43 +4,81 @@ package handlers
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/himbojo/net-tools-gui/backend/internal/audit"
 	"github.com/himbojo/net-tools-gui/backend/internal/executor"
+	"github.com/himbojo/net-tools-gui/backend/internal/jobs"
+	"github.com/himbojo/net-tools-gui/backend/internal/logger"
+	"github.com/himbojo/net-tools-gui/backend/internal/metrics"
+	"github.com/himbojo/net-tools-gui/backend/internal/protocol"
+	"github.com/himbojo/net-tools-gui/backend/internal/tracing"
 	"github.com/himbojo/net-tools-gui/backend/internal/validator"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// Client message types
+const (
+	messageRun    = "run"
+	messageCancel = "cancel"
+	messageResume = "resume"
+)
+
+// wsMessage is a message received from a WebSocket client. Messages
+// without a type are treated as run requests.
+type wsMessage struct {
+	Type    string `json:"type"`
+	JobID   string `json:"jobId"`
+	LastSeq uint64 `json:"lastSeq"`
+	executor.CommandRequest
+}
+
+// wsClient tracks the state of a single WebSocket connection. Its
+// context carries the span of the upgrade request, which lasts as long
+// as the connection.
+type wsClient struct {
+	conn      *websocket.Conn
+	ctx       context.Context
+	id        string
+	envelopes bool
+	writeMu   sync.Mutex
+	jobsMu    sync.Mutex
+	running   map[string]*jobs.Job
+}
+
 // WSHandler handles WebSocket connections
 type WSHandler struct {
 	upgrader       websocket.Upgrader
-	executor       *executor.CommandExecutor
+	jobs           *jobs.Manager
 	validator      *validator.Validator
-	activeClients  map[*websocket.Conn]bool
+	audit          *audit.Log
+	activeClients  map[*websocket.Conn]*wsClient
 	clientsMutex   sync.RWMutex
-	maxConcurrent  int
 	writeTimeout   time.Duration
 	messageTimeout time.Duration
 }
 
 // NewWSHandler creates a new WSHandler instance
-func NewWSHandler(exec *executor.CommandExecutor, val *validator.Validator) *WSHandler {
+func NewWSHandler(manager *jobs.Manager, val *validator.Validator, auditLog *audit.Log) *WSHandler {
 	return &WSHandler{
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
+			Subprotocols:    []string{protocol.V1},
 			CheckOrigin: func(r *http.Request) bool {
 				// TODO: Implement proper origin checking
 				return true
 			},
 		},
-		executor:       exec,
+		jobs:           manager,
 		validator:      val,
-		activeClients:  make(map[*websocket.Conn]bool),
-		maxConcurrent:  5,
+		audit:          auditLog,
+		activeClients:  make(map[*websocket.Conn]*wsClient),
 		writeTimeout:   10 * time.Second,
 		messageTimeout: 60 * time.Second,
 	}
@@ -51,20 +89,31 @@ func (h *WSHandler) HandleConnection(w http.ResponseWriter, r *http.Request) {
 	// Upgrade HTTP connection to WebSocket
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		slog.WarnContext(r.Context(), "websocket upgrade failed", "error", err)
 		return
 	}
 
+	client := &wsClient{
+		conn:      conn,
+		ctx:       r.Context(),
+		id:        clientID(r),
+		envelopes: conn.Subprotocol() == protocol.V1,
+		running:   make(map[string]*jobs.Job),
+	}
+
 	// Register client
 	h.clientsMutex.Lock()
-	h.activeClients[conn] = true
+	h.activeClients[conn] = client
 	h.clientsMutex.Unlock()
+	metrics.WebSocketConnections.Inc()
 
 	// Ensure cleanup on disconnect
 	defer func() {
 		h.clientsMutex.Lock()
 		delete(h.activeClients, conn)
 		h.clientsMutex.Unlock()
+		metrics.WebSocketConnections.Dec()
+		client.cancelAll()
 		conn.Close()
 	}()
 
@@ -73,74 +122,236 @@ func (h *WSHandler) HandleConnection(w http.ResponseWriter, r *http.Request) {
 
 	// Process incoming messages
 	for {
-		var cmdReq executor.CommandRequest
-		err := conn.ReadJSON(&cmdReq)
+		var msg wsMessage
+		err := conn.ReadJSON(&msg)
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket read error: %v", err)
+				slog.WarnContext(client.ctx, "websocket read failed", "error", err)
 			}
 			break
 		}
 
-		// Validate request
-		if err := h.validator.ValidateCommand(cmdReq.Tool, cmdReq.Target, cmdReq.Parameters); err != nil {
-			h.sendError(conn, "validation error", err.Error())
-			continue
+		switch msg.Type {
+		case "", messageRun:
+			h.startJob(client, msg.CommandRequest)
+		case messageCancel:
+			job, ok := h.jobs.Get(msg.JobID)
+			if !ok || !mayAccess(client.ctx, client.id, job) {
+				h.sendError(client, "cancel error", "unknown job: "+msg.JobID)
+				break
+			}
+			job.Cancel()
+		case messageResume:
+			h.resumeJob(client, msg.JobID, msg.LastSeq)
+		default:
+			h.sendError(client, "message error", "unknown message type: "+msg.Type)
 		}
 
-		// Create output channel and context
-		outputChan := make(chan executor.CommandResult)
-		ctx, cancel := context.WithTimeout(context.Background(), h.messageTimeout)
+		// Reset read deadline for next message
+		conn.SetReadDeadline(time.Now().Add(h.messageTimeout))
+	}
+}
 
-		// Execute command
-		go func() {
-			defer cancel()
-			h.executor.Execute(ctx, cmdReq.Tool, cmdReq.Target, cmdReq.Parameters, outputChan)
-		}()
+// startJob validates a request and runs it as a new job
+func (h *WSHandler) startJob(client *wsClient, cmdReq executor.CommandRequest) {
+	ctx, span := tracing.Start(client.ctx, "ws.run",
+		attribute.String("tool", cmdReq.Tool),
+		attribute.String("target", cmdReq.Target),
+	)
+	defer span.End()
 
-		// Stream results back to client
-		go h.streamResults(conn, outputChan, ctx)
+	// Validate request
+	if err := validateCommand(ctx, h.validator, &cmdReq); err != nil {
+		tracing.Fail(span, err)
+		logRejected(ctx, cmdReq, err)
+		auditRequest(ctx, h.audit, audit.ChannelWebSocket, client.id, cmdReq, nil, err)
+		metrics.RecordError(cmdReq.Tool, rejection(err))
+		h.sendError(client, "validation error", err.Error())
+		return
+	}
 
-		// Reset read deadline for next message
-		conn.SetReadDeadline(time.Now().Add(h.messageTimeout))
+	job, err := h.jobs.Submit(ctx, cmdReq, client.id)
+	auditRequest(ctx, h.audit, audit.ChannelWebSocket, client.id, cmdReq, job, err)
+	if err != nil {
+		tracing.Fail(span, err)
+		logRejected(ctx, cmdReq, err)
+		metrics.RecordError(cmdReq.Tool, err)
+		h.sendError(client, "queue error", err.Error())
+		return
 	}
+	span.SetAttributes(attribute.String("job.id", job.ID))
+	sub := job.Subscribe(0)
+
+	if client.envelopes {
+		go h.streamEnvelopes(ctx, client, job, sub, 0)
+		return
+	}
+
+	// Legacy clients cannot resume, so their jobs end with the connection
+	client.addJob(job)
+
+	// Acknowledge the job so the client learns its ID
+	h.send(client, executor.CommandResult{
+		JobID:     job.ID,
+		Tool:      cmdReq.Tool,
+		Target:    cmdReq.Target,
+		StartTime: job.Created,
+	})
+
+	go func() {
+		defer client.removeJob(job.ID)
+		h.streamResults(ctx, client, job, sub)
+	}()
 }
 
-// streamResults sends command results back to the client
-func (h *WSHandler) streamResults(conn *websocket.Conn, outputChan chan executor.CommandResult, ctx context.Context) {
+// resumeJob streams a job's envelopes after lastSeq to a reconnected client
+func (h *WSHandler) resumeJob(client *wsClient, jobID string, lastSeq uint64) {
+	if !client.envelopes {
+		h.sendError(client, "resume error", "resume requires the "+protocol.V1+" subprotocol")
+		return
+	}
+
+	job, ok := h.jobs.Get(jobID)
+	if !ok || !mayAccess(client.ctx, client.id, job) {
+		h.sendError(client, "resume error", "unknown job: "+jobID)
+		return
+	}
+	go h.streamEnvelopes(client.ctx, client, job, job.Subscribe(lastSeq), lastSeq)
+}
+
+// streamEnvelopes sends a job's envelopes after lastSeq until the job
+// finishes, catching up from the job's buffer if the client falls
+// behind. The job keeps running if the client goes away, so that it can
+// be resumed from another connection.
+func (h *WSHandler) streamEnvelopes(ctx context.Context, client *wsClient, job *jobs.Job, sub *jobs.Subscription, lastSeq uint64) {
+	span := startStream(ctx, job)
+	defer func() {
+		span.SetAttributes(attribute.Int64("stream.last_seq", int64(lastSeq)))
+		span.End()
+	}()
+
 	for {
-		select {
-		case <-ctx.Done():
-			return
-		case result, ok := <-outputChan:
-			if !ok {
-				return
+		if sub.Missed {
+			h.sendError(client, "resume gap", fmt.Sprintf("output of job %s after seq %d is no longer available", job.ID, lastSeq))
+		}
+
+		for _, env := range sub.Replay {
+			if env.Seq <= lastSeq {
+				continue
 			}
-			h.clientsMutex.RLock()
-			if !h.activeClients[conn] {
-				h.clientsMutex.RUnlock()
+			if h.send(client, env) != nil {
+				sub.Close()
 				return
 			}
-			h.clientsMutex.RUnlock()
+			lastSeq = env.Seq
+		}
 
-			conn.SetWriteDeadline(time.Now().Add(h.writeTimeout))
-			if err := conn.WriteJSON(result); err != nil {
-				log.Printf("WebSocket write error: %v", err)
+		for update := range sub.Updates {
+			for _, env := range update.Envelopes {
+				if h.send(client, env) != nil {
+					sub.Close()
+					return
+				}
+				lastSeq = env.Seq
+			}
+		}
+
+		select {
+		case <-job.Done():
+			// Anything published after the channel closed is in the buffer
+			sub = job.Subscribe(lastSeq)
+			if len(sub.Replay) == 0 {
 				return
 			}
+		default:
+			sub = job.Subscribe(lastSeq)
+		}
+	}
+}
+
+// streamResults sends job results to a legacy client until the job
+// finishes, cancelling it if the client can no longer be written to
+func (h *WSHandler) streamResults(ctx context.Context, client *wsClient, job *jobs.Job, sub *jobs.Subscription) {
+	defer sub.Close()
+
+	span := startStream(ctx, job)
+	defer span.End()
+
+	for update := range sub.Updates {
+		if update.Result == nil {
+			continue
+		}
+		if err := h.send(client, *update.Result); err != nil {
+			tracing.Fail(span, err)
+			slog.WarnContext(logger.With(ctx, logger.KeyJobID, job.ID), "websocket write failed", "error", err)
+			job.Cancel()
+			return
 		}
 	}
+
+	select {
+	case <-job.Done():
+	default:
+		h.sendError(client, "stream error", "client fell behind; output for job "+job.ID+" was dropped")
+	}
+}
+
+// send writes a message to the client if it is still connected
+func (h *WSHandler) send(client *wsClient, v interface{}) error {
+	h.clientsMutex.RLock()
+	active := h.activeClients[client.conn] == client
+	h.clientsMutex.RUnlock()
+	if !active {
+		return websocket.ErrCloseSent
+	}
+	return client.writeJSON(v, h.writeTimeout)
 }
 
 // sendError sends an error message to the client
-func (h *WSHandler) sendError(conn *websocket.Conn, errType, message string) {
-	conn.SetWriteDeadline(time.Now().Add(h.writeTimeout))
-	err := conn.WriteJSON(map[string]string{
+func (h *WSHandler) sendError(client *wsClient, errType, message string) {
+	var msg interface{} = map[string]string{
 		"error":     errType,
 		"message":   message,
 		"timestamp": time.Now().Format(time.RFC3339),
-	})
+	}
+	if client.envelopes {
+		msg = protocol.NewError(errType, message)
+	}
+
+	err := client.writeJSON(msg, h.writeTimeout)
 	if err != nil {
-		log.Printf("Error sending error message: %v", err)
+		slog.WarnContext(client.ctx, "failed to send error message", "error", err)
+	}
+}
+
+// writeJSON serialises writes to the connection
+func (c *wsClient) writeJSON(v interface{}, timeout time.Duration) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(timeout))
+	return c.conn.WriteJSON(v)
+}
+
+// addJob records a job started by the client
+func (c *wsClient) addJob(job *jobs.Job) {
+	c.jobsMu.Lock()
+	defer c.jobsMu.Unlock()
+	c.running[job.ID] = job
+}
+
+// removeJob forgets a finished job
+func (c *wsClient) removeJob(jobID string) {
+	c.jobsMu.Lock()
+	defer c.jobsMu.Unlock()
+	delete(c.running, jobID)
+}
+
+// cancelAll cancels every running job of the client
+func (c *wsClient) cancelAll() {
+	c.jobsMu.Lock()
+	defer c.jobsMu.Unlock()
+	for _, job := range c.running {
+		job.Cancel()
 	}
 }
@@ -0,0 +1,318 @@
+// File: backend/internal/history/history.go
+
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/internal/jobs"
+	"github.com/himbojo/net-tools-gui/backend/internal/logger"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket names
+var (
+	recordsBucket = []byte("records")
+	idsBucket     = []byte("ids")
+)
+
+// Query limits
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Config holds history store settings. Zero values select the defaults.
+type Config struct {
+	Path           string        `json:"path"`
+	MaxAge         time.Duration `json:"maxAge"`
+	MaxRecords     int           `json:"maxRecords"`
+	PruneInterval  time.Duration `json:"pruneInterval"`
+	MaxOutputLines int           `json:"maxOutputLines"`
+}
+
+// withDefaults fills in unset settings
+func (c Config) withDefaults() Config {
+	if c.Path == "" {
+		c.Path = "data/history.db"
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = 30 * 24 * time.Hour
+	}
+	if c.MaxRecords <= 0 {
+		c.MaxRecords = 100000
+	}
+	if c.PruneInterval <= 0 {
+		c.PruneInterval = time.Hour
+	}
+	if c.MaxOutputLines <= 0 {
+		c.MaxOutputLines = 5000
+	}
+	return c
+}
+
+// Record is a finished job as stored in the history, including the
+// user and roles of its submitter
+type Record = jobs.Snapshot
+
+// Filter selects records from the history. Empty fields match anything.
+// User and Role match the submitter's identity, Client its address.
+// Anonymous keeps only records submitted without a user.
+type Filter struct {
+	Tool      string
+	Target    string
+	User      string
+	Role      string
+	Client    string
+	Anonymous bool
+	Status    string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// Store persists job records in an embedded database
+type Store struct {
+	db     *bolt.DB
+	config Config
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// Open opens or creates the history database and starts pruning it
+func Open(config Config) (*Store, error) {
+	config = config.withDefaults()
+
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %v", err)
+	}
+	db, err := bolt.Open(config.Path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{recordsBucket, idsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise history database: %v", err)
+	}
+
+	s := &Store{db: db, config: config, stop: make(chan struct{})}
+	s.wg.Add(1)
+	go s.pruneLoop()
+	return s, nil
+}
+
+// Close stops pruning and closes the database
+func (s *Store) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+	return s.db.Close()
+}
+
+// Record stores a finished job, logging rather than returning failures
+// so that it can be used as a jobs.Manager observer
+func (s *Store) Record(rec Record) {
+	if err := s.Put(rec); err != nil {
+		slog.Error("failed to store job in history",
+			logger.KeyJobID, rec.ID,
+			logger.KeyTraceID, rec.TraceID,
+			"error", err,
+		)
+	}
+}
+
+// Put stores a record, replacing any earlier record for the same job
+func (s *Store) Put(rec Record) error {
+	if n := s.config.MaxOutputLines; len(rec.Output) > n {
+		rec.Output = append(rec.Output[:n:n], fmt.Sprintf("... %d more lines not stored", len(rec.Output)-n))
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	key := recordKey(rec.CreatedAt, rec.ID)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		ids := tx.Bucket(idsBucket)
+		records := tx.Bucket(recordsBucket)
+		if old := ids.Get([]byte(rec.ID)); old != nil {
+			if err := records.Delete(old); err != nil {
+				return err
+			}
+		}
+		if err := records.Put(key, data); err != nil {
+			return err
+		}
+		return ids.Put([]byte(rec.ID), key)
+	})
+}
+
+// Get returns the record for a job
+func (s *Store) Get(id string) (Record, bool, error) {
+	var (
+		rec   Record
+		found bool
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket(idsBucket).Get([]byte(id))
+		if key == nil {
+			return nil
+		}
+		data := tx.Bucket(recordsBucket).Get(key)
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &rec)
+	})
+	return rec, found, err
+}
+
+// Query returns matching records, newest first
+func (s *Store) Query(f Filter) ([]Record, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	records := []Record{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(recordsBucket).Cursor()
+
+		// Keys sort by creation time, so walk backwards from the upper bound
+		var k, v []byte
+		if f.Until.IsZero() {
+			k, v = c.Last()
+		} else {
+			if k, _ = c.Seek(recordKey(f.Until, "\xff")); k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		}
+
+		lower := recordKey(f.Since, "")
+		for ; k != nil && len(records) < limit; k, v = c.Prev() {
+			if !f.Since.IsZero() && bytes.Compare(k, lower) < 0 {
+				break
+			}
+			var rec Record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			if f.matches(rec) {
+				records = append(records, rec)
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+// Prune applies the retention policy, returning how many records it removed
+func (s *Store) Prune() (int, error) {
+	removed := 0
+	cutoff := recordKey(time.Now().Add(-s.config.MaxAge), "")
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(recordsBucket)
+		ids := tx.Bucket(idsBucket)
+		excess := records.Stats().KeyN - s.config.MaxRecords
+
+		c := records.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.First() {
+			if bytes.Compare(k, cutoff) >= 0 && excess <= 0 {
+				break
+			}
+			var rec Record
+			if err := json.Unmarshal(v, &rec); err == nil {
+				if err := ids.Delete([]byte(rec.ID)); err != nil {
+					return err
+				}
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			excess--
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// pruneLoop applies the retention policy periodically until Close
+func (s *Store) pruneLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Prune(); err != nil {
+			slog.Error("failed to prune history", "error", err)
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// matches reports whether a record passes the filter's field checks
+func (f Filter) matches(rec Record) bool {
+	if f.Tool != "" && rec.Tool != f.Tool {
+		return false
+	}
+	if f.Target != "" && !strings.Contains(strings.ToLower(rec.Target), strings.ToLower(f.Target)) {
+		return false
+	}
+	if f.User != "" && rec.User != f.User {
+		return false
+	}
+	if f.Role != "" && !(identity.Identity{Roles: rec.Roles}).HasRole(f.Role) {
+		return false
+	}
+	if f.Client != "" && rec.Client != f.Client {
+		return false
+	}
+	if f.Anonymous && rec.User != "" {
+		return false
+	}
+	if f.Status != "" && rec.Status != f.Status {
+		return false
+	}
+	return true
+}
+
+// recordKey orders records by creation time, then job ID
+func recordKey(t time.Time, id string) []byte {
+	key := make([]byte, 8, 8+len(id))
+	if !t.IsZero() {
+		binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	}
+	return append(key, id...)
+}
@@ -0,0 +1,229 @@
+// File: backend/internal/identity/identity.go
+
+// Package identity works out who made a request. The server does not
+// authenticate users itself; it trusts an authenticating reverse proxy
+// to pass the user and their roles in headers, and only believes those
+// headers on connections from the proxy's addresses. The same proxies
+// are believed about the address of the client behind them.
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/logger"
+)
+
+// Config names the headers set by the authenticating proxy. Identity
+// headers are ignored unless UserHeader is set and the request comes
+// from one of TrustedProxies. Forwarded and X-Forwarded-For are only
+// read from TrustedProxies.
+type Config struct {
+	UserHeader  string `json:"userHeader"`
+	RolesHeader string `json:"rolesHeader"`
+	// TrustedProxies lists the addresses or CIDR ranges of the proxy
+	TrustedProxies []string `json:"trustedProxies"`
+	// AdminRoles lists roles whose holders may follow and cancel jobs
+	// submitted by anyone
+	AdminRoles []string `json:"adminRoles,omitempty"`
+}
+
+// Identity is the authenticated user behind a request. Admin is set
+// when the user holds one of the configured admin roles.
+type Identity struct {
+	User  string   `json:"user,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+	Admin bool     `json:"-"`
+}
+
+// HasRole reports whether the identity holds role
+func (id Identity) HasRole(role string) bool {
+	for _, r := range id.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// contextKey is the context key of a request's identity
+type contextKey struct{}
+
+// FromContext returns the identity attached to ctx, which is empty for
+// unauthenticated requests
+func FromContext(ctx context.Context) Identity {
+	id, _ := ctx.Value(contextKey{}).(Identity)
+	return id
+}
+
+// NewContext returns ctx with id attached
+func NewContext(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// clientKey is the context key of a request's client address
+type clientKey struct{}
+
+// ClientFromContext returns the client address attached to ctx, or ""
+// if there is none
+func ClientFromContext(ctx context.Context) string {
+	addr, _ := ctx.Value(clientKey{}).(string)
+	return addr
+}
+
+// NewClientContext returns ctx with the client address addr attached
+func NewClientContext(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, clientKey{}, addr)
+}
+
+// ClientAddr returns the address of the client behind a request: the
+// one the Middleware found, or else the host of its remote address
+func ClientAddr(req *http.Request) string {
+	if addr := ClientFromContext(req.Context()); addr != "" {
+		return addr
+	}
+	return hostOf(req.RemoteAddr)
+}
+
+// Resolver reads identities from trusted proxy headers
+type Resolver struct {
+	config  Config
+	proxies []*net.IPNet
+}
+
+// NewResolver creates a new Resolver instance
+func NewResolver(config Config) (*Resolver, error) {
+	r := &Resolver{config: config}
+	for _, p := range config.TrustedProxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+		}
+		r.proxies = append(r.proxies, network)
+	}
+	return r, nil
+}
+
+// Identify returns the identity asserted for a request, if it came
+// through a trusted proxy
+func (r *Resolver) Identify(req *http.Request) Identity {
+	if r.config.UserHeader == "" || !r.trusted(req.RemoteAddr) {
+		return Identity{}
+	}
+
+	id := Identity{User: strings.TrimSpace(req.Header.Get(r.config.UserHeader))}
+	if id.User == "" {
+		return Identity{}
+	}
+	if r.config.RolesHeader != "" {
+		for _, role := range strings.Split(req.Header.Get(r.config.RolesHeader), ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				id.Roles = append(id.Roles, role)
+			}
+		}
+	}
+	for _, role := range r.config.AdminRoles {
+		if id.HasRole(role) {
+			id.Admin = true
+			break
+		}
+	}
+	return id
+}
+
+// Client returns the address of the client that made a request. A
+// request from a trusted proxy is attributed to the last address in its
+// forwarding chain that is not itself a trusted proxy, since earlier
+// entries are whatever the client chose to send.
+func (r *Resolver) Client(req *http.Request) string {
+	peer := hostOf(req.RemoteAddr)
+	if !r.trusted(peer) {
+		return peer
+	}
+
+	hops := forwardedFor(req.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !r.trusted(hops[i]) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		return hops[0]
+	}
+	return peer
+}
+
+// forwardedFor returns the client addresses in a request's Forwarded
+// header, or in X-Forwarded-For if it has none, nearest client first
+func forwardedFor(h http.Header) []string {
+	var hops []string
+	for _, line := range h.Values("Forwarded") {
+		for _, element := range strings.Split(line, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					hops = append(hops, hostOf(strings.Trim(value, `"`)))
+				}
+			}
+		}
+	}
+	if len(hops) > 0 {
+		return hops
+	}
+
+	for _, line := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(line, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hostOf(hop))
+			}
+		}
+	}
+	return hops
+}
+
+// hostOf strips any port and brackets from an address
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
+
+// trusted reports whether addr belongs to a trusted proxy
+func (r *Resolver) trusted(addr string) bool {
+	ip := net.ParseIP(hostOf(addr))
+	if ip == nil {
+		return false
+	}
+	for _, network := range r.proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware attaches each request's identity and client address to its
+// context and log fields. It hands on a copy of the request, so it must
+// wrap every middleware that reads the route pattern the mux records.
+func (r *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := r.Identify(req)
+		ctx := NewContext(req.Context(), id)
+		ctx = NewClientContext(ctx, r.Client(req))
+		if id.User != "" {
+			ctx = logger.With(ctx, logger.KeyUser, id.User)
+		}
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
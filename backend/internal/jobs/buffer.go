@@ -0,0 +1,45 @@
+// File: backend/internal/jobs/buffer.go
+
+package jobs
+
+import (
+	"github.com/himbojo/net-tools-gui/backend/internal/protocol"
+)
+
+// envelopeBuffer is a ring buffer holding the most recent envelopes of
+// a job so that clients can replay what they missed
+type envelopeBuffer struct {
+	items []protocol.Envelope
+	start int
+	count int
+}
+
+// newEnvelopeBuffer creates a new envelopeBuffer holding up to size envelopes
+func newEnvelopeBuffer(size int) *envelopeBuffer {
+	return &envelopeBuffer{items: make([]protocol.Envelope, size)}
+}
+
+// add appends an envelope, evicting the oldest one when full
+func (b *envelopeBuffer) add(env protocol.Envelope) {
+	if b.count < len(b.items) {
+		b.items[(b.start+b.count)%len(b.items)] = env
+		b.count++
+		return
+	}
+	b.items[b.start] = env
+	b.start = (b.start + 1) % len(b.items)
+}
+
+// since returns the buffered envelopes with a sequence number above seq
+// and reports whether any envelopes after seq have already been evicted
+func (b *envelopeBuffer) since(seq uint64) ([]protocol.Envelope, bool) {
+	var envs []protocol.Envelope
+	for i := 0; i < b.count; i++ {
+		env := b.items[(b.start+i)%len(b.items)]
+		if env.Seq > seq {
+			envs = append(envs, env)
+		}
+	}
+	missed := b.count > 0 && b.items[b.start].Seq > seq+1
+	return envs, missed
+}
@@ -0,0 +1,396 @@
+// File: backend/internal/jobs/job.go
+
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/executor"
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/internal/logger"
+	"github.com/himbojo/net-tools-gui/backend/internal/protocol"
+	"github.com/himbojo/net-tools-gui/backend/internal/tracing"
+	"github.com/himbojo/net-tools-gui/backend/pkg/tools"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Statuses of jobs that have not finished yet. Finished jobs take the
+// status of their terminal envelope.
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+)
+
+// subscriberBuffer is how many updates a subscriber may fall behind
+// before it is dropped
+const subscriberBuffer = 256
+
+// Update is what a job publishes for each executor result: the raw
+// result for legacy clients and its envelopes for everyone else
+type Update struct {
+	Result    *executor.CommandResult
+	Envelopes []protocol.Envelope
+}
+
+// Subscription delivers a job's envelopes to one client
+type Subscription struct {
+	// Replay holds the buffered envelopes after the requested sequence
+	Replay []protocol.Envelope
+	// Missed is set when envelopes after the requested sequence have
+	// already been evicted from the buffer
+	Missed bool
+	// Updates receives later updates. It is closed when the job finishes
+	// or when the subscriber falls too far behind.
+	Updates <-chan Update
+
+	close func()
+}
+
+// Close stops delivery of updates
+func (s *Subscription) Close() {
+	s.close()
+}
+
+// Snapshot is the state of a job at a point in time
+type Snapshot struct {
+	ID            string            `json:"id"`
+	Client        string            `json:"client"`
+	User          string            `json:"user,omitempty"`
+	Roles         []string          `json:"roles,omitempty"`
+	Tool          string            `json:"tool"`
+	Target        string            `json:"target"`
+	Parameters    map[string]string `json:"parameters,omitempty"`
+	Status        string            `json:"status"`
+	QueuePosition int               `json:"queuePosition,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Output        []string          `json:"output"`
+	Stderr        []string          `json:"stderr,omitempty"`
+	Events        []tools.Event     `json:"events"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	StartTime     *time.Time        `json:"startTime,omitempty"`
+	EndTime       *time.Time        `json:"endTime,omitempty"`
+	DurationMs    int64             `json:"durationMs"`
+	TraceID       string            `json:"traceId,omitempty"`
+}
+
+// OwnedBy reports whether a request from client by id comes from the
+// submitter of the job the snapshot was taken of
+func (s Snapshot) OwnedBy(id identity.Identity, client string) bool {
+	return ownedBy(s.User, s.Client, id, client)
+}
+
+// ownedBy compares a job's submitter with a requester: by user when the
+// job was submitted by one, otherwise by client address
+func ownedBy(user, client string, id identity.Identity, requester string) bool {
+	if user != "" {
+		return id.User == user
+	}
+	return id.User == "" && requester == client
+}
+
+// Job is a single tool execution tracked by the Manager
+type Job struct {
+	ID       string
+	Client   string
+	Identity identity.Identity
+	Request  executor.CommandRequest
+	Created  time.Time
+	TraceID  string
+
+	ctx     context.Context
+	span    trace.Span
+	cancel  context.CancelFunc
+	started chan struct{}
+	done    chan struct{}
+
+	mu        sync.Mutex
+	queueSpan trace.Span
+	stream    *protocol.Stream
+	status    string
+	position  int
+	lastError string
+	output    []string
+	stderr    []string
+	events    []tools.Event
+	buffer    *envelopeBuffer
+	startTime time.Time
+	ended     time.Time
+	subs      map[chan Update]struct{}
+}
+
+// newJob creates a new Job instance. The job's span is a child of any
+// span in parent and its log fields extend parent's, but the job does
+// not inherit parent's cancellation.
+func newJob(parent context.Context, id, client string, req executor.CommandRequest, bufferSize int) *Job {
+	ctx, span := tracing.Start(context.WithoutCancel(parent), "job",
+		attribute.String("job.id", id),
+		attribute.String("job.client", client),
+		attribute.String("tool", req.Tool),
+		attribute.String("target", req.Target),
+	)
+	ctx = logger.With(ctx,
+		logger.KeyJobID, id,
+		logger.KeyClientID, client,
+		logger.KeyTool, req.Tool,
+		logger.KeyTarget, req.Target,
+	)
+	ctx, cancel := context.WithCancel(ctx)
+	traceID := tracing.TraceID(ctx)
+	j := &Job{
+		ID:       id,
+		Client:   client,
+		Identity: identity.FromContext(parent),
+		Request:  req,
+		Created:  time.Now(),
+		TraceID:  traceID,
+		ctx:      ctx,
+		span:     span,
+		cancel:   cancel,
+		started:  make(chan struct{}),
+		done:     make(chan struct{}),
+		stream:   protocol.NewStream(id, traceID),
+		buffer:   newEnvelopeBuffer(bufferSize),
+		status:   StatusQueued,
+		output:   []string{},
+		events:   []tools.Event{},
+		subs:     make(map[chan Update]struct{}),
+	}
+	j.buffer.add(j.stream.Started(req))
+	return j
+}
+
+// Done returns a channel that is closed when the job has finished
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Cancel stops the job if it is still queued or running
+func (j *Job) Cancel() {
+	j.cancel()
+}
+
+// OwnedBy reports whether a request from client by id comes from the
+// job's submitter: the same user, or the same client address when the
+// job was submitted without one
+func (j *Job) OwnedBy(id identity.Identity, client string) bool {
+	return ownedBy(j.Identity.User, j.Client, id, client)
+}
+
+// SpanContext identifies the job's span, for linking spans that are
+// not its children
+func (j *Job) SpanContext() trace.SpanContext {
+	return j.span.SpanContext()
+}
+
+// reject ends the span of a job that was never accepted
+func (j *Job) reject(err error) {
+	j.cancel()
+	tracing.Fail(j.span, err)
+	j.span.End()
+}
+
+// Snapshot returns the current state of the job
+func (j *Job) Snapshot() Snapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	s := Snapshot{
+		ID:            j.ID,
+		Client:        j.Client,
+		User:          j.Identity.User,
+		Roles:         j.Identity.Roles,
+		Tool:          j.Request.Tool,
+		Target:        j.Request.Target,
+		Parameters:    j.Request.Parameters,
+		Status:        j.status,
+		QueuePosition: j.position,
+		Error:         j.lastError,
+		Output:        append([]string{}, j.output...),
+		Stderr:        append([]string(nil), j.stderr...),
+		Events:        append([]tools.Event{}, j.events...),
+		CreatedAt:     j.Created,
+		TraceID:       j.TraceID,
+	}
+	if !j.startTime.IsZero() {
+		start := j.startTime
+		s.StartTime = &start
+	}
+	end := time.Now()
+	if !j.ended.IsZero() {
+		end = j.ended
+		s.EndTime = &end
+	}
+	s.DurationMs = end.Sub(j.Created).Milliseconds()
+	return s
+}
+
+// Subscribe returns the buffered envelopes with a sequence number above
+// after and delivers later updates until the job finishes
+func (j *Job) Subscribe(after uint64) *Subscription {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	replay, missed := j.buffer.since(after)
+	ch := make(chan Update, subscriberBuffer)
+	sub := &Subscription{Replay: replay, Missed: missed, Updates: ch}
+	if !j.ended.IsZero() {
+		close(ch)
+		sub.close = func() {}
+		return sub
+	}
+
+	j.subs[ch] = struct{}{}
+	sub.close = func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if _, ok := j.subs[ch]; ok {
+			delete(j.subs, ch)
+			close(ch)
+		}
+	}
+	return sub
+}
+
+// markQueued starts the span covering the job's wait for a slot
+func (j *Job) markQueued() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, j.queueSpan = tracing.Start(j.ctx, "job.queue")
+}
+
+// endQueueSpan ends the queue span if the job waited.
+// The caller must hold j.mu.
+func (j *Job) endQueueSpan() {
+	if j.queueSpan != nil {
+		j.queueSpan.End()
+		j.queueSpan = nil
+	}
+}
+
+// setPosition publishes the job's place in the queue if it changed
+func (j *Job) setPosition(position, length int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if position == j.position {
+		return
+	}
+	j.position = position
+
+	env := j.stream.Queued(position, length)
+	j.buffer.add(env)
+	j.publish(Update{
+		Result: &executor.CommandResult{
+			JobID:         j.ID,
+			QueuePosition: position,
+			Tool:          j.Request.Tool,
+			Target:        j.Request.Target,
+			StartTime:     time.Now(),
+		},
+		Envelopes: []protocol.Envelope{env},
+	})
+}
+
+// markRunning records that the job has left the queue
+func (j *Job) markRunning() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.status = StatusRunning
+	j.position = 0
+	j.startTime = time.Now()
+	j.endQueueSpan()
+	close(j.started)
+}
+
+// record stores an executor result and publishes it
+func (j *Job) record(ctx context.Context, result executor.CommandResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	envs := j.stream.Results(ctx, result)
+	for _, env := range envs {
+		switch payload := env.Payload.(type) {
+		case protocol.OutputPayload:
+			if env.Type == protocol.TypeStderr {
+				j.stderr = append(j.stderr, payload.Line)
+			} else {
+				j.output = append(j.output, payload.Line)
+			}
+		case tools.Event:
+			j.events = append(j.events, payload)
+		case protocol.ErrorPayload:
+			j.lastError = payload.Message
+		}
+		j.buffer.add(env)
+	}
+	j.publish(Update{Result: &result, Envelopes: envs})
+}
+
+// finish records the terminal envelope and releases subscribers
+func (j *Job) finish(ctx context.Context) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	env := j.stream.Finish(ctx)
+	if payload, ok := env.Payload.(protocol.CompletedPayload); ok {
+		j.status = payload.Status
+		j.lastError = payload.Error
+		j.ended = payload.EndTime
+	} else {
+		j.ended = time.Now()
+	}
+	j.position = 0
+	j.buffer.add(env)
+
+	j.endQueueSpan()
+	j.span.SetAttributes(attribute.String("job.status", j.status))
+	if j.status == protocol.StatusFailed || j.status == protocol.StatusTimeout {
+		tracing.Fail(j.span, errors.New(j.lastError))
+	}
+	j.span.End()
+
+	// Legacy clients only learn about jobs that never ran from this result
+	var result *executor.CommandResult
+	if j.startTime.IsZero() {
+		result = &executor.CommandResult{
+			JobID:     j.ID,
+			Tool:      j.Request.Tool,
+			Target:    j.Request.Target,
+			Error:     "command execution cancelled",
+			StartTime: j.Created,
+			EndTime:   j.ended,
+		}
+	}
+	j.publish(Update{Result: result, Envelopes: []protocol.Envelope{env}})
+
+	for ch := range j.subs {
+		close(ch)
+	}
+	j.subs = nil
+	close(j.done)
+}
+
+// publish fans an update out to subscribers, dropping any that are full.
+// The caller must hold j.mu.
+func (j *Job) publish(u Update) {
+	for ch := range j.subs {
+		select {
+		case ch <- u:
+		default:
+			delete(j.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// finishedBefore reports whether the job ended before t
+func (j *Job) finishedBefore(t time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return !j.ended.IsZero() && j.ended.Before(t)
+}
@@ -0,0 +1,311 @@
+// File: backend/internal/jobs/manager.go
+
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/errors"
+	"github.com/himbojo/net-tools-gui/backend/internal/executor"
+)
+
+// Config holds job scheduling limits. Zero values select the defaults.
+type Config struct {
+	MaxConcurrent      int            `json:"maxConcurrent"`
+	MaxPerClient       int            `json:"maxPerClient"`
+	MaxPerTool         map[string]int `json:"maxPerTool"`
+	MaxQueued          int            `json:"maxQueued"`
+	MaxQueuedPerClient int            `json:"maxQueuedPerClient"`
+	BufferSize         int            `json:"bufferSize"`
+	Timeout            time.Duration  `json:"timeout"`
+	Retention          time.Duration  `json:"retention"`
+}
+
+// withDefaults fills in unset limits
+func (c Config) withDefaults() Config {
+	if c.MaxConcurrent <= 0 {
+		c.MaxConcurrent = 10
+	}
+	if c.MaxPerClient <= 0 {
+		c.MaxPerClient = 5
+	}
+	if c.MaxQueued <= 0 {
+		c.MaxQueued = 50
+	}
+	if c.MaxQueuedPerClient <= 0 {
+		c.MaxQueuedPerClient = 10
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 1000
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 60 * time.Second
+	}
+	if c.Retention <= 0 {
+		c.Retention = 15 * time.Minute
+	}
+	return c
+}
+
+// Manager runs jobs within global, per-client and per-tool concurrency
+// limits, queues the excess and keeps jobs addressable by ID for a
+// while after they finish
+type Manager struct {
+	executor *executor.CommandExecutor
+	config   Config
+
+	mu              sync.Mutex
+	jobs            map[string]*Job
+	queue           []*Job
+	running         int
+	runningByClient map[string]int
+	runningByTool   map[string]int
+	queuedByClient  map[string]int
+	observers       []func(Snapshot)
+}
+
+// NewManager creates a new Manager instance
+func NewManager(exec *executor.CommandExecutor, config Config) *Manager {
+	return &Manager{
+		executor:        exec,
+		config:          config.withDefaults(),
+		jobs:            make(map[string]*Job),
+		runningByClient: make(map[string]int),
+		runningByTool:   make(map[string]int),
+		queuedByClient:  make(map[string]int),
+	}
+}
+
+// Submit starts or queues a job for an already validated request. It
+// returns a queue-full AppError when the job can neither run nor wait.
+// The job is traced as part of any span in ctx but outlives ctx.
+func (m *Manager) Submit(ctx context.Context, req executor.CommandRequest, client string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.purge()
+	job := newJob(ctx, newJobID(), client, req, m.config.BufferSize)
+
+	if m.canStart(job) {
+		m.jobs[job.ID] = job
+		m.start(job)
+		return job, nil
+	}
+
+	if len(m.queue) >= m.config.MaxQueued {
+		err := errors.NewQueueFullError("job queue is full, try again later")
+		job.reject(err)
+		return nil, err
+	}
+	if m.queuedByClient[client] >= m.config.MaxQueuedPerClient {
+		err := errors.NewQueueFullError("too many queued jobs for this client")
+		job.reject(err)
+		return nil, err
+	}
+
+	m.jobs[job.ID] = job
+	job.markQueued()
+	m.queue = append(m.queue, job)
+	m.queuedByClient[client]++
+	job.setPosition(len(m.queue), len(m.queue))
+	go m.watchQueued(job)
+
+	return job, nil
+}
+
+// OnFinish registers a function that is called with the final state of
+// every job. It must be called before any jobs are submitted.
+func (m *Manager) OnFinish(fn func(Snapshot)) {
+	m.observers = append(m.observers, fn)
+}
+
+// Get returns the job with the given ID
+func (m *Manager) Get(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	return job, ok
+}
+
+// Cancel stops the job with the given ID and reports whether it exists
+func (m *Manager) Cancel(id string) bool {
+	job, ok := m.Get(id)
+	if ok {
+		job.Cancel()
+	}
+	return ok
+}
+
+// QueueLength returns the number of jobs waiting for a slot
+func (m *Manager) QueueLength() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.queue)
+}
+
+// Running returns the number of jobs currently executing
+func (m *Manager) Running() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.running
+}
+
+// canStart reports whether a job fits within every limit.
+// The caller must hold m.mu.
+func (m *Manager) canStart(job *Job) bool {
+	if m.running >= m.config.MaxConcurrent {
+		return false
+	}
+	if m.runningByClient[job.Client] >= m.config.MaxPerClient {
+		return false
+	}
+	if limit, ok := m.config.MaxPerTool[job.Request.Tool]; ok && m.runningByTool[job.Request.Tool] >= limit {
+		return false
+	}
+	return true
+}
+
+// start runs a job in the background. The caller must hold m.mu.
+func (m *Manager) start(job *Job) {
+	m.running++
+	m.runningByClient[job.Client]++
+	m.runningByTool[job.Request.Tool]++
+	job.markRunning()
+	go m.run(job)
+}
+
+// schedule starts every queued job that now fits and tells the rest
+// where they stand. The caller must hold m.mu.
+func (m *Manager) schedule() {
+	waiting := m.queue[:0]
+	for _, job := range m.queue {
+		if m.canStart(job) {
+			m.release(m.queuedByClient, job.Client)
+			m.start(job)
+			continue
+		}
+		waiting = append(waiting, job)
+	}
+	for i := len(waiting); i < len(m.queue); i++ {
+		m.queue[i] = nil
+	}
+	m.queue = waiting
+
+	for i, job := range m.queue {
+		job.setPosition(i+1, len(m.queue))
+	}
+}
+
+// watchQueued removes a job from the queue if it is cancelled before
+// it gets to run
+func (m *Manager) watchQueued(job *Job) {
+	select {
+	case <-job.started:
+		return
+	case <-job.ctx.Done():
+	}
+
+	m.mu.Lock()
+	removed := m.dequeue(job)
+	if removed {
+		m.schedule()
+	}
+	m.mu.Unlock()
+
+	if removed {
+		job.finish(job.ctx)
+		m.notify(job)
+	}
+}
+
+// dequeue removes a waiting job and reports whether it was queued.
+// The caller must hold m.mu.
+func (m *Manager) dequeue(job *Job) bool {
+	for i, queued := range m.queue {
+		if queued == job {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			m.release(m.queuedByClient, job.Client)
+			return true
+		}
+	}
+	return false
+}
+
+// run executes a job, publishes its results and frees its slot
+func (m *Manager) run(job *Job) {
+	ctx, cancel := context.WithTimeout(job.ctx, m.config.Timeout)
+	defer cancel()
+
+	// Execute returns only once nothing else can send on outputChan,
+	// even when the run is cancelled or times out
+	outputChan := make(chan executor.CommandResult)
+	go func() {
+		defer close(outputChan)
+		req := job.Request
+		m.executor.Execute(ctx, req.Tool, req.Target, req.Parameters, outputChan)
+	}()
+
+	for result := range outputChan {
+		result.JobID = job.ID
+		job.record(ctx, result)
+	}
+	job.finish(ctx)
+	job.cancel()
+	m.notify(job)
+
+	m.mu.Lock()
+	m.running--
+	m.release(m.runningByClient, job.Client)
+	m.release(m.runningByTool, job.Request.Tool)
+	m.schedule()
+	m.mu.Unlock()
+}
+
+// notify logs a finished job and passes it to the observers
+func (m *Manager) notify(job *Job) {
+	snapshot := job.Snapshot()
+	attrs := []slog.Attr{
+		slog.String("status", snapshot.Status),
+		slog.Int64("duration_ms", snapshot.DurationMs),
+	}
+	if snapshot.Error != "" {
+		attrs = append(attrs, slog.String("error", snapshot.Error))
+	}
+	slog.LogAttrs(job.ctx, slog.LevelInfo, "job finished", attrs...)
+	for _, fn := range m.observers {
+		fn(snapshot)
+	}
+}
+
+// release decrements a per-key counter, dropping it at zero.
+// The caller must hold m.mu.
+func (m *Manager) release(counts map[string]int, key string) {
+	if counts[key] <= 1 {
+		delete(counts, key)
+		return
+	}
+	counts[key]--
+}
+
+// purge forgets jobs that finished longer ago than the retention period.
+// The caller must hold m.mu.
+func (m *Manager) purge() {
+	cutoff := time.Now().Add(-m.config.Retention)
+	for id, job := range m.jobs {
+		if job.finishedBefore(cutoff) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// newJobID returns a random identifier for a job
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,126 @@
+// File: backend/internal/jobs/manager_test.go
+
+package jobs
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/executor"
+	"github.com/himbojo/net-tools-gui/backend/internal/protocol"
+	"github.com/himbojo/net-tools-gui/backend/pkg/tools"
+)
+
+// yesTool runs yes against the target, so a job keeps writing output
+// until it is stopped
+type yesTool struct {
+	path string
+}
+
+func (t yesTool) Name() string                            { return "yes" }
+func (t yesTool) Description() string                     { return "Prints the target forever" }
+func (t yesTool) Parameters() []tools.Parameter           { return nil }
+func (t yesTool) Validate(params map[string]string) error { return nil }
+func (t yesTool) NewParser() tools.Parser                 { return tools.NopParser{} }
+
+func (t yesTool) Command(target string, params map[string]string) (tools.Command, error) {
+	return tools.Command{Path: t.path, Args: []string{target}}, nil
+}
+
+// newYesManager returns a manager whose only tool is yes
+func newYesManager(t *testing.T, config Config) *Manager {
+	path, err := exec.LookPath("yes")
+	if err != nil {
+		t.Skipf("yes not available: %v", err)
+	}
+	registry := tools.NewRegistry()
+	registry.MustRegister(yesTool{path: path})
+	return NewManager(executor.NewExecutor(registry, nil), config)
+}
+
+var yesRequest = executor.CommandRequest{Tool: "yes", Target: "y"}
+
+// waitDone waits for a job to finish and returns its final state
+func waitDone(t *testing.T, job *Job) Snapshot {
+	t.Helper()
+	select {
+	case <-job.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("job %s did not finish", job.ID)
+	}
+	return job.Snapshot()
+}
+
+// waitOutput waits until a running job has produced output, so that
+// its readers are busy sending when it is stopped
+func waitOutput(t *testing.T, job *Job) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(job.Snapshot().Output) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s produced no output", job.ID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestManagerCancel(t *testing.T) {
+	m := newYesManager(t, Config{MaxConcurrent: 1})
+
+	running, err := m.Submit(context.Background(), yesRequest, "client")
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	queued, err := m.Submit(context.Background(), yesRequest, "client")
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if m.QueueLength() != 1 {
+		t.Fatalf("queue length = %d, want 1", m.QueueLength())
+	}
+
+	// A queued job is dropped without ever running
+	queued.Cancel()
+	if s := waitDone(t, queued); s.Status != protocol.StatusCancelled || len(s.Output) != 0 {
+		t.Errorf("queued job = %s with %d lines, want cancelled without output", s.Status, len(s.Output))
+	}
+	if m.QueueLength() != 0 {
+		t.Errorf("queue length = %d after cancel, want 0", m.QueueLength())
+	}
+
+	waitOutput(t, running)
+	running.Cancel()
+	if s := waitDone(t, running); s.Status != protocol.StatusCancelled {
+		t.Errorf("running job = %s, want cancelled", s.Status)
+	}
+	waitIdle(t, m)
+}
+
+func TestManagerTimeout(t *testing.T) {
+	m := newYesManager(t, Config{Timeout: 20 * time.Millisecond})
+
+	for i := 0; i < 10; i++ {
+		job, err := m.Submit(context.Background(), yesRequest, "client")
+		if err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+		if s := waitDone(t, job); s.Status != protocol.StatusTimeout {
+			t.Errorf("job = %s, want timeout", s.Status)
+		}
+	}
+	waitIdle(t, m)
+}
+
+// waitIdle waits for the manager to release the slots of finished jobs
+func waitIdle(t *testing.T, m *Manager) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Running() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d jobs still running", m.Running())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -1,82 +1,188 @@
 // File: backend/internal/logger/logger.go
 
+// Package logger configures the process-wide slog logger and carries
+// correlation fields through contexts. Code logs with the slog
+// functions that take a context, and every record picks up the fields
+// attached to that context with With plus the trace and span IDs of any
+// active span.
 package logger
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
-	"path/filepath"
-	"runtime"
-	"time"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Correlation field names
+const (
+	KeyRequestID = "request_id"
+	KeyClientID  = "client_id"
+	KeyUser      = "user"
+	KeyJobID     = "job_id"
+	KeyTool      = "tool"
+	KeyTarget    = "target"
+	KeyTraceID   = "trace_id"
+	KeySpanID    = "span_id"
 )
 
-type Logger struct {
-	debugLogger *log.Logger
-	infoLogger  *log.Logger
-	warnLogger  *log.Logger
-	errorLogger *log.Logger
+// Config holds logging settings. Zero values select the defaults.
+type Config struct {
+	// Level is debug, info, warn or error, optionally with an offset
+	// such as "info+2"
+	Level string `json:"level"`
+	// Format is json or text
+	Format string `json:"format"`
+	// Output is stdout, stderr or the path of a file to append to
+	Output    string `json:"output"`
+	AddSource bool   `json:"addSource"`
+	// Rotation applies when Output is a file
+	Rotation RotationConfig `json:"rotation"`
 }
 
-var (
-	defaultLogger *Logger
-	logFile       *os.File
-)
+// withDefaults fills in unset settings
+func (c Config) withDefaults() Config {
+	if c.Level == "" {
+		c.Level = "info"
+	}
+	if c.Format == "" {
+		c.Format = "json"
+	}
+	if c.Output == "" {
+		c.Output = "stdout"
+	}
+	return c
+}
+
+// New creates a logger from config. The returned function closes the
+// output if it is a file.
+func New(config Config) (*slog.Logger, func() error, error) {
+	l, _, closeOut, err := newLogger(config)
+	return l, closeOut, err
+}
+
+// newLogger creates a logger from config and returns its output
+func newLogger(config Config) (*slog.Logger, io.Writer, func() error, error) {
+	config = config.withDefaults()
 
-func init() {
-	// Create logs directory if it doesn't exist
-	if err := os.MkdirAll("logs", 0755); err != nil {
-		log.Fatal("Failed to create logs directory:", err)
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid log level %q", config.Level)
 	}
 
-	// Create or open log file with timestamp
-	filename := filepath.Join("logs", fmt.Sprintf("nettools_%s.log", time.Now().Format("2006-01-02")))
-	var err error
-	logFile, err = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	out, closeOut, err := openOutput(config.Output, config.Rotation)
 	if err != nil {
-		log.Fatal("Failed to open log file:", err)
+		return nil, nil, nil, err
 	}
 
-	defaultLogger = &Logger{
-		debugLogger: log.New(logFile, "DEBUG: ", log.Ldate|log.Ltime),
-		infoLogger:  log.New(logFile, "INFO:  ", log.Ldate|log.Ltime),
-		warnLogger:  log.New(logFile, "WARN:  ", log.Ldate|log.Ltime),
-		errorLogger: log.New(logFile, "ERROR: ", log.Ldate|log.Ltime),
+	opts := &slog.HandlerOptions{Level: level, AddSource: config.AddSource}
+	var handler slog.Handler
+	switch strings.ToLower(config.Format) {
+	case "json":
+		handler = slog.NewJSONHandler(out, opts)
+	case "text":
+		handler = slog.NewTextHandler(out, opts)
+	default:
+		closeOut()
+		return nil, nil, nil, fmt.Errorf("invalid log format %q", config.Format)
 	}
+	return slog.New(contextHandler{handler}), out, closeOut, nil
 }
 
-// getFileAndLine returns the file name and line number of the caller
-func getFileAndLine() string {
-	_, file, line, ok := runtime.Caller(2)
-	if !ok {
-		return "unknown:0"
+// Setup makes a logger created from config the default for slog and
+// for the standard log package
+func Setup(config Config) (func() error, error) {
+	l, out, closeOut, err := newLogger(config)
+	if err != nil {
+		return nil, err
 	}
-	return fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	slog.SetDefault(l)
+
+	outputMu.Lock()
+	output = out
+	outputMu.Unlock()
+	return closeOut, nil
 }
 
-func Debug(format string, v ...interface{}) {
-	loc := getFileAndLine()
-	defaultLogger.debugLogger.Printf("%s: %s", loc, fmt.Sprintf(format, v...))
+// openOutput opens the named log destination
+func openOutput(output string, rotation RotationConfig) (io.Writer, func() error, error) {
+	switch output {
+	case "stdout":
+		return os.Stdout, func() error { return nil }, nil
+	case "stderr":
+		return os.Stderr, func() error { return nil }, nil
+	}
+
+	f, err := openRotatingFile(output, rotation)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
 }
 
-func Info(format string, v ...interface{}) {
-	loc := getFileAndLine()
-	defaultLogger.infoLogger.Printf("%s: %s", loc, fmt.Sprintf(format, v...))
+// attrsKey is the context key of the correlation fields
+type attrsKey struct{}
+
+// With returns a context whose log records carry the given fields in
+// addition to those already attached to ctx, replacing any with the same
+// key. Arguments are key-value pairs or slog.Attr values, as for
+// slog.Logger.With.
+func With(ctx context.Context, args ...any) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+	attrs := append([]slog.Attr(nil), attrsFrom(ctx)...)
+	r := slog.Record{}
+	r.Add(args...)
+	r.Attrs(func(a slog.Attr) bool {
+		for i := range attrs {
+			if attrs[i].Key == a.Key {
+				attrs[i] = a
+				return true
+			}
+		}
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, attrsKey{}, attrs)
 }
 
-func Warn(format string, v ...interface{}) {
-	loc := getFileAndLine()
-	defaultLogger.warnLogger.Printf("%s: %s", loc, fmt.Sprintf(format, v...))
+// attrsFrom returns the correlation fields attached to ctx
+func attrsFrom(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
 }
 
-func Error(format string, v ...interface{}) {
-	loc := getFileAndLine()
-	defaultLogger.errorLogger.Printf("%s: %s", loc, fmt.Sprintf(format, v...))
+// contextHandler adds the correlation fields and trace of the record's
+// context to each record
+type contextHandler struct {
+	slog.Handler
 }
 
-// Close should be called before the program exits
-func Close() {
-	if logFile != nil {
-		logFile.Close()
+// Handle implements slog.Handler
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		r.AddAttrs(attrsFrom(ctx)...)
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			r.AddAttrs(
+				slog.String(KeyTraceID, sc.TraceID().String()),
+				slog.String(KeySpanID, sc.SpanID().String()),
+			)
+		}
 	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
 }
@@ -0,0 +1,337 @@
+// File: backend/internal/logger/rotate.go
+
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat stamps rotated files. It sorts lexically and avoids
+// characters that are awkward in file names.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// compressSuffix is appended to gzipped backups
+const compressSuffix = ".gz"
+
+// RotationConfig controls rotation of file output. Zero values disable
+// the corresponding rotation or retention rule.
+type RotationConfig struct {
+	// MaxSizeMB rotates the file before it grows past this size
+	MaxSizeMB int `json:"maxSizeMB"`
+	// Interval rotates the file when a multiple of the interval since
+	// the Unix epoch passes, so 24h rotates at midnight UTC
+	Interval time.Duration `json:"interval"`
+	// MaxBackups is how many rotated files are kept
+	MaxBackups int `json:"maxBackups"`
+	// MaxAge removes rotated files older than this
+	MaxAge time.Duration `json:"maxAge"`
+	// Compress gzips rotated files
+	Compress bool `json:"compress"`
+}
+
+// rotatingFile is a log file that rotates itself by size and time and
+// can be reopened after an external tool has moved it
+type rotatingFile struct {
+	path   string
+	config RotationConfig
+
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+
+	mill chan struct{}
+	done chan struct{}
+}
+
+// openRotatingFile opens path for appending, creating its directory
+func openRotatingFile(path string, config RotationConfig) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("create log directory: %w", err)
+	}
+
+	f := &rotatingFile{
+		path:   path,
+		config: config,
+		mill:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	go f.millLoop()
+	f.requestMill()
+	return f, nil
+}
+
+// open opens the log file and works out when it is next due to rotate.
+// The caller must hold f.mu or have exclusive access.
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	if f.config.Interval > 0 {
+		// A file left over from an earlier run rotates on the first
+		// write if its period has already ended
+		start := time.Now()
+		if f.size > 0 {
+			start = info.ModTime()
+		}
+		f.nextRotate = start.Truncate(f.config.Interval).Add(f.config.Interval)
+	}
+	return nil
+}
+
+// Write implements io.Writer, rotating first if the write would exceed
+// the size limit or the rotation interval has passed
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.due(len(p)) {
+		if err := f.rotate(); err != nil {
+			// Keep logging to the current file rather than losing lines
+			fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// due reports whether the file should rotate before a write of n bytes.
+// The caller must hold f.mu.
+func (f *rotatingFile) due(n int) bool {
+	if f.size == 0 {
+		return false
+	}
+	if max := int64(f.config.MaxSizeMB) << 20; max > 0 && f.size+int64(n) > max {
+		return true
+	}
+	return !f.nextRotate.IsZero() && !time.Now().Before(f.nextRotate)
+}
+
+// rotate moves the current file aside and starts a new one.
+// The caller must hold f.mu.
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	ext := filepath.Ext(f.path)
+	backup := strings.TrimSuffix(f.path, ext) + "-" + time.Now().Format(backupTimeFormat) + ext
+	if err := os.Rename(f.path, backup); err != nil {
+		// Reopen the original so logging carries on
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("rename log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.requestMill()
+	return nil
+}
+
+// Reopen closes the file and opens its path again, for use after an
+// external tool such as logrotate has moved it
+func (f *rotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	return f.open()
+}
+
+// Close closes the file and waits for background work to finish
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	close(f.mill)
+	<-f.done
+	return err
+}
+
+// requestMill asks the background worker to compress and prune backups.
+// The caller must hold f.mu or have exclusive access.
+func (f *rotatingFile) requestMill() {
+	select {
+	case f.mill <- struct{}{}:
+	default:
+	}
+}
+
+// millLoop compresses and prunes backups off the write path
+func (f *rotatingFile) millLoop() {
+	defer close(f.done)
+	for range f.mill {
+		if err := f.millOnce(); err != nil {
+			fmt.Fprintf(os.Stderr, "log retention failed: %v\n", err)
+		}
+	}
+}
+
+// logBackup is a rotated log file
+type logBackup struct {
+	path string
+	time time.Time
+}
+
+// millOnce applies the retention rules and compresses what is left
+func (f *rotatingFile) millOnce() error {
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	var keep []logBackup
+	cutoff := time.Now().Add(-f.config.MaxAge)
+	for i, b := range backups {
+		expired := f.config.MaxAge > 0 && b.time.Before(cutoff)
+		excess := f.config.MaxBackups > 0 && i >= f.config.MaxBackups
+		if expired || excess {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		keep = append(keep, b)
+	}
+
+	if !f.config.Compress {
+		return nil
+	}
+	for _, b := range keep {
+		if !strings.HasSuffix(b.path, compressSuffix) {
+			if err := compressFile(b.path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// backups lists the rotated files of the log, newest first
+func (f *rotatingFile) backups() ([]logBackup, error) {
+	dir := filepath.Dir(f.path)
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(filepath.Base(f.path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []logBackup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name[len(prefix):], compressSuffix), ext)
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, logBackup{path: filepath.Join(dir, name), time: t})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].time.After(backups[j].time)
+	})
+	return backups, nil
+}
+
+// compressFile gzips path and removes the original
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + compressSuffix + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+compressSuffix)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("compress %s: %w", path, err)
+	}
+	return os.Remove(path)
+}
+
+// reopener is an output that can be reopened on request
+type reopener interface {
+	Reopen() error
+}
+
+var (
+	outputMu sync.Mutex
+	output   io.Writer
+)
+
+// Reopen reopens the log file installed by Setup, so that logging
+// continues in a new file after the old one has been moved. It does
+// nothing when logging to stdout or stderr.
+func Reopen() error {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	r, ok := output.(reopener)
+	if !ok {
+		return nil
+	}
+	if err := r.Reopen(); err != nil {
+		return err
+	}
+	slog.Info("reopened log file")
+	return nil
+}
@@ -0,0 +1,92 @@
+// File: backend/internal/metrics/app.go
+
+package metrics
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/errors"
+	"github.com/himbojo/net-tools-gui/backend/internal/jobs"
+	"github.com/himbojo/net-tools-gui/backend/internal/protocol"
+)
+
+// Default is the registry served on /metrics
+var Default = NewRegistry()
+
+// Application metrics
+var (
+	HTTPRequests = Default.NewCounter("nettools_http_requests_total",
+		"HTTP requests by method, route and status code.", "method", "route", "code")
+	HTTPDuration = Default.NewHistogram("nettools_http_request_duration_seconds",
+		"HTTP request latency by method and route.", HTTPBuckets, "method", "route")
+	ToolRuns = Default.NewCounter("nettools_tool_runs_total",
+		"Finished tool runs by tool and outcome.", "tool", "outcome")
+	ToolDuration = Default.NewHistogram("nettools_tool_run_duration_seconds",
+		"Tool run time from start to finish by tool and outcome.", ToolBuckets, "tool", "outcome")
+	ToolErrors = Default.NewCounter("nettools_tool_errors_total",
+		"Tool requests that failed or were rejected, by tool and error type.", "tool", "type")
+	WebSocketConnections = Default.NewGauge("nettools_websocket_connections",
+		"Open WebSocket connections.")
+	RateLimitRejections = Default.NewCounter("nettools_rate_limit_rejections_total",
+		"Requests rejected by the rate limiter.")
+)
+
+// knownTools limits the tool label on rejected requests to registered
+// tools, since the name comes from the client
+var (
+	knownToolsMu sync.RWMutex
+	knownTools   = map[string]bool{}
+)
+
+func init() {
+	registerProcessMetrics(Default)
+}
+
+// Handler serves the default registry
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// SetTools sets the tool names that may appear as labels on rejected
+// requests; any other name is recorded as "unknown"
+func SetTools(names []string) {
+	knownToolsMu.Lock()
+	defer knownToolsMu.Unlock()
+
+	knownTools = make(map[string]bool, len(names))
+	for _, name := range names {
+		knownTools[name] = true
+	}
+}
+
+// ObserveJob records the outcome of a finished job. It is registered as
+// a jobs.Manager observer.
+func ObserveJob(s jobs.Snapshot) {
+	ToolRuns.Inc(s.Tool, s.Status)
+	if s.StartTime != nil && s.EndTime != nil {
+		ToolDuration.Observe(s.EndTime.Sub(*s.StartTime).Seconds(), s.Tool, s.Status)
+	}
+
+	switch s.Status {
+	case protocol.StatusFailed:
+		ToolErrors.Inc(s.Tool, errors.ErrorTypeExecution.String())
+	case protocol.StatusTimeout:
+		ToolErrors.Inc(s.Tool, errors.ErrorTypeTimeout.String())
+	}
+}
+
+// RecordError counts a tool request that was rejected before it ran
+func RecordError(tool string, err error) {
+	errType := errors.ErrorTypeInternal
+	if appErr, ok := err.(*errors.AppError); ok {
+		errType = appErr.Type
+	}
+
+	knownToolsMu.RLock()
+	if !knownTools[tool] {
+		tool = "unknown"
+	}
+	knownToolsMu.RUnlock()
+	ToolErrors.Inc(tool, errType.String())
+}
@@ -3,84 +3,324 @@
 package metrics
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
-	"time"
 )
 
-type MetricType string
-
-const (
-	MetricCommandExecution MetricType = "command_execution"
-	MetricWebSocket        MetricType = "websocket"
-	MetricRateLimit        MetricType = "rate_limit"
+// Bucket boundaries, in seconds, for request and tool run histograms
+var (
+	HTTPBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	ToolBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120}
 )
 
-type MetricValue struct {
-	Count       int64
-	TotalTime   time.Duration
-	Errors      int64
-	LastUpdated time.Time
+// collector writes one or more metric families in the Prometheus text
+// exposition format
+type collector interface {
+	write(w io.Writer)
 }
 
-type Metrics struct {
-	mu      sync.RWMutex
-	metrics map[MetricType]*MetricValue
+// Registry holds metrics and renders them for Prometheus to scrape
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
 }
 
-var (
-	defaultMetrics *Metrics
-	once           sync.Once
-)
+// NewRegistry creates a new Registry instance
+func NewRegistry() *Registry {
+	return &Registry{}
+}
 
-func init() {
-	once.Do(func() {
-		defaultMetrics = NewMetrics()
+// register adds a collector to the registry
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteText writes every metric in the Prometheus text format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry's metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
 	})
 }
 
-func NewMetrics() *Metrics {
-	return &Metrics{
-		metrics: make(map[MetricType]*MetricValue),
+// desc names a metric family and its labels
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// header writes the HELP and TYPE lines of a family
+func (d desc) header(w io.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, typ)
+}
+
+// key joins label values into a map key, checking their number
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs formats label values, plus an optional extra pair, for a
+// sample line
+func (d desc) labelPairs(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			pairs = append(pairs, d.labels[i]+`="`+escapeLabel(v)+`"`)
+		}
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+`="`+escapeLabel(extra[1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a monotonically increasing value per label combination
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter creates and registers a counter
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, labels}, values: make(map[string]float64)}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the counter for the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the counter for the given label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+}
+
+// write implements collector
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(w, "counter")
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+	}
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(key), formatFloat(c.values[key]))
+	}
+}
+
+// Gauge is a value per label combination that can go up and down
+type Gauge struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewGauge creates and registers a gauge
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{desc: desc{name, help, labels}, values: make(map[string]float64)}
+	r.register(g)
+	return g
+}
+
+// Set sets the gauge for the given label values
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	key := g.key(labelValues)
+	g.mu.Lock()
+	g.values[key] = v
+	g.mu.Unlock()
+}
+
+// Inc adds one to the gauge for the given label values
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+// Dec subtracts one from the gauge for the given label values
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+// Add adds v to the gauge for the given label values
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	key := g.key(labelValues)
+	g.mu.Lock()
+	g.values[key] += v
+	g.mu.Unlock()
+}
+
+// write implements collector
+func (g *Gauge) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.header(w, "gauge")
+	if len(g.labels) == 0 && len(g.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", g.name)
+	}
+	for _, key := range sortedKeys(g.values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelPairs(key), formatFloat(g.values[key]))
 	}
 }
 
-func (m *Metrics) Record(metricType MetricType, duration time.Duration, err error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// valueFunc is a gauge or counter read from a function at scrape time
+type valueFunc struct {
+	desc
+	typ string
+	fn  func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&valueFunc{desc: desc{name: name, help: help}, typ: "gauge", fn: fn})
+}
+
+// NewCounterFunc registers a counter whose value is read from fn
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&valueFunc{desc: desc{name: name, help: help}, typ: "counter", fn: fn})
+}
+
+// write implements collector
+func (f *valueFunc) write(w io.Writer) {
+	f.header(w, f.typ)
+	fmt.Fprintf(w, "%s %s\n", f.name, formatFloat(f.fn()))
+}
+
+// Histogram counts observations into buckets per label combination
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+// histogramValue is the state of one labelled histogram
+type histogramValue struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
 
-	if _, exists := m.metrics[metricType]; !exists {
-		m.metrics[metricType] = &MetricValue{}
+// NewHistogram creates and registers a histogram with the given upper
+// bucket bounds
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name, help, labels},
+		buckets: append([]float64(nil), buckets...),
+		values:  make(map[string]*histogramValue),
 	}
+	sort.Float64s(h.buckets)
+	r.register(h)
+	return h
+}
+
+// Observe records a value for the given label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	metric := m.metrics[metricType]
-	metric.Count++
-	metric.TotalTime += duration
-	if err != nil {
-		metric.Errors++
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			hv.counts[i]++
+		}
 	}
-	metric.LastUpdated = time.Now()
+	hv.count++
+	hv.sum += v
 }
 
-func (m *Metrics) Get(metricType MetricType) *MetricValue {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// write implements collector
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	if metric, exists := m.metrics[metricType]; exists {
-		return &MetricValue{
-			Count:       metric.Count,
-			TotalTime:   metric.TotalTime,
-			Errors:      metric.Errors,
-			LastUpdated: metric.LastUpdated,
+	h.header(w, "histogram")
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", formatFloat(bound)), hv.counts[i])
 		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", "+Inf"), hv.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(key), formatFloat(hv.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(key), hv.count)
 	}
-	return nil
 }
 
-// Global helper functions
-func RecordMetric(metricType MetricType, duration time.Duration, err error) {
-	defaultMetrics.Record(metricType, duration, err)
+// sortedKeys returns a map's keys in order so output is stable
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatFloat formats a sample value
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Escapes for the text format. Label values escape quotes as well as
+// backslashes and line feeds; help text only the latter two.
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+// escapeLabel escapes a label value for the text format
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
 }
 
-func GetMetric(metricType MetricType) *MetricValue {
-	return defaultMetrics.Get(metricType)
+// escapeHelp escapes help text for the text format
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
 }
@@ -0,0 +1,196 @@
+// File: backend/internal/metrics/metrics_test.go
+
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// render returns a registry's metrics in the text format
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	return b.String()
+}
+
+func TestWriteText(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(r *Registry)
+		want  string
+	}{
+		{
+			name:  "counter without samples",
+			setup: func(r *Registry) { r.NewCounter("jobs_total", "Jobs run.") },
+			want: `# HELP jobs_total Jobs run.
+# TYPE jobs_total counter
+jobs_total 0
+`,
+		},
+		{
+			name:  "labelled counter without samples",
+			setup: func(r *Registry) { r.NewCounter("jobs_total", "Jobs run.", "tool") },
+			want: `# HELP jobs_total Jobs run.
+# TYPE jobs_total counter
+`,
+		},
+		{
+			name: "labelled counter sorted by label values",
+			setup: func(r *Registry) {
+				c := r.NewCounter("jobs_total", "Jobs run.", "tool", "status")
+				c.Inc("ping", "success")
+				c.Add(2.5, "dig", "error")
+				c.Inc("ping", "success")
+			},
+			want: `# HELP jobs_total Jobs run.
+# TYPE jobs_total counter
+jobs_total{tool="dig",status="error"} 2.5
+jobs_total{tool="ping",status="success"} 2
+`,
+		},
+		{
+			name: "label value escaping",
+			setup: func(r *Registry) {
+				r.NewCounter("errors_total", "Errors.", "reason").Inc("bad \"quote\"\\path\nnext")
+			},
+			want: `# HELP errors_total Errors.
+# TYPE errors_total counter
+errors_total{reason="bad \"quote\"\\path\nnext"} 1
+`,
+		},
+		{
+			name:  "help escaping",
+			setup: func(r *Registry) { r.NewGauge("up", "Line one\nline \"two\" with C:\\path.") },
+			want: `# HELP up Line one\nline "two" with C:\\path.
+# TYPE up gauge
+up 0
+`,
+		},
+		{
+			name: "gauge",
+			setup: func(r *Registry) {
+				g := r.NewGauge("jobs_running", "Jobs running.", "tool")
+				g.Inc("ping")
+				g.Inc("ping")
+				g.Dec("ping")
+				g.Set(-3, "dig")
+			},
+			want: `# HELP jobs_running Jobs running.
+# TYPE jobs_running gauge
+jobs_running{tool="dig"} -3
+jobs_running{tool="ping"} 1
+`,
+		},
+		{
+			name: "special values",
+			setup: func(r *Registry) {
+				g := r.NewGauge("value", "Values.", "kind")
+				g.Set(math.Inf(1), "pos")
+				g.Set(math.Inf(-1), "neg")
+				g.Set(math.NaN(), "nan")
+				g.Set(1e21, "large")
+				g.Set(0.000001, "small")
+			},
+			want: `# HELP value Values.
+# TYPE value gauge
+value{kind="large"} 1e+21
+value{kind="nan"} NaN
+value{kind="neg"} -Inf
+value{kind="pos"} +Inf
+value{kind="small"} 1e-06
+`,
+		},
+		{
+			name: "gauge and counter funcs",
+			setup: func(r *Registry) {
+				r.NewGaugeFunc("goroutines", "Goroutines.", func() float64 { return 7 })
+				r.NewCounterFunc("seconds_total", "Seconds.", func() float64 { return 1.5 })
+			},
+			want: `# HELP goroutines Goroutines.
+# TYPE goroutines gauge
+goroutines 7
+# HELP seconds_total Seconds.
+# TYPE seconds_total counter
+seconds_total 1.5
+`,
+		},
+		{
+			name: "histogram",
+			setup: func(r *Registry) {
+				h := r.NewHistogram("duration_seconds", "Durations.", []float64{1, 0.1, 0.5})
+				h.Observe(0.05)
+				h.Observe(0.1)
+				h.Observe(0.7)
+				h.Observe(3)
+			},
+			want: `# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{le="0.1"} 2
+duration_seconds_bucket{le="0.5"} 2
+duration_seconds_bucket{le="1"} 3
+duration_seconds_bucket{le="+Inf"} 4
+duration_seconds_sum 3.85
+duration_seconds_count 4
+`,
+		},
+		{
+			name: "labelled histogram",
+			setup: func(r *Registry) {
+				h := r.NewHistogram("duration_seconds", "Durations.", []float64{1}, "tool")
+				h.Observe(2, "ping")
+				h.Observe(0.5, "dig")
+			},
+			want: `# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{tool="dig",le="1"} 1
+duration_seconds_bucket{tool="dig",le="+Inf"} 1
+duration_seconds_sum{tool="dig"} 0.5
+duration_seconds_count{tool="dig"} 1
+duration_seconds_bucket{tool="ping",le="1"} 0
+duration_seconds_bucket{tool="ping",le="+Inf"} 1
+duration_seconds_sum{tool="ping"} 2
+duration_seconds_count{tool="ping"} 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			tt.setup(r)
+			if got := render(t, r); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	c := NewRegistry().NewCounter("jobs_total", "Jobs run.", "tool")
+	defer func() {
+		if recover() == nil {
+			t.Error("Inc with the wrong number of label values did not panic")
+		}
+	}()
+	c.Inc("ping", "extra")
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("jobs_total", "Jobs run.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the text exposition format", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "\njobs_total 1\n") {
+		t.Errorf("body = %q, want the counter sample", rec.Body)
+	}
+}
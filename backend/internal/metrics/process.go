@@ -0,0 +1,73 @@
+// File: backend/internal/metrics/process.go
+
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is the kernel's USER_HZ, the unit of CPU times in procfs
+const clockTicks = 100
+
+// registerProcessMetrics adds Go runtime and process statistics
+func registerProcessMetrics(r *Registry) {
+	start := float64(time.Now().Unix())
+
+	r.NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	r.NewGaugeFunc("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", func() float64 {
+		return start
+	})
+	r.register(memStatsCollector{})
+	r.register(procCollector{})
+}
+
+// memStatsCollector reports Go heap statistics
+type memStatsCollector struct{}
+
+// write implements collector
+func (memStatsCollector) write(w io.Writer) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	writeSample(w, "go_memstats_alloc_bytes", "gauge", "Number of bytes allocated and still in use.", float64(ms.Alloc))
+	writeSample(w, "go_memstats_sys_bytes", "gauge", "Number of bytes obtained from the system.", float64(ms.Sys))
+	writeSample(w, "go_memstats_heap_inuse_bytes", "gauge", "Number of heap bytes that are in use.", float64(ms.HeapInuse))
+	writeSample(w, "go_gc_cycles_total", "counter", "Number of completed GC cycles.", float64(ms.NumGC))
+}
+
+// procCollector reports CPU, memory and file descriptor usage from
+// procfs. It writes nothing on systems without /proc.
+type procCollector struct{}
+
+// write implements collector
+func (procCollector) write(w io.Writer) {
+	if stat, err := os.ReadFile("/proc/self/stat"); err == nil {
+		// Fields after the parenthesised command name, starting at state
+		s := string(stat)
+		fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+		if len(fields) > 21 {
+			utime, _ := strconv.ParseFloat(fields[11], 64)
+			stime, _ := strconv.ParseFloat(fields[12], 64)
+			rss, _ := strconv.ParseFloat(fields[21], 64)
+			writeSample(w, "process_cpu_seconds_total", "counter", "Total user and system CPU time spent in seconds.", (utime+stime)/clockTicks)
+			writeSample(w, "process_resident_memory_bytes", "gauge", "Resident memory size in bytes.", rss*float64(os.Getpagesize()))
+		}
+	}
+	if fds, err := os.ReadDir("/proc/self/fd"); err == nil {
+		writeSample(w, "process_open_fds", "gauge", "Number of open file descriptors.", float64(len(fds)))
+	}
+}
+
+// writeSample writes a single unlabelled metric family
+func writeSample(w io.Writer, name, typ, help string, v float64) {
+	desc{name: name, help: help}.header(w, typ)
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(v))
+}
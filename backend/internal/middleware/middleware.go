@@ -6,25 +6,56 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/internal/metrics"
 )
 
+// RateLimitConfig holds a rate limiter's allowance per client. Zero
+// values select the defaults.
+type RateLimitConfig struct {
+	Limit  int           `json:"limit"`
+	Window time.Duration `json:"window"`
+}
+
+// withDefaults fills in unset settings
+func (c RateLimitConfig) withDefaults() RateLimitConfig {
+	if c.Limit <= 0 {
+		c.Limit = 10
+	}
+	if c.Window <= 0 {
+		c.Window = time.Minute
+	}
+	return c
+}
+
 // RateLimiter implements rate limiting for requests
 type RateLimiter struct {
 	requests map[string][]time.Time
 	mutex    sync.RWMutex
 	window   time.Duration
 	limit    int
+	rejected []func(*http.Request)
 }
 
-// NewRateLimiter creates a new RateLimiter instance
-func NewRateLimiter() *RateLimiter {
+// NewRateLimiter creates a new RateLimiter instance that allows each
+// client config.Limit requests per config.Window
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	config = config.withDefaults()
 	return &RateLimiter{
 		requests: make(map[string][]time.Time),
-		window:   time.Minute,
-		limit:    10,
+		window:   config.Window,
+		limit:    config.Limit,
 	}
 }
 
+// OnReject registers a function that is called with every request the
+// limiter turns away. It must be called before the limiter serves
+// requests.
+func (rl *RateLimiter) OnReject(fn func(*http.Request)) {
+	rl.rejected = append(rl.rejected, fn)
+}
+
 // cleanup removes old requests outside the window
 func (rl *RateLimiter) cleanup(clientID string) {
 	cutoff := time.Now().Add(-rl.window)
@@ -41,8 +72,8 @@ func (rl *RateLimiter) cleanup(clientID string) {
 // Limit applies rate limiting to requests
 func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get client identifier (IP address or session token)
-		clientID := r.RemoteAddr
+		// Identify the client by address, looking through trusted proxies
+		clientID := identity.ClientAddr(r)
 
 		rl.mutex.Lock()
 		// Clean up old requests
@@ -51,6 +82,10 @@ func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
 		// Check rate limit
 		if len(rl.requests[clientID]) >= rl.limit {
 			rl.mutex.Unlock()
+			metrics.RateLimitRejections.Inc()
+			for _, fn := range rl.rejected {
+				fn(r)
+			}
 			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
@@ -95,7 +130,7 @@ func (sm *SecurityMiddleware) Secure(next http.Handler) http.Handler {
 		if origin != "" {
 			if sm.allowedOrigins[origin] {
 				w.Header().Set("Access-Control-Allow-Origin", origin)
-				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 				w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 				w.Header().Set("Access-Control-Max-Age", "86400")
 			}
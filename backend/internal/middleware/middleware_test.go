@@ -0,0 +1,54 @@
+// File: backend/internal/middleware/middleware_test.go
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+)
+
+func TestRateLimiter(t *testing.T) {
+	rl := NewRateLimiter(RateLimitConfig{Limit: 3, Window: 50 * time.Millisecond})
+	var rejected int
+	rl.OnReject(func(*http.Request) { rejected++ })
+	h := rl.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	get := func(client string) int {
+		r := httptest.NewRequest(http.MethodGet, "/probe", nil)
+		r = r.WithContext(identity.NewClientContext(r.Context(), client))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := get("10.0.0.1"); code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200", i+1, code)
+		}
+	}
+	if code := get("10.0.0.1"); code != http.StatusTooManyRequests {
+		t.Errorf("request over the limit = %d, want 429", code)
+	}
+	if code := get("10.0.0.2"); code != http.StatusOK {
+		t.Errorf("another client = %d, want 200", code)
+	}
+	if rejected != 1 {
+		t.Errorf("OnReject called %d times, want 1", rejected)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if code := get("10.0.0.1"); code != http.StatusOK {
+		t.Errorf("request after the window = %d, want 200", code)
+	}
+}
+
+func TestRateLimitConfigDefaults(t *testing.T) {
+	c := RateLimitConfig{}.withDefaults()
+	if c.Limit != 10 || c.Window != time.Minute {
+		t.Errorf("defaults = %+v, want 10 per minute", c)
+	}
+}
@@ -0,0 +1,555 @@
+// File: backend/internal/monitor/monitor.go
+
+package monitor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	mathrand "math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/errors"
+	"github.com/himbojo/net-tools-gui/backend/internal/executor"
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/internal/jobs"
+	"github.com/himbojo/net-tools-gui/backend/internal/logger"
+	"github.com/himbojo/net-tools-gui/backend/internal/metrics"
+	"github.com/himbojo/net-tools-gui/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Result statuses in addition to the job statuses
+const (
+	StatusSkipped  = "skipped"
+	StatusRejected = "rejected"
+)
+
+// maxNameLength bounds monitor names
+const maxNameLength = 100
+
+// maxCronJitter is the largest jitter, in seconds, allowed on a cron
+// schedule, which may fire as often as once a minute
+const maxCronJitter = 30
+
+// Config holds monitor settings. Zero values select the defaults.
+type Config struct {
+	Path          string        `json:"path"`
+	MaxMonitors   int           `json:"maxMonitors"`
+	MaxConcurrent int           `json:"maxConcurrent"`
+	MaxResults    int           `json:"maxResults"`
+	MaxAge        time.Duration `json:"maxAge"`
+	PruneInterval time.Duration `json:"pruneInterval"`
+}
+
+// withDefaults fills in unset settings
+func (c Config) withDefaults() Config {
+	if c.Path == "" {
+		c.Path = "data/monitors.db"
+	}
+	if c.MaxMonitors <= 0 {
+		c.MaxMonitors = 100
+	}
+	if c.MaxConcurrent <= 0 {
+		c.MaxConcurrent = 4
+	}
+	if c.MaxResults <= 0 {
+		c.MaxResults = 10000
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = 30 * 24 * time.Hour
+	}
+	if c.PruneInterval <= 0 {
+		c.PruneInterval = time.Hour
+	}
+	return c
+}
+
+// Monitor is a tool request that runs on a schedule. Jitter delays each
+// run by a random number of seconds up to the given value. Runs are
+// checked against the target policy with the Roles of the user who last
+// defined the request, which only the owner or an admin may do.
+type Monitor struct {
+	ID        string                  `json:"id"`
+	Name      string                  `json:"name"`
+	Request   executor.CommandRequest `json:"request"`
+	Schedule  Schedule                `json:"schedule"`
+	Jitter    int                     `json:"jitter,omitempty"`
+	Paused    bool                    `json:"paused"`
+	Owner     string                  `json:"owner"`
+	Roles     []string                `json:"roles,omitempty"`
+	CreatedAt time.Time               `json:"createdAt"`
+	UpdatedAt time.Time               `json:"updatedAt"`
+	NextRun   *time.Time              `json:"nextRun,omitempty"`
+}
+
+// OwnedBy reports whether a request from client by id comes from the
+// monitor's owner: the same user, or the same client address when the
+// monitor was created without one
+func (m Monitor) OwnedBy(id identity.Identity, client string) bool {
+	if id.User != "" {
+		return m.Owner == id.User
+	}
+	return m.Owner == client
+}
+
+// Result is one run of a monitor. Values and Labels hold the numbers and
+// strings extracted from the run's events; the full output stays in the
+// job history under JobID.
+type Result struct {
+	MonitorID  string             `json:"monitorId"`
+	JobID      string             `json:"jobId,omitempty"`
+	Time       time.Time          `json:"time"`
+	Status     string             `json:"status"`
+	Error      string             `json:"error,omitempty"`
+	DurationMs int64              `json:"durationMs"`
+	Values     map[string]float64 `json:"values,omitempty"`
+	Labels     map[string]string  `json:"labels,omitempty"`
+}
+
+// entry is a monitor's scheduling state
+type entry struct {
+	monitor Monitor
+	timer   *time.Timer
+	next    time.Time
+	gen     uint64
+	running bool
+}
+
+// Manager runs monitors on their schedules through the job manager and
+// records their results
+type Manager struct {
+	jobs   *jobs.Manager
+	store  *store
+	config Config
+	slots  chan struct{}
+	stop   chan struct{}
+	wg     sync.WaitGroup
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	observers []func(Monitor, Result)
+	started   bool
+	closed    bool
+}
+
+// Open loads the stored monitors. They run once Start is called.
+func Open(jobManager *jobs.Manager, config Config) (*Manager, error) {
+	config = config.withDefaults()
+
+	st, err := openStore(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	monitors, err := st.monitors()
+	if err != nil {
+		st.close()
+		return nil, fmt.Errorf("failed to load monitors: %v", err)
+	}
+
+	m := &Manager{
+		jobs:    jobManager,
+		store:   st,
+		config:  config,
+		slots:   make(chan struct{}, config.MaxConcurrent),
+		stop:    make(chan struct{}),
+		entries: make(map[string]*entry),
+	}
+
+	for _, mon := range monitors {
+		if err := mon.Schedule.Validate(); err != nil {
+			slog.Warn("skipping monitor with invalid schedule", "monitor_id", mon.ID, "error", err)
+			continue
+		}
+		m.entries[mon.ID] = &entry{monitor: mon}
+	}
+
+	m.wg.Add(1)
+	go m.pruneLoop()
+	return m, nil
+}
+
+// Start schedules the stored monitors. Observers must be registered
+// before it is called.
+func (m *Manager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.started = true
+	for _, e := range m.entries {
+		m.schedule(e)
+	}
+}
+
+// Close stops scheduling, cancels running monitor jobs and closes the
+// database
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	for _, e := range m.entries {
+		m.unschedule(e)
+	}
+	m.mu.Unlock()
+
+	close(m.stop)
+	m.wg.Wait()
+	return m.store.close()
+}
+
+// OnResult registers a function that is called with every recorded
+// result. It must be called before Start.
+func (m *Manager) OnResult(fn func(Monitor, Result)) {
+	m.observers = append(m.observers, fn)
+}
+
+// List returns all monitors, oldest first
+func (m *Manager) List() []Monitor {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	monitors := make([]Monitor, 0, len(m.entries))
+	for _, e := range m.entries {
+		monitors = append(monitors, e.view())
+	}
+	sort.Slice(monitors, func(i, j int) bool {
+		return monitors[i].CreatedAt.Before(monitors[j].CreatedAt)
+	})
+	return monitors
+}
+
+// Get returns the monitor with the given ID
+func (m *Manager) Get(id string) (Monitor, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[id]
+	if !ok {
+		return Monitor{}, false
+	}
+	return e.view(), true
+}
+
+// Create validates, stores and schedules a new monitor. The request must
+// already have passed the validator.
+func (m *Manager) Create(mon Monitor) (Monitor, error) {
+	if err := checkMonitor(&mon); err != nil {
+		return Monitor{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.entries) >= m.config.MaxMonitors {
+		return Monitor{}, errors.NewValidationError(fmt.Sprintf("monitor limit of %d reached", m.config.MaxMonitors))
+	}
+
+	now := time.Now()
+	mon.ID = newMonitorID()
+	mon.CreatedAt = now
+	mon.UpdatedAt = now
+	mon.NextRun = nil
+	if err := m.store.putMonitor(mon); err != nil {
+		return Monitor{}, errors.NewInternalError(fmt.Errorf("failed to store monitor: %v", err))
+	}
+
+	e := &entry{monitor: mon}
+	m.entries[mon.ID] = e
+	m.schedule(e)
+	return e.view(), nil
+}
+
+// Update replaces a monitor's definition, keeping its identity and
+// results. The request must already have passed the validator.
+func (m *Manager) Update(id string, mon Monitor) (Monitor, error) {
+	if err := checkMonitor(&mon); err != nil {
+		return Monitor{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[id]
+	if !ok {
+		return Monitor{}, errors.NewNotFoundError("monitor not found")
+	}
+
+	mon.ID = id
+	mon.Owner = e.monitor.Owner
+	mon.CreatedAt = e.monitor.CreatedAt
+	mon.UpdatedAt = time.Now()
+	mon.NextRun = nil
+	if err := m.store.putMonitor(mon); err != nil {
+		return Monitor{}, errors.NewInternalError(fmt.Errorf("failed to store monitor: %v", err))
+	}
+
+	e.monitor = mon
+	m.schedule(e)
+	return e.view(), nil
+}
+
+// Delete stops a monitor and removes it along with its results
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[id]
+	if !ok {
+		return errors.NewNotFoundError("monitor not found")
+	}
+	if err := m.store.deleteMonitor(id); err != nil {
+		return errors.NewInternalError(fmt.Errorf("failed to delete monitor: %v", err))
+	}
+
+	m.unschedule(e)
+	delete(m.entries, id)
+	return nil
+}
+
+// SetPaused pauses or resumes a monitor
+func (m *Manager) SetPaused(id string, paused bool) (Monitor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[id]
+	if !ok {
+		return Monitor{}, errors.NewNotFoundError("monitor not found")
+	}
+	if e.monitor.Paused == paused {
+		return e.view(), nil
+	}
+
+	mon := e.monitor
+	mon.Paused = paused
+	mon.UpdatedAt = time.Now()
+	if err := m.store.putMonitor(mon); err != nil {
+		return Monitor{}, errors.NewInternalError(fmt.Errorf("failed to store monitor: %v", err))
+	}
+
+	e.monitor = mon
+	m.schedule(e)
+	return e.view(), nil
+}
+
+// Results returns a monitor's results between since and until, oldest
+// first. Zero times leave that end of the range open.
+func (m *Manager) Results(id string, since, until time.Time, limit int) ([]Result, error) {
+	if _, ok := m.Get(id); !ok {
+		return nil, errors.NewNotFoundError("monitor not found")
+	}
+	results, err := m.store.results(id, since, until, limit)
+	if err != nil {
+		return nil, errors.NewInternalError(fmt.Errorf("failed to read monitor results: %v", err))
+	}
+	return results, nil
+}
+
+// schedule arms the entry's timer for its next run, replacing any
+// earlier timer. The caller must hold m.mu.
+func (m *Manager) schedule(e *entry) {
+	m.unschedule(e)
+	if !m.started || m.closed || e.monitor.Paused {
+		return
+	}
+
+	now := time.Now()
+	next := e.monitor.Schedule.Next(now)
+	if next.IsZero() {
+		return
+	}
+	if e.monitor.Jitter > 0 {
+		next = next.Add(time.Duration(mathrand.Int63n(int64(e.monitor.Jitter) * int64(time.Second))))
+	}
+
+	gen := e.gen
+	id := e.monitor.ID
+	e.next = next
+	e.timer = time.AfterFunc(next.Sub(now), func() {
+		m.fire(id, gen)
+	})
+}
+
+// unschedule stops the entry's timer. The caller must hold m.mu.
+func (m *Manager) unschedule(e *entry) {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.next = time.Time{}
+	e.gen++
+}
+
+// fire starts a run of a monitor and arms its next one. A run is skipped
+// when the previous one is still going or every monitor slot is in use.
+func (m *Manager) fire(id string, gen uint64) {
+	m.mu.Lock()
+	e, ok := m.entries[id]
+	if !ok || e.gen != gen || m.closed {
+		m.mu.Unlock()
+		return
+	}
+	mon := e.monitor
+	m.schedule(e)
+
+	reason := ""
+	if e.running {
+		reason = "previous run still in progress"
+	} else {
+		select {
+		case m.slots <- struct{}{}:
+			e.running = true
+			m.wg.Add(1)
+		default:
+			reason = "monitor concurrency limit reached"
+		}
+	}
+	m.mu.Unlock()
+
+	if reason != "" {
+		m.record(mon, Result{
+			MonitorID: mon.ID,
+			Time:      time.Now(),
+			Status:    StatusSkipped,
+			Error:     reason,
+		})
+		return
+	}
+	go m.run(mon)
+}
+
+// run executes one run of a monitor and records its result
+func (m *Manager) run(mon Monitor) {
+	defer m.wg.Done()
+	defer func() {
+		<-m.slots
+		m.mu.Lock()
+		if e, ok := m.entries[mon.ID]; ok {
+			e.running = false
+		}
+		m.mu.Unlock()
+	}()
+
+	ctx := identity.NewContext(context.Background(), identity.Identity{Roles: mon.Roles})
+	ctx, span := tracing.Start(ctx, "monitor.run",
+		attribute.String("monitor.id", mon.ID),
+		attribute.String("monitor.name", mon.Name),
+	)
+	defer span.End()
+
+	start := time.Now()
+	job, err := m.jobs.Submit(ctx, mon.Request, "monitor:"+mon.ID)
+	if err != nil {
+		tracing.Fail(span, err)
+		metrics.RecordError(mon.Request.Tool, err)
+		m.record(mon, Result{
+			MonitorID: mon.ID,
+			Time:      start,
+			Status:    StatusRejected,
+			Error:     err.Error(),
+		})
+		return
+	}
+
+	select {
+	case <-job.Done():
+	case <-m.stop:
+		job.Cancel()
+		<-job.Done()
+	}
+
+	snapshot := job.Snapshot()
+	values, labels := Summarize(snapshot.Events)
+	m.record(mon, Result{
+		MonitorID:  mon.ID,
+		JobID:      snapshot.ID,
+		Time:       start,
+		Status:     snapshot.Status,
+		Error:      snapshot.Error,
+		DurationMs: snapshot.DurationMs,
+		Values:     values,
+		Labels:     labels,
+	})
+}
+
+// record stores a result and passes it to the observers. Results of
+// monitors deleted while running are dropped.
+func (m *Manager) record(mon Monitor, result Result) {
+	err := m.store.addResult(result)
+	if err == errDeleted {
+		return
+	}
+	if err != nil {
+		slog.Error("failed to store monitor result", "monitor_id", mon.ID, logger.KeyJobID, result.JobID, "error", err)
+	}
+	for _, fn := range m.observers {
+		fn(mon, result)
+	}
+}
+
+// pruneLoop applies the result retention policy periodically until Close
+func (m *Manager) pruneLoop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.config.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.store.prune(m.config.MaxAge, m.config.MaxResults); err != nil {
+			slog.Error("failed to prune monitor results", "error", err)
+		}
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// view returns the monitor with its next run time filled in
+func (e *entry) view() Monitor {
+	mon := e.monitor
+	if !e.next.IsZero() {
+		next := e.next
+		mon.NextRun = &next
+	}
+	return mon
+}
+
+// checkMonitor validates the parts of a monitor the validator does not
+// cover and prepares its schedule
+func checkMonitor(mon *Monitor) error {
+	if err := mon.Schedule.Validate(); err != nil {
+		return errors.NewValidationError(err.Error())
+	}
+	if len(mon.Name) > maxNameLength {
+		return errors.NewValidationError(fmt.Sprintf("name must be at most %d characters", maxNameLength))
+	}
+	// Names end up in notification headers, such as email subjects
+	if strings.IndexFunc(mon.Name, unicode.IsControl) >= 0 {
+		return errors.NewValidationError("name must not contain control characters")
+	}
+	if mon.Name == "" {
+		mon.Name = mon.Request.Tool + " " + mon.Request.Target
+	}
+
+	maxJitter := maxCronJitter
+	if mon.Schedule.Cron == "" {
+		maxJitter = mon.Schedule.Interval / 2
+	}
+	if mon.Jitter < 0 || mon.Jitter > maxJitter {
+		return errors.NewValidationError(fmt.Sprintf("jitter must be between 0 and %d seconds", maxJitter))
+	}
+	return nil
+}
+
+// newMonitorID returns a random identifier for a monitor
+func newMonitorID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
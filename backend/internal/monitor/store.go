@@ -0,0 +1,216 @@
+// File: backend/internal/monitor/store.go
+
+package monitor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket names
+var (
+	monitorsBucket = []byte("monitors")
+	resultsBucket  = []byte("results")
+)
+
+// Result query limits
+const (
+	defaultResultLimit = 500
+	maxResultLimit     = 10000
+)
+
+// errDeleted is returned when a result arrives for a monitor that has
+// been deleted while it was running
+var errDeleted = fmt.Errorf("monitor has been deleted")
+
+// store persists monitors and their results in an embedded database.
+// Results live in one nested bucket per monitor, keyed by time.
+type store struct {
+	db *bolt.DB
+}
+
+// openStore opens or creates the monitor database
+func openStore(path string) (*store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create monitor directory: %v", err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open monitor database: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{monitorsBucket, resultsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise monitor database: %v", err)
+	}
+	return &store{db: db}, nil
+}
+
+// close closes the database
+func (s *store) close() error {
+	return s.db.Close()
+}
+
+// monitors returns every stored monitor
+func (s *store) monitors() ([]Monitor, error) {
+	var monitors []Monitor
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(monitorsBucket).ForEach(func(k, v []byte) error {
+			var m Monitor
+			if err := json.Unmarshal(v, &m); err != nil {
+				return fmt.Errorf("monitor %s: %v", k, err)
+			}
+			monitors = append(monitors, m)
+			return nil
+		})
+	})
+	return monitors, err
+}
+
+// putMonitor creates or replaces a monitor
+func (s *store) putMonitor(m Monitor) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(monitorsBucket).Put([]byte(m.ID), data)
+	})
+}
+
+// deleteMonitor removes a monitor and all of its results
+func (s *store) deleteMonitor(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(monitorsBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		err := tx.Bucket(resultsBucket).DeleteBucket([]byte(id))
+		if err == bolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// addResult appends a result to a monitor's timeseries, or returns
+// errDeleted if the monitor no longer exists. The check shares the
+// transaction with the write, so a run that outlives its monitor
+// cannot recreate the results bucket deleteMonitor removed.
+func (s *store) addResult(r Result) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(monitorsBucket).Get([]byte(r.MonitorID)) == nil {
+			return errDeleted
+		}
+		b, err := tx.Bucket(resultsBucket).CreateBucketIfNotExists([]byte(r.MonitorID))
+		if err != nil {
+			return err
+		}
+		seq, _ := b.NextSequence()
+		return b.Put(resultKey(r.Time, seq), data)
+	})
+}
+
+// results returns a monitor's results between since and until, oldest
+// first, keeping the most recent ones when there are more than limit
+func (s *store) results(id string, since, until time.Time, limit int) ([]Result, error) {
+	if limit <= 0 {
+		limit = defaultResultLimit
+	}
+	if limit > maxResultLimit {
+		limit = maxResultLimit
+	}
+
+	results := []Result{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(resultsBucket).Bucket([]byte(id))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+
+		var k, v []byte
+		if until.IsZero() {
+			k, v = c.Last()
+		} else if k, _ = c.Seek(resultKey(until, ^uint64(0))); k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+
+		lower := resultKey(since, 0)
+		for ; k != nil && len(results) < limit; k, v = c.Prev() {
+			if !since.IsZero() && bytes.Compare(k, lower) < 0 {
+				break
+			}
+			var r Result
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			results = append(results, r)
+		}
+		return nil
+	})
+
+	// Reverse into chronological order
+	for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+		results[i], results[j] = results[j], results[i]
+	}
+	return results, err
+}
+
+// prune drops results older than maxAge and trims each monitor to at
+// most maxResults, returning how many results it removed
+func (s *store) prune(maxAge time.Duration, maxResults int) (int, error) {
+	removed := 0
+	cutoff := resultKey(time.Now().Add(-maxAge), 0)
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(resultsBucket).ForEachBucket(func(name []byte) error {
+			b := tx.Bucket(resultsBucket).Bucket(name)
+			excess := b.Stats().KeyN - maxResults
+
+			c := b.Cursor()
+			for k, _ := c.First(); k != nil; k, _ = c.First() {
+				if bytes.Compare(k, cutoff) >= 0 && excess <= 0 {
+					break
+				}
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				excess--
+				removed++
+			}
+			return nil
+		})
+	})
+	return removed, err
+}
+
+// resultKey orders results by time, then insertion sequence
+func resultKey(t time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	if !t.IsZero() {
+		binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	}
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
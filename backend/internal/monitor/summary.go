@@ -0,0 +1,80 @@
+// File: backend/internal/monitor/summary.go
+
+package monitor
+
+import (
+	"strings"
+
+	"github.com/himbojo/net-tools-gui/backend/pkg/tools"
+)
+
+// Summarize extracts the numeric values and labels worth charting or
+// alerting on from a run's structured events
+func Summarize(events []tools.Event) (map[string]float64, map[string]string) {
+	values := map[string]float64{}
+	labels := map[string]string{}
+	var path []string
+
+	for _, event := range events {
+		switch data := event.Data.(type) {
+		case tools.PingSummary:
+			values["transmitted"] = float64(data.Transmitted)
+			values["received"] = float64(data.Received)
+			values["loss"] = data.Loss
+			if data.RTT != nil {
+				values["rtt_min"] = data.RTT.Min
+				values["rtt_avg"] = data.RTT.Avg
+				values["rtt_max"] = data.RTT.Max
+				values["rtt_mdev"] = data.RTT.Mdev
+			}
+
+		case tools.DNSResponse:
+			if data.Status != "" {
+				labels["rcode"] = data.Status
+			}
+			values["query_time"] = float64(data.QueryTime)
+			values["answers"] = float64(len(data.Answer))
+
+		case tools.TracerouteHop:
+			hop := "*"
+			for _, probe := range data.Probes {
+				if probe.Address != "" {
+					hop = probe.Address
+					break
+				}
+			}
+			path = append(path, hop)
+			values["hops"] = float64(data.Hop)
+
+		case tools.HTTPResponse:
+			values["status_code"] = float64(data.Status)
+			values["dns"] = data.Timing.DNS
+			values["connect"] = data.Timing.Connect
+			values["tls"] = data.Timing.TLS
+			values["ttfb"] = data.Timing.TTFB
+			values["total"] = data.Timing.Total
+			values["redirects"] = float64(len(data.Redirects))
+
+		case tools.TLSResult:
+			values["days_to_expiry"] = float64(data.DaysToExpiry)
+			values["chain_valid"] = boolValue(data.ChainValid)
+			values["hostname_valid"] = boolValue(data.HostnameValid)
+			if data.Version != "" {
+				labels["tls_version"] = data.Version
+			}
+		}
+	}
+
+	if len(path) > 0 {
+		labels["path"] = strings.Join(path, ",")
+	}
+	return values, labels
+}
+
+// boolValue converts a boolean into a 0 or 1 sample
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
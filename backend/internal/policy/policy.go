@@ -0,0 +1,484 @@
+// File: backend/internal/policy/policy.go
+
+// Package policy decides which hosts users may run tools against. Rules
+// match on address range, domain suffix, tool and role, and are applied
+// to every address a target resolves to, so a name that resolves into a
+// forbidden range is refused like the address itself. The addresses
+// found when a run starts are pinned for the rest of the run, so a DNS
+// server that changes its answer cannot redirect it.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/pkg/tools"
+	"golang.org/x/net/idna"
+)
+
+// Rule actions
+const (
+	ActionAllow = "allow"
+	ActionDeny  = "deny"
+)
+
+// reservedRanges are denied after the configured rules unless
+// AllowReserved is set. They reach the server itself, its local link or
+// private networks, including cloud metadata services. Operators who
+// want users to reach internal networks allow them in Rules, which are
+// tried first. IPv4-mapped addresses (::ffff:0:0/96) are matched as the
+// IPv4 address they carry, so the IPv4 ranges cover them. Translated and
+// NAT64 forms embed an IPv4 address a translator will reach, so they are
+// denied outright.
+var reservedRanges = []string{
+	"0.0.0.0/8",         // this network
+	"10.0.0.0/8",        // private
+	"100.64.0.0/10",     // carrier-grade NAT and Alibaba Cloud metadata
+	"127.0.0.0/8",       // loopback
+	"169.254.0.0/16",    // link-local and cloud metadata
+	"172.16.0.0/12",     // private
+	"192.168.0.0/16",    // private
+	"224.0.0.0/4",       // multicast
+	"240.0.0.0/4",       // reserved and broadcast
+	"::/96",             // unspecified, loopback and IPv4-compatible
+	"::ffff:0:0:0/96",   // IPv4-translated (SIIT)
+	"64:ff9b::/96",      // NAT64 well-known prefix
+	"64:ff9b:1::/48",    // NAT64 local-use prefix
+	"fc00::/7",          // unique local
+	"fe80::/10",         // link-local
+	"ff00::/8",          // multicast
+	"fd00:ec2::254/128", // EC2 metadata over IPv6
+}
+
+// Config holds the target policy. Zero values select the defaults.
+type Config struct {
+	// Rules are tried in order and the first that matches an address
+	// decides whether it may be reached
+	Rules []Rule `json:"rules"`
+	// DefaultAction applies to addresses no rule matches, allow by default
+	DefaultAction string `json:"defaultAction"`
+	// AllowReserved drops the built-in rule that denies loopback,
+	// link-local, private, multicast and other reserved ranges after Rules
+	AllowReserved bool `json:"allowReserved"`
+	// ResolveTimeout bounds each DNS lookup
+	ResolveTimeout time.Duration `json:"resolveTimeout"`
+}
+
+// withDefaults fills in unset settings
+func (c Config) withDefaults() Config {
+	if c.DefaultAction == "" {
+		c.DefaultAction = ActionAllow
+	}
+	if c.ResolveTimeout == 0 {
+		c.ResolveTimeout = 5 * time.Second
+	}
+	return c
+}
+
+// Rule allows or denies a set of destinations. A rule with both CIDRs
+// and Domains matches only names in the domains that resolve into the
+// ranges; one with neither matches every destination.
+type Rule struct {
+	// Name identifies the rule in error messages
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	// CIDRs are address ranges; a bare address matches only itself
+	CIDRs []string `json:"cidrs"`
+	// Domains are suffixes matched against target names, so
+	// "example.com" matches it and all of its subdomains
+	Domains []string `json:"domains"`
+	// Tools limits the rule to runs of these tools
+	Tools []string `json:"tools"`
+	// Roles limits the rule to users holding one of these roles
+	Roles []string `json:"roles"`
+}
+
+// rule is a compiled Rule
+type rule struct {
+	name     string
+	allow    bool
+	prefixes []netip.Prefix
+	domains  []string
+	tools    map[string]bool
+	roles    []string
+}
+
+// matches reports whether the rule applies to a run of tool by id that
+// reaches addr through name, which is empty for address literals
+func (r rule) matches(tool string, id identity.Identity, name string, addr netip.Addr) bool {
+	if len(r.tools) > 0 && !r.tools[tool] {
+		return false
+	}
+	if len(r.roles) > 0 {
+		held := false
+		for _, role := range r.roles {
+			if id.HasRole(role) {
+				held = true
+				break
+			}
+		}
+		if !held {
+			return false
+		}
+	}
+	if len(r.domains) > 0 && !inDomains(name, r.domains) {
+		return false
+	}
+	if len(r.prefixes) > 0 {
+		for _, p := range r.prefixes {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// inDomains reports whether name is one of domains or below one of them
+func inDomains(name string, domains []string) bool {
+	if name == "" {
+		return false
+	}
+	for _, d := range domains {
+		if name == d || strings.HasSuffix(name, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeniedError reports a destination the policy does not allow
+type DeniedError struct {
+	// Host is the name that was resolved, empty for address literals
+	Host string
+	Addr netip.Addr
+	// Rule names the deciding rule, empty for the default action
+	Rule string
+}
+
+func (e *DeniedError) Error() string {
+	msg := fmt.Sprintf("target %s is not allowed", e.Addr)
+	if !e.Addr.IsValid() {
+		msg = fmt.Sprintf("target %s is not allowed", e.Host)
+	} else if e.Host != "" {
+		msg = fmt.Sprintf("target %s resolves to %s, which is not allowed", e.Host, e.Addr)
+	}
+	if e.Rule != "" {
+		msg += fmt.Sprintf(" (rule %q)", e.Rule)
+	}
+	return msg
+}
+
+// Policy evaluates run targets against the configured rules
+type Policy struct {
+	registry     *tools.Registry
+	rules        []rule
+	defaultAllow bool
+	timeout      time.Duration
+	resolver     *net.Resolver
+}
+
+// New compiles config into a Policy. Tool names in rules must be
+// registered in registry.
+func New(config Config, registry *tools.Registry) (*Policy, error) {
+	config = config.withDefaults()
+
+	p := &Policy{
+		registry: registry,
+		timeout:  config.ResolveTimeout,
+		resolver: net.DefaultResolver,
+	}
+	switch config.DefaultAction {
+	case ActionAllow:
+		p.defaultAllow = true
+	case ActionDeny:
+	default:
+		return nil, fmt.Errorf("invalid default action %q", config.DefaultAction)
+	}
+
+	for i, cr := range config.Rules {
+		if cr.Name == "" {
+			cr.Name = fmt.Sprintf("#%d", i+1)
+		}
+		r, err := compileRule(cr, registry)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", cr.Name, err)
+		}
+		p.rules = append(p.rules, r)
+	}
+	if !config.AllowReserved {
+		r, err := compileRule(Rule{Name: "reserved", Action: ActionDeny, CIDRs: reservedRanges}, registry)
+		if err != nil {
+			return nil, err
+		}
+		p.rules = append(p.rules, r)
+	}
+	return p, nil
+}
+
+// compileRule checks a configured rule and prepares it for matching
+func compileRule(cr Rule, registry *tools.Registry) (rule, error) {
+	r := rule{name: cr.Name, roles: cr.Roles}
+	switch cr.Action {
+	case ActionAllow:
+		r.allow = true
+	case ActionDeny:
+	default:
+		return r, fmt.Errorf("invalid action %q", cr.Action)
+	}
+
+	for _, s := range cr.CIDRs {
+		p, err := parsePrefix(s)
+		if err != nil {
+			return r, err
+		}
+		r.prefixes = append(r.prefixes, p)
+	}
+	for _, d := range cr.Domains {
+		d = strings.TrimPrefix(strings.TrimPrefix(d, "*"), ".")
+		if d == "" {
+			return r, fmt.Errorf("empty domain")
+		}
+		// Targets are validated to punycode, so match in that form
+		if strings.ContainsFunc(d, func(c rune) bool { return c >= utf8.RuneSelf }) {
+			ascii, err := idna.Lookup.ToASCII(d)
+			if err != nil {
+				return r, fmt.Errorf("invalid domain %q", d)
+			}
+			d = ascii
+		}
+		r.domains = append(r.domains, normalizeName(d))
+	}
+	if len(cr.Tools) > 0 {
+		r.tools = make(map[string]bool)
+		for _, name := range cr.Tools {
+			if _, ok := registry.Get(name); !ok {
+				return r, fmt.Errorf("unknown tool %q", name)
+			}
+			r.tools[name] = true
+		}
+	}
+	return r, nil
+}
+
+// parsePrefix parses a CIDR range or a single address. IPv4-mapped IPv6
+// ranges are converted to IPv4, since addresses are matched unmapped.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid address %q", s)
+		}
+		if addr.Zone() != "" {
+			return netip.Prefix{}, fmt.Errorf("invalid address %q: zones are not allowed", s)
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", s)
+	}
+	if p.Addr().Is4In6() {
+		if p.Bits() < 96 {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q: IPv4-mapped ranges must be /96 or longer", s)
+		}
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	return p.Masked(), nil
+}
+
+// normalizeName lowercases a host name and drops any trailing dot.
+// Address literals are left alone, since zone IDs are case sensitive.
+func normalizeName(name string) string {
+	if _, err := netip.ParseAddr(name); err == nil {
+		return name
+	}
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// Check evaluates a run of tool against target for the user in ctx,
+// resolving names. It returns the access granted to the run, which pins
+// the addresses found now, or a *DeniedError if any address the run
+// would reach is not allowed.
+func (p *Policy) Check(ctx context.Context, tool, target string, params map[string]string) (*Access, error) {
+	a := &Access{
+		policy:  p,
+		tool:    tool,
+		id:      identity.FromContext(ctx),
+		network: "ip",
+		pinned:  make(map[string][]netip.Addr),
+	}
+	switch params["family"] {
+	case tools.FamilyIPv4:
+		a.network = "ip4"
+	case tools.FamilyIPv6:
+		a.network = "ip6"
+	}
+
+	host := target
+	if t, ok := p.registry.Get(tool); ok {
+		if d, ok := t.(tools.Destination); ok {
+			// The target is still named in the query, so rules that do
+			// not depend on an address apply to it, keeping denied zones
+			// and tools out of reach through the server's own resolver
+			if err := p.decideName(tool, a.id, normalizeName(target)); err != nil {
+				return nil, err
+			}
+			host = d.Destination(target, params)
+		}
+	}
+	if host == "" {
+		return a, nil
+	}
+
+	a.host = normalizeName(host)
+	if _, err := a.Resolve(ctx, a.host); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// decide applies the rules to one address
+func (p *Policy) decide(tool string, id identity.Identity, name string, addr netip.Addr) error {
+	// Prefixes never contain addresses with zones
+	match := addr.WithZone("")
+	for _, r := range p.rules {
+		if !r.matches(tool, id, name, match) {
+			continue
+		}
+		if r.allow {
+			return nil
+		}
+		return &DeniedError{Host: name, Addr: addr, Rule: r.name}
+	}
+	if p.defaultAllow {
+		return nil
+	}
+	return &DeniedError{Host: name, Addr: addr}
+}
+
+// decideName applies the rules to a target the run names but does not
+// connect to. Rules with CIDRs need an address and are skipped.
+func (p *Policy) decideName(tool string, id identity.Identity, name string) error {
+	match := name
+	if _, err := netip.ParseAddr(name); err == nil {
+		// Reverse lookups name an address, which no domain matches
+		match = ""
+	}
+	for _, r := range p.rules {
+		if len(r.prefixes) > 0 || !r.matches(tool, id, match, netip.Addr{}) {
+			continue
+		}
+		if r.allow {
+			return nil
+		}
+		return &DeniedError{Host: name, Rule: r.name}
+	}
+	if p.defaultAllow {
+		return nil
+	}
+	return &DeniedError{Host: name}
+}
+
+// Access is a run's permission to reach its destination. It implements
+// tools.Guard so in-process tools connect only to vetted addresses.
+type Access struct {
+	policy *Policy
+	tool   string
+	id     identity.Identity
+	// network limits lookups to the run's address family
+	network string
+	// host is the destination checked by Policy.Check
+	host string
+
+	mu     sync.Mutex
+	pinned map[string][]netip.Addr
+}
+
+// Resolve implements tools.Guard. Hosts already seen are answered from
+// their pinned addresses; new ones, such as the targets of HTTP
+// redirects, are checked now and pinned in turn.
+func (a *Access) Resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	host = normalizeName(host)
+
+	a.mu.Lock()
+	addrs, ok := a.pinned[host]
+	a.mu.Unlock()
+	if ok {
+		return addrs, nil
+	}
+
+	addrs, err := a.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	a.pinned[host] = addrs
+	a.mu.Unlock()
+	return addrs, nil
+}
+
+// resolve looks up host and checks every address it has. The whole host
+// is refused if any address is, since the tool might reach any of them.
+func (a *Access) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	p := a.policy
+
+	var (
+		name  string
+		addrs []netip.Addr
+	)
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		name = host
+		ctx, cancel := context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+		addrs, err = p.resolver.LookupNetIP(ctx, a.network, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+		}
+	}
+
+	for i, addr := range addrs {
+		addr = addr.Unmap()
+		if err := p.decide(a.tool, a.id, name, addr); err != nil {
+			return nil, err
+		}
+		addrs[i] = addr
+	}
+	return addrs, nil
+}
+
+// Target returns what to pass to an external command in place of
+// target: the pinned address of the destination, preferring IPv4 as the
+// in-process tools do. Targets the run does not connect to are returned
+// unchanged.
+func (a *Access) Target(target string) string {
+	if a.host == "" || a.host != normalizeName(target) {
+		return target
+	}
+
+	a.mu.Lock()
+	addrs := a.pinned[a.host]
+	a.mu.Unlock()
+	if len(addrs) == 0 {
+		return target
+	}
+	for _, addr := range addrs {
+		if addr.Is4() {
+			return addr.String()
+		}
+	}
+	return addrs[0].String()
+}
@@ -0,0 +1,286 @@
+// File: backend/internal/policy/policy_test.go
+
+package policy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
+	"github.com/himbojo/net-tools-gui/backend/pkg/tools"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testZone answers DNS queries from a map of names to addresses that
+// tests may change between lookups
+type testZone struct {
+	mu      sync.Mutex
+	records map[string][]netip.Addr
+}
+
+// set replaces the addresses of a fully qualified name
+func (z *testZone) set(name string, addrs ...string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.records[name] = nil
+	for _, a := range addrs {
+		z.records[name] = append(z.records[name], netip.MustParseAddr(a))
+	}
+}
+
+// serve answers queries on conn until it is closed
+func (z *testZone) serve(conn net.PacketConn) {
+	buf := make([]byte, 512)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var msg dnsmessage.Message
+		if err := msg.Unpack(buf[:n]); err != nil || len(msg.Questions) == 0 {
+			continue
+		}
+		q := msg.Questions[0]
+
+		z.mu.Lock()
+		addrs, ok := z.records[q.Name.String()]
+		z.mu.Unlock()
+
+		reply := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: msg.ID, Response: true, RecursionAvailable: true},
+			Questions: msg.Questions,
+		}
+		if !ok {
+			reply.RCode = dnsmessage.RCodeNameError
+		}
+		for _, addr := range addrs {
+			rh := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: 60}
+			switch {
+			case q.Type == dnsmessage.TypeA && addr.Is4():
+				reply.Answers = append(reply.Answers, dnsmessage.Resource{Header: rh, Body: &dnsmessage.AResource{A: addr.As4()}})
+			case q.Type == dnsmessage.TypeAAAA && addr.Is6():
+				reply.Answers = append(reply.Answers, dnsmessage.Resource{Header: rh, Body: &dnsmessage.AAAAResource{AAAA: addr.As16()}})
+			}
+		}
+		if raw, err := reply.Pack(); err == nil {
+			conn.WriteTo(raw, from)
+		}
+	}
+}
+
+// testRules exercise each kind of match; their order matters
+var testRules = []Rule{
+	{Name: "one-documentation-host", Action: ActionAllow, CIDRs: []string{"198.51.100.9"}},
+	{Name: "documentation", Action: ActionDeny, CIDRs: []string{"198.51.100.0/24"}},
+	{Name: "mapped-range", Action: ActionDeny, CIDRs: []string{"::ffff:203.0.113.128/121"}},
+	{Name: "ops-internal", Action: ActionAllow, CIDRs: []string{"10.0.0.0/8"}, Roles: []string{"ops"}},
+	{Name: "no-http-to-corp", Action: ActionDeny, Domains: []string{"corp.example"}, Tools: []string{"http"}},
+	{Name: "corp-in-lab", Action: ActionAllow, Domains: []string{"lab.example"}, CIDRs: []string{"192.168.50.0/24"}},
+	{Name: "no-internal-dns", Action: ActionDeny, Domains: []string{"internal.example"}, Tools: []string{"dig"}},
+}
+
+// newTestPolicy compiles testRules and answers lookups from the
+// returned zone
+func newTestPolicy(t *testing.T) (*Policy, *testZone) {
+	t.Helper()
+	p, err := New(Config{Rules: testRules}, tools.NewDefaultRegistry(tools.Config{}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	zone := &testZone{records: make(map[string][]netip.Addr)}
+	go zone.serve(conn)
+
+	p.resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", conn.LocalAddr().String())
+		},
+	}
+	return p, zone
+}
+
+func TestCheck(t *testing.T) {
+	p, zone := newTestPolicy(t)
+	zone.set("public.example.", "203.0.113.5", "2001:db8::5")
+	zone.set("www.corp.example.", "203.0.113.6")
+	zone.set("mixed.example.", "203.0.113.7", "127.0.0.1")
+	zone.set("doc.example.", "198.51.100.7")
+	zone.set("host.lab.example.", "192.168.50.4")
+	zone.set("other.lab.example.", "192.168.60.4")
+
+	tests := []struct {
+		name   string
+		tool   string
+		target string
+		roles  []string
+		params map[string]string
+		rule   string // deciding deny rule, or "" if allowed
+	}{
+		{"public address", "ping", "8.8.8.8", nil, nil, ""},
+		{"public IPv6 address", "ping", "2001:db8::1", nil, nil, ""},
+		{"earlier allow wins", "ping", "198.51.100.9", nil, nil, ""},
+		{"later deny", "ping", "198.51.100.7", nil, nil, "documentation"},
+		{"mapped address unwrapped", "ping", "::ffff:198.51.100.7", nil, nil, "documentation"},
+		{"mapped rule range", "ping", "203.0.113.130", nil, nil, "mapped-range"},
+		{"outside mapped rule range", "ping", "203.0.113.1", nil, nil, ""},
+		{"private without role", "ping", "10.1.2.3", nil, nil, "reserved"},
+		{"private with role", "ping", "10.1.2.3", []string{"ops"}, nil, ""},
+		{"mapped private with role", "ping", "::ffff:10.1.2.3", []string{"ops"}, nil, ""},
+		{"loopback", "ping", "127.0.0.1", nil, nil, "reserved"},
+		{"rfc1918 172", "ping", "172.16.0.1", nil, nil, "reserved"},
+		{"rfc1918 192", "ping", "192.168.1.1", nil, nil, "reserved"},
+		{"cgnat metadata", "ping", "100.100.100.200", nil, nil, "reserved"},
+		{"aws metadata", "http", "169.254.169.254", nil, nil, "reserved"},
+		{"nat64", "ping", "64:ff9b::a01:203", nil, nil, "reserved"},
+		{"ipv4-compatible", "ping", "::127.0.0.1", nil, nil, "reserved"},
+		{"unique local", "ping", "fd00::1", nil, nil, "reserved"},
+		{"link-local with zone", "ping", "fe80::1%eth0", nil, nil, "reserved"},
+		{"name resolving publicly", "ping", "public.example", nil, nil, ""},
+		{"domain rule for its tool", "http", "www.corp.example", nil, nil, "no-http-to-corp"},
+		{"domain rule for another tool", "ping", "www.corp.example", nil, nil, ""},
+		{"name into denied range", "ping", "doc.example", nil, nil, "documentation"},
+		{"any address denied refuses the name", "ping", "mixed.example", nil, nil, "reserved"},
+		{"domain and range both match", "ping", "host.lab.example", nil, nil, ""},
+		{"domain matches but range does not", "ping", "other.lab.example", nil, nil, "reserved"},
+		{"dig query name rule", "dig", "db.internal.example", nil, nil, "no-internal-dns"},
+		{"dig query of a private address", "dig", "10.1.2.3", nil, nil, ""},
+		{"dig server checked", "dig", "example.com", nil, map[string]string{"server": "127.0.0.1"}, "reserved"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := identity.NewContext(context.Background(), identity.Identity{Roles: tt.roles})
+			_, err := p.Check(ctx, tt.tool, tt.target, tt.params)
+			if tt.rule == "" {
+				if err != nil {
+					t.Fatalf("Check: %v, want allowed", err)
+				}
+				return
+			}
+			var denied *DeniedError
+			if !errors.As(err, &denied) {
+				t.Fatalf("Check error = %v, want denied by %s", err, tt.rule)
+			}
+			if denied.Rule != tt.rule {
+				t.Errorf("denied by %q, want %q: %v", denied.Rule, tt.rule, err)
+			}
+		})
+	}
+}
+
+func TestCheckDefaultDeny(t *testing.T) {
+	p, err := New(Config{DefaultAction: ActionDeny, AllowReserved: true}, tools.NewDefaultRegistry(tools.Config{}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	_, err = p.Check(context.Background(), "ping", "127.0.0.1", nil)
+	var denied *DeniedError
+	if !errors.As(err, &denied) || denied.Rule != "" {
+		t.Errorf("Check error = %v, want denied by the default action", err)
+	}
+}
+
+func TestAccessPinsAddresses(t *testing.T) {
+	p, zone := newTestPolicy(t)
+	zone.set("flip.example.", "203.0.113.5")
+	zone.set("dual.example.", "2001:db8::6", "203.0.113.6")
+
+	a, err := p.Check(context.Background(), "ping", "flip.example", nil)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	// The name now points somewhere forbidden, but the run keeps the
+	// address it was allowed
+	zone.set("flip.example.", "127.0.0.1")
+	addrs, err := a.Resolve(context.Background(), "FLIP.example.")
+	if err != nil || len(addrs) != 1 || addrs[0] != netip.MustParseAddr("203.0.113.5") {
+		t.Errorf("Resolve = %v, %v, want the pinned 203.0.113.5", addrs, err)
+	}
+	if got := a.Target("flip.example"); got != "203.0.113.5" {
+		t.Errorf("Target = %s, want the pinned address", got)
+	}
+	if got := a.Target("elsewhere.example"); got != "elsewhere.example" {
+		t.Errorf("Target of another host = %s, want it unchanged", got)
+	}
+
+	// New hosts, such as redirect targets, are checked as they come
+	if _, err := a.Resolve(context.Background(), "127.0.0.1"); err == nil {
+		t.Error("Resolve of a reserved address succeeded")
+	}
+	if _, err := p.Check(context.Background(), "ping", "flip.example", nil); err == nil {
+		t.Error("a new run was allowed to the changed address")
+	}
+
+	tests := []struct {
+		family string
+		want   string
+	}{
+		{"", "203.0.113.6"},
+		{tools.FamilyIPv4, "203.0.113.6"},
+		{tools.FamilyIPv6, "2001:db8::6"},
+	}
+	for _, tt := range tests {
+		a, err := p.Check(context.Background(), "ping", "dual.example", map[string]string{"family": tt.family})
+		if err != nil {
+			t.Fatalf("Check family %q: %v", tt.family, err)
+		}
+		if got := a.Target("dual.example"); got != tt.want {
+			t.Errorf("family %q Target = %s, want %s", tt.family, got, tt.want)
+		}
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8", true},
+		{"10.1.2.3/8", "10.0.0.0/8", true},
+		{"192.0.2.1", "192.0.2.1/32", true},
+		{"2001:db8::1", "2001:db8::1/128", true},
+		{"::ffff:192.0.2.1", "192.0.2.1/32", true},
+		{"::ffff:192.0.2.0/120", "192.0.2.0/24", true},
+		{"::ffff:0:0/80", "", false},
+		{"fe80::1%eth0", "", false},
+		{"10.0.0.0/33", "", false},
+		{"example.com", "", false},
+	}
+	for _, tt := range tests {
+		got, err := parsePrefix(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("parsePrefix(%q) error = %v, want ok = %v", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && got.String() != tt.want {
+			t.Errorf("parsePrefix(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewRejectsBadRules(t *testing.T) {
+	registry := tools.NewDefaultRegistry(tools.Config{})
+	for _, r := range []Rule{
+		{Action: "maybe"},
+		{Action: ActionDeny, CIDRs: []string{"not-a-cidr"}},
+		{Action: ActionDeny, Domains: []string{"*."}},
+		{Action: ActionDeny, Tools: []string{"nmap"}},
+	} {
+		if _, err := New(Config{Rules: []Rule{r}}, registry); err == nil {
+			t.Errorf("New accepted rule %+v", r)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// File: backend/internal/protocol/protocol.go
+
+// Package protocol defines the versioned message envelope streamed to
+// clients that negotiate the "nettools.v1" WebSocket subprotocol.
+//
+// Every server message is an Envelope:
+//
+//	{"type": "output", "jobId": "…", "seq": 3, "time": "…",
+//	 "traceId": "…", "payload": {…}}
+//
+// The trace ID is present when tracing is enabled and names the trace
+// that covers the job, for finding its spans and log lines.
+//
+// Sequence numbers start at 1 for each job and increase by one per
+// envelope. A job always begins with "started" and ends with exactly one
+// of "completed" or "cancelled". While it waits for a free slot it sends
+// "queued" envelopes carrying its queue position; once running it may
+// send any number of "output", "stderr", "event" and "error" envelopes.
+// Errors that do not belong to a job (for example a rejected request)
+// carry no job ID and a sequence number of 0.
+//
+// Clients send the same messages as in the legacy format:
+//
+//	{"type": "run", "tool": "ping", "target": "example.com", "parameters": {…}}
+//	{"type": "cancel", "jobId": "…"}
+//
+// Connections that do not negotiate a subprotocol keep receiving raw
+// CommandResult objects.
+package protocol
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/executor"
+)
+
+// V1 is the WebSocket subprotocol that selects the envelope format
+const V1 = "nettools.v1"
+
+// Envelope types
+const (
+	TypeStarted   = "started"
+	TypeQueued    = "queued"
+	TypeOutput    = "output"
+	TypeStderr    = "stderr"
+	TypeEvent     = "event"
+	TypeError     = "error"
+	TypeCompleted = "completed"
+	TypeCancelled = "cancelled"
+)
+
+// Job completion statuses
+const (
+	StatusSuccess   = "success"
+	StatusFailed    = "failed"
+	StatusTimeout   = "timeout"
+	StatusCancelled = "cancelled"
+)
+
+// Envelope is a single server message
+type Envelope struct {
+	Type    string      `json:"type"`
+	JobID   string      `json:"jobId,omitempty"`
+	Seq     uint64      `json:"seq"`
+	Time    time.Time   `json:"time"`
+	TraceID string      `json:"traceId,omitempty"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// StartedPayload describes an accepted job
+type StartedPayload struct {
+	Tool       string            `json:"tool"`
+	Target     string            `json:"target"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// QueuedPayload reports a waiting job's place in the queue
+type QueuedPayload struct {
+	Position int `json:"position"`
+	Length   int `json:"length"`
+}
+
+// OutputPayload carries one line of output
+type OutputPayload struct {
+	Line string `json:"line"`
+}
+
+// ErrorPayload describes an error
+type ErrorPayload struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// CompletedPayload summarises a finished job
+type CompletedPayload struct {
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+// NewError returns an error envelope that does not belong to a job
+func NewError(code, message string) Envelope {
+	return Envelope{
+		Type:    TypeError,
+		Time:    time.Now(),
+		Payload: ErrorPayload{Code: code, Message: message},
+	}
+}
+
+// Stream turns the results of one job into numbered envelopes
+type Stream struct {
+	jobID     string
+	traceID   string
+	seq       uint64
+	startTime time.Time
+	lastError string
+}
+
+// NewStream creates a new Stream for a job, stamping its envelopes with
+// traceID if it is not empty
+func NewStream(jobID, traceID string) *Stream {
+	return &Stream{jobID: jobID, traceID: traceID, startTime: time.Now()}
+}
+
+// Started returns the envelope announcing the job
+func (s *Stream) Started(req executor.CommandRequest) Envelope {
+	return s.next(TypeStarted, StartedPayload{
+		Tool:       req.Tool,
+		Target:     req.Target,
+		Parameters: req.Parameters,
+	})
+}
+
+// Queued returns an envelope with the job's current queue position
+func (s *Stream) Queued(position, length int) Envelope {
+	return s.next(TypeQueued, QueuedPayload{Position: position, Length: length})
+}
+
+// Results converts an executor result for a job whose context is ctx
+// into zero or more envelopes. The executor's own completion markers are
+// dropped; Finish reports the end of the job instead.
+func (s *Stream) Results(ctx context.Context, result executor.CommandResult) []Envelope {
+	var envs []Envelope
+
+	if result.Error != "" {
+		// Errors without an end time are stderr output from a process
+		if result.EndTime.IsZero() {
+			return append(envs, s.next(TypeStderr, OutputPayload{Line: result.Error}))
+		}
+		s.lastError = result.Error
+		// Cancellations and timeouts are reported by Finish
+		if ctx.Err() != nil {
+			return envs
+		}
+		return append(envs, s.next(TypeError, ErrorPayload{Message: result.Error}))
+	}
+
+	if result.Output != "" {
+		envs = append(envs, s.next(TypeOutput, OutputPayload{Line: result.Output}))
+	}
+	if result.Event != nil {
+		envs = append(envs, s.next(TypeEvent, *result.Event))
+	}
+	return envs
+}
+
+// Finish returns the terminal envelope for a job whose context is ctx
+func (s *Stream) Finish(ctx context.Context) Envelope {
+	payload := CompletedPayload{
+		Status:    StatusSuccess,
+		Error:     s.lastError,
+		StartTime: s.startTime,
+		EndTime:   time.Now(),
+	}
+	payload.DurationMs = payload.EndTime.Sub(payload.StartTime).Milliseconds()
+
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		payload.Status = StatusCancelled
+		payload.Error = ""
+		return s.next(TypeCancelled, payload)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		payload.Status = StatusTimeout
+	case s.lastError != "":
+		payload.Status = StatusFailed
+	}
+	return s.next(TypeCompleted, payload)
+}
+
+// next stamps an envelope with the job and trace IDs and the next
+// sequence number
+func (s *Stream) next(typ string, payload interface{}) Envelope {
+	s.seq++
+	return Envelope{
+		Type:    typ,
+		JobID:   s.jobID,
+		Seq:     s.seq,
+		Time:    time.Now(),
+		TraceID: s.traceID,
+		Payload: payload,
+	}
+}
@@ -3,13 +3,27 @@
 package server
 
 import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/himbojo/net-tools-gui/backend/internal/errors"
+	"github.com/himbojo/net-tools-gui/backend/internal/identity"
 	"github.com/himbojo/net-tools-gui/backend/internal/logger"
 	"github.com/himbojo/net-tools-gui/backend/internal/metrics"
+	"github.com/himbojo/net-tools-gui/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ResponseWriter wraps http.ResponseWriter to capture status codes
@@ -23,31 +37,92 @@ func (rw *ResponseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// LoggingMiddleware adds request logging
+// Flush passes flushes through for streaming responses
+func (rw *ResponseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes hijacking through for WebSocket upgrades
+func (rw *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not support hijacking")
+	}
+	rw.statusCode = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (rw *ResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// LoggingMiddleware gives each request an ID, attaches it and the
+// client address to the request's log context and logs the request once
+// it completes. A well-formed X-Request-Id from the caller is kept. It
+// hands on a copy of the request, so it must wrap TracingMiddleware for
+// the route pattern to reach the spans.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		requestID := r.Header.Get("X-Request-Id")
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set("X-Request-Id", requestID)
+
+		ctx := logger.With(r.Context(),
+			logger.KeyRequestID, requestID,
+			logger.KeyClientID, identity.ClientAddr(r),
+		)
+
 		// Create custom response writer to capture status code
 		rw := &ResponseWriter{w, http.StatusOK}
 
 		// Process request
-		next.ServeHTTP(rw, r)
-
-		// Log request details
-		duration := time.Since(start)
-		logger.Info(
-			"[%s] %s %s %d %v",
-			r.Method,
-			r.RequestURI,
-			r.RemoteAddr,
-			rw.statusCode,
-			duration,
-		)
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		// The request's span is only open inside TracingMiddleware, which
+		// reports its trace in the response
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rw.statusCode),
+			slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+		}
+		if traceID := rw.Header().Get("X-Trace-Id"); traceID != "" {
+			attrs = append(attrs, slog.String(logger.KeyTraceID, traceID))
+		}
+		slog.LogAttrs(ctx, slog.LevelInfo, "request", attrs...)
 	})
 }
 
-// MetricsMiddleware adds metrics recording
+// validRequestID reports whether a caller-supplied request ID is safe to
+// log and echo back
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random request identifier
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// MetricsMiddleware records request counts and latency by method, route
+// pattern and status code
 func MetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -57,17 +132,60 @@ func MetricsMiddleware(next http.Handler) http.Handler {
 
 		next.ServeHTTP(rw, r)
 
-		duration := time.Since(start)
-		var err error
-		if rw.statusCode >= 400 {
-			err = fmt.Errorf("request failed with status %d", rw.statusCode)
+		// The mux records the matched pattern on the request; requests
+		// that never reached it are grouped together
+		route := r.Pattern
+		if _, path, ok := strings.Cut(route, " "); ok {
+			route = path
+		}
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.Inc(r.Method, route, strconv.Itoa(rw.statusCode))
+		metrics.HTTPDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
+
+// TracingMiddleware runs each request in a server span, continuing any
+// trace the caller propagated, and returns the trace ID in X-Trace-Id.
+// It hands on a copy of the request, so it must wrap every middleware
+// that reads the route pattern the mux records on the request.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("client.address", identity.ClientAddr(r)),
+			),
+		)
+		defer span.End()
+		if id := tracing.TraceID(ctx); id != "" {
+			w.Header().Set("X-Trace-Id", id)
+		}
+
+		rw := &ResponseWriter{w, http.StatusOK}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(rw, r)
+
+		if _, route, ok := strings.Cut(r.Pattern, " "); ok {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(attribute.String("http.route", route))
+		} else if r.Pattern != "" {
+			span.SetName(r.Method + " " + r.Pattern)
+			span.SetAttributes(attribute.String("http.route", r.Pattern))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", rw.statusCode))
+		if rw.statusCode >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rw.statusCode))
 		}
-		metrics.RecordMetric(metrics.MetricWebSocket, duration, err)
 	})
 }
 
 // WriteError writes an error response in a consistent format
-func WriteError(w http.ResponseWriter, err error) {
+func WriteError(ctx context.Context, w http.ResponseWriter, err error) {
 	var appErr *errors.AppError
 	if e, ok := err.(*errors.AppError); ok {
 		appErr = e
@@ -80,11 +198,11 @@ func WriteError(w http.ResponseWriter, err error) {
 	w.WriteHeader(status)
 
 	// Log error with appropriate level
+	level := slog.LevelWarn
 	if status >= 500 {
-		logger.Error(appErr.Error())
-	} else {
-		logger.Warn(appErr.Error())
+		level = slog.LevelError
 	}
+	slog.Log(ctx, level, "request failed", "status", status, "error", appErr.Error())
 }
 
 // WriteSuccess writes a success response in a consistent format
@@ -0,0 +1,144 @@
+// File: backend/internal/tracing/tracing.go
+
+// Package tracing sets up OpenTelemetry tracing and wraps the small part
+// of the API the rest of the server uses. Until Setup installs a
+// provider every span is a no-op and TraceID returns an empty string.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the server's spans to the backend
+const instrumentationName = "github.com/himbojo/net-tools-gui/backend"
+
+// tracer resolves against the global provider, so spans started before
+// Setup are no-ops and later ones are exported
+var tracer = otel.Tracer(instrumentationName)
+
+// Config holds tracing settings. Zero values select the defaults.
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Endpoint is the host and port of an OTLP/HTTP collector
+	Endpoint    string            `json:"endpoint"`
+	URLPath     string            `json:"urlPath"`
+	Insecure    bool              `json:"insecure"`
+	Headers     map[string]string `json:"headers"`
+	ServiceName string            `json:"serviceName"`
+	// SampleRatio is the fraction of new traces that are recorded.
+	// Traces started by a caller follow the caller's decision.
+	SampleRatio float64 `json:"sampleRatio"`
+}
+
+// withDefaults fills in unset settings
+func (c Config) withDefaults() Config {
+	if c.Endpoint == "" {
+		c.Endpoint = "localhost:4318"
+	}
+	if c.ServiceName == "" {
+		c.ServiceName = "net-tools"
+	}
+	if c.SampleRatio <= 0 || c.SampleRatio > 1 {
+		c.SampleRatio = 1
+	}
+	return c
+}
+
+// Setup installs a tracer provider that sends spans to exporter, or to
+// the configured OTLP endpoint if exporter is nil, and accepts W3C trace
+// context from callers. It does nothing unless tracing is enabled or an
+// exporter is given. The returned function flushes and stops the provider.
+func Setup(config Config, exporter sdktrace.SpanExporter) (func(context.Context) error, error) {
+	if !config.Enabled && exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+	config = config.withDefaults()
+
+	var opts []sdktrace.TracerProviderOption
+	if exporter == nil {
+		clientOpts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(config.Endpoint),
+			otlptracehttp.WithHeaders(config.Headers),
+		}
+		if config.URLPath != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithURLPath(config.URLPath))
+		}
+		if config.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptracehttp.New(context.Background(), clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("create OTLP exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	} else {
+		// Injected exporters are usually in-memory ones read by tests,
+		// which expect spans as soon as they end
+		opts = append(opts, sdktrace.WithSyncer(exporter))
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(config.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("create resource: %w", err)
+	}
+	opts = append(opts,
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the server's tracer for spans that need options Start
+// does not offer
+func Tracer() trace.Tracer {
+	return tracer
+}
+
+// Start begins a span as a child of any span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Fail marks a span as failed with err. A nil err leaves it unchanged.
+func Fail(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// TraceID returns the hex ID of the trace in ctx, or an empty string
+// if ctx carries no trace
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// Extract returns ctx with the trace context sent by a caller, if any
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
@@ -3,155 +3,300 @@
 package validator
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"net/netip"
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/himbojo/net-tools-gui/backend/internal/policy"
+	"github.com/himbojo/net-tools-gui/backend/pkg/tools"
+	"golang.org/x/net/idna"
 )
 
 // Validator handles input validation
 type Validator struct {
+	registry *tools.Registry
+	policy   *policy.Policy
+
 	// Cached compiled regexes
 	hostnameRegex *regexp.Regexp
 	ipv4Regex     *regexp.Regexp
-	ipv6Regex     *regexp.Regexp
+	zoneRegex     *regexp.Regexp
+
+	// idna converts internationalized host names to ASCII
+	idna *idna.Profile
 }
 
-// NewValidator creates a new Validator instance
-func NewValidator() *Validator {
+// NewValidator creates a new Validator instance. Targets are checked
+// against pol by ValidateAccess; a nil pol allows every target.
+func NewValidator(registry *tools.Registry, pol *policy.Policy) *Validator {
 	return &Validator{
+		registry:      registry,
+		policy:        pol,
 		hostnameRegex: regexp.MustCompile(`^([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])(\.[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])*$`),
 		ipv4Regex:     regexp.MustCompile(`^(\d{1,3}\.){3}\d{1,3}$`),
-		ipv6Regex:     regexp.MustCompile(`^([0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}$|^::1$`),
+		zoneRegex:     regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.\-]{0,14}$`),
+		// IDNA2008 lookup as RFC 5891 describes, with the UTS #46 mapping
+		// so input such as upper case or full-width forms is accepted
+		idna: idna.New(
+			idna.MapForLookup(),
+			idna.BidiRule(),
+			idna.CheckJoiners(true),
+			idna.Transitional(false),
+		),
 	}
 }
 
-// ValidateCommand validates command input
-func (v *Validator) ValidateCommand(tool, target string, params map[string]string) error {
+// ValidateCommand validates command input and returns the target in
+// canonical form, which is what should be run
+func (v *Validator) ValidateCommand(tool, target string, params map[string]string) (string, error) {
 	// Validate tool
 	if err := v.validateTool(tool); err != nil {
-		return err
+		return "", err
 	}
 
 	// Validate target
-	if err := v.validateTarget(target); err != nil {
-		return err
+	target, err := v.validateTarget(target)
+	if err != nil {
+		return "", err
 	}
 
 	// Validate parameters
-	return v.validateParams(tool, params)
+	if err := v.validateParams(tool, params); err != nil {
+		return "", err
+	}
+	if err := v.validateFamily(tool, target, params); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// ValidateAccess checks that the user in ctx may run tool against
+// target, resolving host names. It should follow ValidateCommand and
+// returns a *policy.DeniedError when the policy refuses the target.
+func (v *Validator) ValidateAccess(ctx context.Context, tool, target string, params map[string]string) error {
+	if v.policy == nil {
+		return nil
+	}
+	_, err := v.policy.Check(ctx, tool, target, params)
+	return err
 }
 
 // validateTool checks if the tool is supported
 func (v *Validator) validateTool(tool string) error {
-	switch tool {
-	case "ping", "dig", "traceroute":
-		return nil
-	default:
+	if _, ok := v.registry.Get(tool); !ok {
 		return fmt.Errorf("unsupported tool: %s", tool)
 	}
+	return nil
 }
 
-// validateTarget checks if the target is valid
-func (v *Validator) validateTarget(target string) error {
+// validateTarget checks if the target is valid and returns it in
+// canonical form: IPv6 addresses as RFC 5952 text and internationalized
+// host names as punycode
+func (v *Validator) validateTarget(target string) (string, error) {
 	target = strings.TrimSpace(target)
 	if target == "" {
-		return fmt.Errorf("empty target")
+		return "", fmt.Errorf("empty target")
 	}
 
-	if len(target) > 253 {
-		return fmt.Errorf("target too long")
-	}
-
-	// Check if it's an IP address
+	// Check if it's an IP address. Only IPv6 addresses contain colons.
 	if v.ipv4Regex.MatchString(target) {
 		if ip := net.ParseIP(target); ip == nil {
-			return fmt.Errorf("invalid IPv4 address")
+			return "", fmt.Errorf("invalid IPv4 address")
 		}
-		return nil
+		return target, nil
 	}
 
-	if v.ipv6Regex.MatchString(target) {
-		if ip := net.ParseIP(target); ip == nil {
-			return fmt.Errorf("invalid IPv6 address")
+	if strings.Contains(target, ":") {
+		return v.validateIPv6(target)
+	}
+
+	// Convert internationalized names, and check the punycode of names
+	// already converted. Other ASCII names are left alone, since the
+	// IDNA label rules reject some that are in use, such as those with
+	// hyphens in the third and fourth places.
+	if !isASCII(target) || strings.Contains(strings.ToLower(target), "xn--") {
+		ascii, err := v.idna.ToASCII(target)
+		if err != nil {
+			return "", fmt.Errorf("invalid internationalized hostname")
 		}
-		return nil
+		target = ascii
+	}
+
+	if len(target) > 253 {
+		return "", fmt.Errorf("target too long")
 	}
 
 	// Check if it's a valid hostname
 	if !v.hostnameRegex.MatchString(target) {
-		return fmt.Errorf("invalid hostname")
+		return "", fmt.Errorf("invalid hostname")
 	}
 
 	// Additional hostname validation
 	parts := strings.Split(target, ".")
 	for _, part := range parts {
 		if len(part) > 63 {
-			return fmt.Errorf("hostname label too long")
+			return "", fmt.Errorf("hostname label too long")
+		}
+	}
+
+	return target, nil
+}
+
+// validateIPv6 accepts the RFC 4291 text forms of an IPv6 address.
+// IPv4-mapped addresses are returned as the IPv4 address they carry. A
+// zone ID is only accepted on link-local addresses, where it is needed to
+// pick the interface, and only if it looks like an interface name or
+// index.
+func (v *Validator) validateIPv6(target string) (string, error) {
+	addr, err := netip.ParseAddr(target)
+	if err != nil || !addr.Is6() {
+		return "", fmt.Errorf("invalid IPv6 address")
+	}
+
+	zone := addr.Zone()
+	if addr.Is4In6() {
+		if zone != "" {
+			return "", fmt.Errorf("zone ID not allowed on an IPv4-mapped address")
+		}
+		return addr.Unmap().String(), nil
+	}
+	if zone != "" {
+		if !addr.IsLinkLocalUnicast() && !addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() {
+			return "", fmt.Errorf("zone ID only allowed on link-local addresses")
+		}
+		if !v.zoneRegex.MatchString(zone) {
+			return "", fmt.Errorf("invalid IPv6 zone ID")
+		}
+	}
+	return addr.String(), nil
+}
+
+// isASCII reports whether s contains only ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// validateFamily checks that an address family parameter does not
+// contradict the address the tool connects to
+func (v *Validator) validateFamily(tool, target string, params map[string]string) error {
+	family := params["family"]
+	if family == "" {
+		return nil
+	}
+	if t, ok := v.registry.Get(tool); ok {
+		if d, ok := t.(tools.Destination); ok {
+			target = d.Destination(target, params)
 		}
 	}
 
+	addr, err := netip.ParseAddr(target)
+	if err != nil {
+		return nil
+	}
+	if (family == tools.FamilyIPv4) != addr.Unmap().Is4() {
+		return fmt.Errorf("%s is not an IPv%s address", target, family)
+	}
 	return nil
 }
 
 // validateParams checks tool-specific parameters
 func (v *Validator) validateParams(tool string, params map[string]string) error {
-	switch tool {
-	case "ping":
-		return v.validatePingParams(params)
-	case "dig":
-		return v.validateDigParams(params)
-	case "traceroute":
-		return v.validateTracerouteParams(params)
-	default:
+	t, ok := v.registry.Get(tool)
+	if !ok {
 		return fmt.Errorf("invalid tool")
 	}
+
+	if err := v.validateCommonParams(params); err != nil {
+		return err
+	}
+
+	return t.Validate(params)
 }
 
-// validatePingParams validates ping-specific parameters
-func (v *Validator) validatePingParams(params map[string]string) error {
-	if count, ok := params["count"]; ok {
-		n, err := strconv.Atoi(count)
-		if err != nil {
-			return fmt.Errorf("invalid ping count")
+// validateCommonParams enforces restrictions on parameters shared by
+// several tools, whichever tool receives them
+func (v *Validator) validateCommonParams(params map[string]string) error {
+	if port, ok := params["port"]; ok && port != "" {
+		if err := v.validatePort(port); err != nil {
+			return err
 		}
-		if n < 1 || n > 10 {
-			return fmt.Errorf("ping count must be between 1 and 10")
+	}
+	if method, ok := params["method"]; ok && method != "" {
+		if err := v.validateHTTPMethod(method); err != nil {
+			return err
+		}
+	}
+	if headers, ok := params["headers"]; ok && headers != "" {
+		if err := v.validateHTTPHeaders(headers); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-// validateDigParams validates dig-specific parameters
-func (v *Validator) validateDigParams(params map[string]string) error {
-	if recordType, ok := params["type"]; ok {
-		validTypes := map[string]bool{
-			"A":     true,
-			"AAAA":  true,
-			"MX":    true,
-			"NS":    true,
-			"TXT":   true,
-			"SOA":   true,
-			"CNAME": true,
-			"PTR":   true,
-		}
-		if !validTypes[strings.ToUpper(recordType)] {
-			return fmt.Errorf("invalid DNS record type")
-		}
+// validatePort checks that a port parameter is a usable TCP/UDP port
+func (v *Validator) validatePort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return fmt.Errorf("invalid port")
+	}
+	if n < 1 || n > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
 	}
 	return nil
 }
 
-// validateTracerouteParams validates traceroute-specific parameters
-func (v *Validator) validateTracerouteParams(params map[string]string) error {
-	if maxHops, ok := params["maxHops"]; ok {
-		n, err := strconv.Atoi(maxHops)
-		if err != nil {
-			return fmt.Errorf("invalid max hops value")
+// validateHTTPMethod allows only methods without side effects on the target
+func (v *Validator) validateHTTPMethod(method string) error {
+	switch strings.ToUpper(method) {
+	case "GET", "HEAD", "OPTIONS":
+		return nil
+	default:
+		return fmt.Errorf("HTTP method not allowed: %s", method)
+	}
+}
+
+// validateHTTPHeaders allows a small set of request headers that cannot be
+// used to smuggle credentials or alter request framing
+func (v *Validator) validateHTTPHeaders(headers string) error {
+	allowed := map[string]bool{
+		"Accept":            true,
+		"Accept-Encoding":   true,
+		"Accept-Language":   true,
+		"Cache-Control":     true,
+		"If-Modified-Since": true,
+		"If-None-Match":     true,
+		"Pragma":            true,
+		"User-Agent":        true,
+	}
+
+	parsed, err := tools.ParseHeaderLines(headers)
+	if err != nil {
+		return err
+	}
+	if len(parsed) > 10 {
+		return fmt.Errorf("too many headers")
+	}
+	for name, values := range parsed {
+		if !allowed[name] {
+			return fmt.Errorf("header not allowed: %s", name)
 		}
-		if n < 1 || n > 30 {
-			return fmt.Errorf("max hops must be between 1 and 30")
+		for _, value := range values {
+			if len(value) > 1024 {
+				return fmt.Errorf("header value too long: %s", name)
+			}
+			if strings.ContainsAny(value, "\r\n\x00") {
+				return fmt.Errorf("invalid header value: %s", name)
+			}
 		}
 	}
 	return nil
@@ -0,0 +1,110 @@
+// File: backend/internal/validator/validator_test.go
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/himbojo/net-tools-gui/backend/pkg/tools"
+)
+
+func TestValidateCommandTarget(t *testing.T) {
+	v := NewValidator(tools.NewDefaultRegistry(tools.Config{}), nil)
+
+	tests := []struct {
+		name   string
+		target string
+		want   string // canonical target, or "" when the target is rejected
+	}{
+		// IPv4 and IPv6 text forms
+		{"ipv4", "192.0.2.1", "192.0.2.1"},
+		{"ipv4 out of range", "192.0.2.256", ""},
+		{"ipv6 full form", "2001:0db8:0000:0000:0000:0000:0000:0001", "2001:db8::1"},
+		{"ipv6 upper case", "2001:DB8::1", "2001:db8::1"},
+		{"ipv6 compressed", "2001:db8::1", "2001:db8::1"},
+		{"ipv6 loopback", "::1", "::1"},
+		{"ipv6 unspecified", "::", "::"},
+		{"ipv6 longest zero run", "2001:db8:0:0:1:0:0:1", "2001:db8::1:0:0:1"},
+		{"ipv6 two compressions", "2001::db8::1", ""},
+		{"ipv6 too many groups", "1:2:3:4:5:6:7:8:9", ""},
+		{"ipv6 embedded ipv4", "64:ff9b::192.0.2.1", "64:ff9b::c000:201"},
+		{"ipv4-mapped", "::ffff:192.0.2.1", "192.0.2.1"},
+		{"ipv4-mapped hex", "::ffff:c000:201", "192.0.2.1"},
+		{"ipv4-mapped bad ipv4", "::ffff:192.0.2.256", ""},
+
+		// Zones
+		{"link-local zone name", "fe80::1%eth0", "fe80::1%eth0"},
+		{"link-local zone index", "fe80::1%2", "fe80::1%2"},
+		{"link-local multicast zone", "ff02::1%eth0", "ff02::1%eth0"},
+		{"link-local without zone", "fe80::1", "fe80::1"},
+		{"global address zone", "2001:db8::1%eth0", ""},
+		{"ipv4-mapped zone", "::ffff:192.0.2.1%eth0", ""},
+		{"zone with shell characters", "fe80::1%eth0;reboot", ""},
+		{"zone too long", "fe80::1%abcdefghijklmnopq", ""},
+
+		// Host names
+		{"hostname", "example.com", "example.com"},
+		{"hostname with hyphens in third and fourth places", "ab--cd.example.com", "ab--cd.example.com"},
+		{"u-label", "bücher.example", "xn--bcher-kva.example"},
+		{"u-label mapped to lower case", "BÜCHER.example", "xn--bcher-kva.example"},
+		{"u-label with deviation character", "straße.example", "xn--strae-oqa.example"},
+		{"punycode", "xn--bcher-kva.example", "xn--bcher-kva.example"},
+		{"punycode upper case", "XN--BCHER-KVA.example", "xn--bcher-kva.example"},
+		{"invalid punycode", "xn--a.example", ""},
+		{"joiner out of context", "a\u200db.example", ""},
+		{"mixed bidi label", "a\u05d0.example", ""},
+		{"leading combining mark", "\u0301a.example", ""},
+		{"disallowed code point", "a b.example", ""},
+		{"label too long", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa.example", ""},
+		{"shell metacharacters", "example.com;reboot", ""},
+		{"empty", " ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.ValidateCommand("ping", tt.target, nil)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("ValidateCommand(%q) = %q, want an error", tt.target, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateCommand(%q): %v", tt.target, err)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateCommand(%q) = %q, want %q", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCommandFamily(t *testing.T) {
+	v := NewValidator(tools.NewDefaultRegistry(tools.Config{}), nil)
+
+	tests := []struct {
+		name   string
+		tool   string
+		target string
+		params map[string]string
+		ok     bool
+	}{
+		{"ipv4 with family 4", "ping", "192.0.2.1", map[string]string{"family": "4"}, true},
+		{"ipv4 with family 6", "ping", "192.0.2.1", map[string]string{"family": "6"}, false},
+		{"ipv6 with family 6", "ping", "2001:db8::1", map[string]string{"family": "6"}, true},
+		{"ipv6 with family 4", "ping", "2001:db8::1", map[string]string{"family": "4"}, false},
+		{"ipv4-mapped with family 4", "ping", "::ffff:192.0.2.1", map[string]string{"family": "4"}, true},
+		{"ipv4-mapped with family 6", "ping", "::ffff:192.0.2.1", map[string]string{"family": "6"}, false},
+		{"hostname with either family", "ping", "example.com", map[string]string{"family": "6"}, true},
+		{"unknown family", "ping", "example.com", map[string]string{"family": "5"}, false},
+		{"dig checks the server", "dig", "example.com", map[string]string{"family": "4", "server": "2001:db8::53"}, false},
+		{"dig with matching server", "dig", "example.com", map[string]string{"family": "6", "server": "2001:db8::53"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.ValidateCommand(tt.tool, tt.target, tt.params)
+			if ok := err == nil; ok != tt.ok {
+				t.Errorf("ValidateCommand(%s, %q, %v) error = %v, want ok %v", tt.tool, tt.target, tt.params, err, tt.ok)
+			}
+		})
+	}
+}
@@ -1,17 +1,149 @@
 package tools
 
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// digRecordTypes lists the DNS record types accepted by the dig tool
+var digRecordTypes = []string{
+	"A", "AAAA", "MX", "NS", "TXT", "SOA", "CNAME", "PTR",
+	"SRV", "CAA", "DS", "DNSKEY", "TLSA", "SSHFP", "NAPTR", "SVCB", "HTTPS",
+}
+
+// DigConfig holds dig tool settings
+type DigConfig struct {
+	// Engine is the default engine, either "exec" or "native"
+	Engine string `json:"engine"`
+	// Resolver is the server the native engine queries as host[:port].
+	// Empty uses the first nameserver in /etc/resolv.conf.
+	Resolver string        `json:"resolver"`
+	Timeout  time.Duration `json:"timeout"`
+}
+
 // DigTool handles DNS lookup commands
 type DigTool struct {
-	// TODO: Add dig configuration
+	path   string
+	config DigConfig
 }
 
 // NewDigTool creates a new DigTool instance
-func NewDigTool() *DigTool {
-	return &DigTool{}
+func NewDigTool(config DigConfig) *DigTool {
+	path := "/usr/bin/dig"
+	if runtime.GOOS == "windows" {
+		path = "dig"
+	}
+	if config.Engine == "" {
+		config.Engine = EngineExec
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	return &DigTool{path: path, config: config}
+}
+
+// Name implements Tool
+func (d *DigTool) Name() string { return "dig" }
+
+// Description implements Tool
+func (d *DigTool) Description() string { return "Query DNS records for a name" }
+
+// Parameters implements Tool
+func (d *DigTool) Parameters() []Parameter {
+	return []Parameter{
+		{Name: "type", Type: ParameterEnum, Description: "DNS record type to query", Default: "A", Values: digRecordTypes},
+		{Name: "engine", Type: ParameterEnum, Description: "Run the system dig binary or the built-in resolver", Default: d.config.Engine, Values: []string{EngineExec, EngineNative}},
+		{Name: "server", Type: ParameterString, Description: "IP address of the DNS server to query"},
+		familyParameter,
+	}
+}
+
+// Validate implements Tool
+func (d *DigTool) Validate(params map[string]string) error {
+	if recordType, ok := params["type"]; ok {
+		valid := false
+		for _, t := range digRecordTypes {
+			if strings.ToUpper(recordType) == t {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid DNS record type")
+		}
+	}
+	if err := validateEngine(params); err != nil {
+		return err
+	}
+	if err := validateFamily(params); err != nil {
+		return err
+	}
+	if server, ok := params["server"]; ok && server != "" {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("DNS server must be an IP address")
+		}
+	}
+	return nil
+}
+
+// Command implements Tool
+func (d *DigTool) Command(target string, params map[string]string) (Command, error) {
+	recordType := paramOrDefault(params, "type", "A")
+	args := []string{"+noquestion", recordType, target}
+	if family := params["family"]; family != "" {
+		args = append(args, "-"+family)
+	}
+	if server := params["server"]; server != "" {
+		args = append(args, "@"+server)
+	}
+	return Command{Path: d.path, Args: args}, nil
+}
+
+// Destination implements Destination. Queries go to the server
+// parameter, or to the configured resolver when it is not set.
+func (d *DigTool) Destination(target string, params map[string]string) string {
+	return params["server"]
 }
 
-// Execute runs a dig command
-func (d *DigTool) Execute(target string, params map[string]string) (string, error) {
-	// TODO: Implement dig command execution
-	return "", nil
+// NewParser implements Tool
+func (d *DigTool) NewParser() Parser { return NewDigParser() }
+
+// Native implements Runner
+func (d *DigTool) Native(params map[string]string) bool {
+	return paramOrDefault(params, "engine", d.config.Engine) == EngineNative
+}
+
+// Run implements Runner using the built-in DNS client
+func (d *DigTool) Run(ctx context.Context, target string, params map[string]string, emit Emitter) error {
+	recordType := strings.ToUpper(paramOrDefault(params, "type", "A"))
+	client := NewDNSClient(paramOrDefault(params, "server", d.config.Resolver), d.config.Timeout)
+	client.Family = params["family"]
+	if params["server"] == "" {
+		// The configured or system resolver is chosen by the operator,
+		// not the user, and is often a loopback stub resolver
+		ctx = withoutGuard(ctx)
+	}
+
+	ex, err := client.Query(ctx, target, recordType)
+	if err != nil {
+		emit("", &Event{Type: EventDNSResponse, Data: DNSResponse{
+			Flags:      []string{},
+			Answer:     []DNSRecord{},
+			Authority:  []DNSRecord{},
+			Additional: []DNSRecord{},
+			Server:     client.Server,
+			Error:      err.Error(),
+		}})
+		return err
+	}
+
+	for _, line := range formatDigOutput(ex, recordType, target) {
+		emit(line, nil)
+	}
+	emit("", &Event{Type: EventDNSResponse, Data: ex.Response})
+	return nil
 }
@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// EventDNSResponse is the event type carrying a parsed DNS response
+const EventDNSResponse = "dns_response"
+
+// DNSRecord is a single resource record
+type DNSRecord struct {
+	Name  string `json:"name"`
+	TTL   uint32 `json:"ttl"`
+	Class string `json:"class"`
+	Type  string `json:"type"`
+	Data  string `json:"data"`
+}
+
+// DNSResponse is the structured result of a DNS query
+type DNSResponse struct {
+	Status     string      `json:"status"`
+	Opcode     string      `json:"opcode,omitempty"`
+	ID         int         `json:"id"`
+	Flags      []string    `json:"flags"`
+	Answer     []DNSRecord `json:"answer"`
+	Authority  []DNSRecord `json:"authority"`
+	Additional []DNSRecord `json:"additional"`
+	QueryTime  int         `json:"queryTime"`
+	Server     string      `json:"server,omitempty"`
+	MsgSize    int         `json:"msgSize"`
+	Error      string      `json:"error,omitempty"`
+}
+
+var (
+	// ;; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 12345
+	digHeaderRegex = regexp.MustCompile(`->>HEADER<<- opcode: (\S+), status: (\S+), id: (\d+)`)
+	// ;; flags: qr rd ra; QUERY: 1, ANSWER: 1, AUTHORITY: 0, ADDITIONAL: 1
+	digFlagsRegex = regexp.MustCompile(`^;; flags:([^;]*);`)
+	// ;; ANSWER SECTION:
+	digSectionRegex = regexp.MustCompile(`^;; (\S+) SECTION:$`)
+	// example.com.  3600  IN  A  93.184.216.34
+	digRecordRegex = regexp.MustCompile(`^(\S+)\s+(\d+)\s+(\S+)\s+(\S+)\s+(.*)$`)
+	// ;; Query time: 12 msec
+	digQueryTimeRegex = regexp.MustCompile(`^;; Query time: (\d+) msec`)
+	// ;; SERVER: 127.0.0.53#53(127.0.0.53) (UDP)
+	digServerRegex = regexp.MustCompile(`^;; SERVER: ([^#\s]+)#(\d+)`)
+	// ;; MSG SIZE  rcvd: 56
+	digMsgSizeRegex = regexp.MustCompile(`^;; MSG SIZE\s+rcvd: (\d+)`)
+	// ;; connection timed out; no servers could be reached
+	digErrorRegex = regexp.MustCompile(`^;; (connection timed out.*|communications error.*|no servers could be reached.*)$`)
+)
+
+// DigParser parses dig output into a DNSResponse
+type DigParser struct {
+	response DNSResponse
+	section  string
+	seen     bool
+}
+
+// NewDigParser creates a new DigParser instance
+func NewDigParser() *DigParser {
+	return &DigParser{
+		response: DNSResponse{
+			Flags:      []string{},
+			Answer:     []DNSRecord{},
+			Authority:  []DNSRecord{},
+			Additional: []DNSRecord{},
+		},
+		section: "ANSWER",
+	}
+}
+
+// ParseLine implements Parser
+func (d *DigParser) ParseLine(line string) *Event {
+	r := &d.response
+
+	if m := digHeaderRegex.FindStringSubmatch(line); m != nil {
+		r.Opcode = m[1]
+		r.Status = m[2]
+		r.ID = atoi(m[3])
+		d.seen = true
+		return nil
+	}
+	if m := digFlagsRegex.FindStringSubmatch(line); m != nil {
+		r.Flags = append(r.Flags, strings.Fields(m[1])...)
+		return nil
+	}
+	if m := digSectionRegex.FindStringSubmatch(line); m != nil {
+		d.section = m[1]
+		return nil
+	}
+	if m := digQueryTimeRegex.FindStringSubmatch(line); m != nil {
+		r.QueryTime = atoi(m[1])
+		return nil
+	}
+	if m := digServerRegex.FindStringSubmatch(line); m != nil {
+		r.Server = net.JoinHostPort(m[1], m[2])
+		return nil
+	}
+	if m := digMsgSizeRegex.FindStringSubmatch(line); m != nil {
+		r.MsgSize = atoi(m[1])
+		d.seen = true
+		return nil
+	}
+	if m := digErrorRegex.FindStringSubmatch(line); m != nil {
+		r.Error = m[1]
+		d.seen = true
+		return nil
+	}
+
+	// Remaining comment lines carry nothing we model
+	if strings.HasPrefix(line, ";") {
+		return nil
+	}
+
+	if m := digRecordRegex.FindStringSubmatch(line); m != nil {
+		record := DNSRecord{
+			Name:  m[1],
+			TTL:   uint32(atoi(m[2])),
+			Class: m[3],
+			Type:  m[4],
+			Data:  strings.TrimSpace(m[5]),
+		}
+		switch d.section {
+		case "AUTHORITY":
+			r.Authority = append(r.Authority, record)
+		case "ADDITIONAL":
+			r.Additional = append(r.Additional, record)
+		default:
+			r.Answer = append(r.Answer, record)
+		}
+	}
+
+	return nil
+}
+
+// Finish implements Parser
+func (d *DigParser) Finish() []Event {
+	if !d.seen {
+		return nil
+	}
+	return []Event{{Type: EventDNSResponse, Data: d.response}}
+}
@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDigParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []Event
+	}{
+		{
+			name: "answer with authority and additional sections",
+			output: `
+; <<>> DiG 9.18.28-0ubuntu0.22.04.1-Ubuntu <<>> example.com A
+;; global options: +cmd
+;; Got answer:
+;; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 41856
+;; flags: qr rd ra; QUERY: 1, ANSWER: 2, AUTHORITY: 1, ADDITIONAL: 2
+
+;; OPT PSEUDOSECTION:
+; EDNS: version: 0, flags:; udp: 65494
+;; QUESTION SECTION:
+;example.com.			IN	A
+
+;; ANSWER SECTION:
+example.com.		3600	IN	A	93.184.215.14
+example.com.		3600	IN	A	93.184.215.15
+
+;; AUTHORITY SECTION:
+example.com.		86400	IN	NS	a.iana-servers.net.
+
+;; ADDITIONAL SECTION:
+a.iana-servers.net.	1800	IN	A	199.43.135.53
+
+;; Query time: 12 msec
+;; SERVER: 127.0.0.53#53(127.0.0.53) (UDP)
+;; WHEN: Mon Oct 14 10:02:11 UTC 2024
+;; MSG SIZE  rcvd: 128
+`,
+			want: []Event{{Type: EventDNSResponse, Data: DNSResponse{
+				Status: "NOERROR",
+				Opcode: "QUERY",
+				ID:     41856,
+				Flags:  []string{"qr", "rd", "ra"},
+				Answer: []DNSRecord{
+					{Name: "example.com.", TTL: 3600, Class: "IN", Type: "A", Data: "93.184.215.14"},
+					{Name: "example.com.", TTL: 3600, Class: "IN", Type: "A", Data: "93.184.215.15"},
+				},
+				Authority: []DNSRecord{
+					{Name: "example.com.", TTL: 86400, Class: "IN", Type: "NS", Data: "a.iana-servers.net."},
+				},
+				Additional: []DNSRecord{
+					{Name: "a.iana-servers.net.", TTL: 1800, Class: "IN", Type: "A", Data: "199.43.135.53"},
+				},
+				QueryTime: 12,
+				Server:    "127.0.0.53:53",
+				MsgSize:   128,
+			}}},
+		},
+		{
+			name: "NXDOMAIN over IPv6 with multi-word record data",
+			output: `; <<>> DiG 9.10.6 <<>> @2001:4860:4860::8888 nope.example.com TXT
+;; ->>HEADER<<- opcode: QUERY, status: NXDOMAIN, id: 7
+;; flags: qr rd ra; QUERY: 1, ANSWER: 0, AUTHORITY: 1, ADDITIONAL: 1
+
+;; AUTHORITY SECTION:
+example.com.		3600	IN	SOA	ns.icann.org. noc.dns.icann.org. 2024081464 7200 3600 1209600 3600
+
+;; Query time: 31 msec
+;; SERVER: 2001:4860:4860::8888#53(2001:4860:4860::8888)
+;; MSG SIZE  rcvd: 113`,
+			want: []Event{{Type: EventDNSResponse, Data: DNSResponse{
+				Status: "NXDOMAIN",
+				Opcode: "QUERY",
+				ID:     7,
+				Flags:  []string{"qr", "rd", "ra"},
+				Answer: []DNSRecord{},
+				Authority: []DNSRecord{
+					{Name: "example.com.", TTL: 3600, Class: "IN", Type: "SOA", Data: "ns.icann.org. noc.dns.icann.org. 2024081464 7200 3600 1209600 3600"},
+				},
+				Additional: []DNSRecord{},
+				QueryTime:  31,
+				Server:     "[2001:4860:4860::8888]:53",
+				MsgSize:    113,
+			}}},
+		},
+		{
+			name: "short output",
+			output: `93.184.215.14
+93.184.215.15`,
+			want: nil,
+		},
+		{
+			name: "no server reachable",
+			output: `
+; <<>> DiG 9.18.28 <<>> @192.0.2.1 example.com
+; (1 server found)
+;; global options: +cmd
+;; connection timed out; no servers could be reached
+`,
+			want: []Event{{Type: EventDNSResponse, Data: DNSResponse{
+				Flags:      []string{},
+				Answer:     []DNSRecord{},
+				Authority:  []DNSRecord{},
+				Additional: []DNSRecord{},
+				Error:      "connection timed out; no servers could be reached",
+			}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseOutput(NewDigParser(), tt.output)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events:\n got  %+v\n want %+v", got, tt.want)
+			}
+		})
+	}
+}
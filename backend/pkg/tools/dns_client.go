@@ -0,0 +1,529 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ednsUDPSize is the UDP payload size advertised via EDNS0
+const ednsUDPSize = 1232
+
+// dnsTypes maps record type names to their wire values
+var dnsTypes = map[string]dnsmessage.Type{
+	"A":      dnsmessage.TypeA,
+	"AAAA":   dnsmessage.TypeAAAA,
+	"CAA":    dnsmessage.Type(257),
+	"CNAME":  dnsmessage.TypeCNAME,
+	"DNSKEY": dnsmessage.Type(48),
+	"DS":     dnsmessage.Type(43),
+	"HTTPS":  dnsmessage.Type(65),
+	"MX":     dnsmessage.TypeMX,
+	"NAPTR":  dnsmessage.Type(35),
+	"NS":     dnsmessage.TypeNS,
+	"PTR":    dnsmessage.TypePTR,
+	"SOA":    dnsmessage.TypeSOA,
+	"SRV":    dnsmessage.TypeSRV,
+	"SSHFP":  dnsmessage.Type(44),
+	"SVCB":   dnsmessage.Type(64),
+	"TLSA":   dnsmessage.Type(52),
+	"TXT":    dnsmessage.TypeTXT,
+}
+
+// dnsRCodes maps response codes to the names dig prints
+var dnsRCodes = map[dnsmessage.RCode]string{
+	0:  "NOERROR",
+	1:  "FORMERR",
+	2:  "SERVFAIL",
+	3:  "NXDOMAIN",
+	4:  "NOTIMP",
+	5:  "REFUSED",
+	6:  "YXDOMAIN",
+	7:  "YXRRSET",
+	8:  "NXRRSET",
+	9:  "NOTAUTH",
+	10: "NOTZONE",
+}
+
+// DNSClient performs DNS queries without relying on external binaries
+type DNSClient struct {
+	// Server is the resolver address as host:port
+	Server  string
+	Timeout time.Duration
+	// Family limits queries to IPv4 or IPv6 transport; empty allows either
+	Family string
+}
+
+// NewDNSClient creates a DNSClient that queries server. An empty server
+// selects the first nameserver in /etc/resolv.conf.
+func NewDNSClient(server string, timeout time.Duration) *DNSClient {
+	if server == "" {
+		server = systemResolver()
+	}
+	return &DNSClient{
+		Server:  withDefaultPort(server, "53"),
+		Timeout: timeout,
+	}
+}
+
+// DNSExchange is a completed query and its response
+type DNSExchange struct {
+	Response  DNSResponse
+	Transport string
+}
+
+// Query sends a query for name and recordType, retrying over TCP when the
+// UDP response is truncated
+func (c *DNSClient) Query(ctx context.Context, name, recordType string) (*DNSExchange, error) {
+	qtype, ok := dnsTypes[strings.ToUpper(recordType)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported DNS record type: %s", recordType)
+	}
+
+	// Mirror dig -x for PTR lookups of literal addresses
+	if qtype == dnsmessage.TypePTR {
+		if ip := net.ParseIP(name); ip != nil {
+			name = reverseName(ip)
+		}
+	}
+
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query name: %v", err)
+	}
+
+	id := uint16(rand.Intn(1 << 16))
+	query, err := buildQuery(id, qname, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	transport := "UDP"
+	raw, err := c.exchangeUDP(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var header dnsmessage.Header
+	if header, err = parseHeader(raw); err != nil {
+		return nil, err
+	}
+	if header.Truncated {
+		transport = "TCP"
+		if raw, err = c.exchangeTCP(ctx, query, id); err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := decodeResponse(raw)
+	if err != nil {
+		return nil, err
+	}
+	response.QueryTime = int(time.Since(start).Milliseconds())
+	response.Server = c.Server
+	response.MsgSize = len(raw)
+
+	return &DNSExchange{Response: *response, Transport: transport}, nil
+}
+
+// exchangeUDP sends query over UDP and returns the matching response
+func (c *DNSClient) exchangeUDP(ctx context.Context, query []byte, id uint16) ([]byte, error) {
+	conn, err := dialGuarded(ctx, &net.Dialer{}, familyNetwork("udp", c.Family), c.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		// Ignore stray datagrams that do not answer our query
+		if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+			return append([]byte(nil), buf[:n]...), nil
+		}
+	}
+}
+
+// exchangeTCP sends query over TCP and returns the response
+func (c *DNSClient) exchangeTCP(ctx context.Context, query []byte, id uint16) ([]byte, error) {
+	conn, err := dialGuarded(ctx, &net.Dialer{}, familyNetwork("tcp", c.Family), c.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, contextError(ctx, err)
+	}
+	raw := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, raw); err != nil {
+		return nil, contextError(ctx, err)
+	}
+	if len(raw) < 2 || binary.BigEndian.Uint16(raw) != id {
+		return nil, fmt.Errorf("response ID mismatch")
+	}
+	return raw, nil
+}
+
+// buildQuery packs a recursive query with an EDNS0 OPT record
+func buildQuery(id uint16, name dnsmessage.Name, qtype dnsmessage.Type) ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(ednsUDPSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// parseHeader decodes only the header of a DNS message
+func parseHeader(raw []byte) (dnsmessage.Header, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(raw)
+	if err != nil {
+		return h, fmt.Errorf("invalid DNS response: %v", err)
+	}
+	return h, nil
+}
+
+// decodeResponse converts a wire-format response into a DNSResponse
+func decodeResponse(raw []byte) (*DNSResponse, error) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(raw); err != nil {
+		return nil, fmt.Errorf("invalid DNS response: %v", err)
+	}
+
+	rcode := msg.Header.RCode
+	response := &DNSResponse{
+		Opcode:     opcodeName(msg.Header.OpCode),
+		ID:         int(msg.Header.ID),
+		Flags:      headerFlags(msg.Header),
+		Answer:     convertRecords(msg.Answers),
+		Authority:  convertRecords(msg.Authorities),
+		Additional: []DNSRecord{},
+	}
+
+	for _, r := range msg.Additionals {
+		if r.Header.Type == dnsmessage.TypeOPT {
+			// The OPT record carries the upper bits of extended rcodes
+			rcode |= dnsmessage.RCode(r.Header.TTL>>24) << 4
+			continue
+		}
+		response.Additional = append(response.Additional, convertRecord(r))
+	}
+
+	response.Status = dnsRCodes[rcode]
+	if response.Status == "" {
+		response.Status = fmt.Sprintf("RCODE%d", rcode)
+	}
+	return response, nil
+}
+
+// headerFlags returns the header flags in dig's order
+func headerFlags(h dnsmessage.Header) []string {
+	flags := []string{}
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{h.Response, "qr"},
+		{h.Authoritative, "aa"},
+		{h.Truncated, "tc"},
+		{h.RecursionDesired, "rd"},
+		{h.RecursionAvailable, "ra"},
+		{h.AuthenticData, "ad"},
+		{h.CheckingDisabled, "cd"},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	return flags
+}
+
+// convertRecords converts wire resources into DNSRecords
+func convertRecords(resources []dnsmessage.Resource) []DNSRecord {
+	records := make([]DNSRecord, 0, len(resources))
+	for _, r := range resources {
+		records = append(records, convertRecord(r))
+	}
+	return records
+}
+
+// convertRecord converts a single wire resource into a DNSRecord
+func convertRecord(r dnsmessage.Resource) DNSRecord {
+	class := "IN"
+	if r.Header.Class != dnsmessage.ClassINET {
+		class = fmt.Sprintf("CLASS%d", r.Header.Class)
+	}
+	return DNSRecord{
+		Name:  r.Header.Name.String(),
+		TTL:   r.Header.TTL,
+		Class: class,
+		Type:  typeName(r.Header.Type),
+		Data:  formatRData(r.Header.Type, r.Body),
+	}
+}
+
+// formatRData renders record data in zone-file presentation format
+func formatRData(t dnsmessage.Type, body dnsmessage.ResourceBody) string {
+	switch b := body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(b.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return net.IP(b.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		return b.CNAME.String()
+	case *dnsmessage.NSResource:
+		return b.NS.String()
+	case *dnsmessage.PTRResource:
+		return b.PTR.String()
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", b.Pref, b.MX)
+	case *dnsmessage.SOAResource:
+		return fmt.Sprintf("%s %s %d %d %d %d %d", b.NS, b.MBox, b.Serial, b.Refresh, b.Retry, b.Expire, b.MinTTL)
+	case *dnsmessage.SRVResource:
+		return fmt.Sprintf("%d %d %d %s", b.Priority, b.Weight, b.Port, b.Target)
+	case *dnsmessage.TXTResource:
+		parts := make([]string, len(b.TXT))
+		for i, s := range b.TXT {
+			parts[i] = quoteCharString(s)
+		}
+		return strings.Join(parts, " ")
+	case *dnsmessage.UnknownResource:
+		return formatUnknownRData(t, b.Data)
+	}
+	return ""
+}
+
+// formatUnknownRData renders types dnsmessage does not decode itself
+func formatUnknownRData(t dnsmessage.Type, data []byte) string {
+	switch t {
+	case dnsTypes["CAA"]:
+		if len(data) >= 2 && len(data) >= 2+int(data[1]) {
+			tagEnd := 2 + int(data[1])
+			return fmt.Sprintf("%d %s %s", data[0], data[2:tagEnd], quoteCharString(string(data[tagEnd:])))
+		}
+	case dnsTypes["DS"]:
+		if len(data) >= 4 {
+			return fmt.Sprintf("%d %d %d %s", binary.BigEndian.Uint16(data), data[2], data[3], strings.ToUpper(hex.EncodeToString(data[4:])))
+		}
+	case dnsTypes["DNSKEY"]:
+		if len(data) >= 4 {
+			return fmt.Sprintf("%d %d %d %s", binary.BigEndian.Uint16(data), data[2], data[3], base64.StdEncoding.EncodeToString(data[4:]))
+		}
+	case dnsTypes["TLSA"]:
+		if len(data) >= 3 {
+			return fmt.Sprintf("%d %d %d %s", data[0], data[1], data[2], strings.ToUpper(hex.EncodeToString(data[3:])))
+		}
+	case dnsTypes["SSHFP"]:
+		if len(data) >= 2 {
+			return fmt.Sprintf("%d %d %s", data[0], data[1], strings.ToUpper(hex.EncodeToString(data[2:])))
+		}
+	}
+
+	// RFC 3597 generic encoding
+	return fmt.Sprintf(`\# %d %s`, len(data), hex.EncodeToString(data))
+}
+
+// quoteCharString quotes a DNS character-string for presentation
+func quoteCharString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 32 || c > 126:
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// typeName returns the presentation name of a record type
+func typeName(t dnsmessage.Type) string {
+	for name, v := range dnsTypes {
+		if v == t {
+			return name
+		}
+	}
+	return fmt.Sprintf("TYPE%d", t)
+}
+
+// opcodeName returns the presentation name of an opcode
+func opcodeName(op dnsmessage.OpCode) string {
+	switch op {
+	case 0:
+		return "QUERY"
+	case 2:
+		return "STATUS"
+	case 4:
+		return "NOTIFY"
+	case 5:
+		return "UPDATE"
+	}
+	return fmt.Sprintf("OPCODE%d", op)
+}
+
+// reverseName returns the in-addr.arpa or ip6.arpa name for ip
+func reverseName(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", v4[3], v4[2], v4[1], v4[0])
+	}
+
+	const digits = "0123456789abcdef"
+	var b strings.Builder
+	for i := len(ip) - 1; i >= 0; i-- {
+		b.WriteByte(digits[ip[i]&0x0f])
+		b.WriteByte('.')
+		b.WriteByte(digits[ip[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String()
+}
+
+// systemResolver returns the first nameserver in /etc/resolv.conf
+func systemResolver() string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return "127.0.0.1:53"
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53")
+		}
+	}
+	return "127.0.0.1:53"
+}
+
+// withDefaultPort appends port to addr when it does not carry one
+func withDefaultPort(addr, port string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(strings.Trim(addr, "[]"), port)
+}
+
+// closeOnDone closes conn when ctx is done so blocked I/O returns. The
+// returned function stops the watcher.
+func closeOnDone(ctx context.Context, conn net.Conn) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// contextError prefers the context's error when it caused err
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// formatDigOutput renders an exchange as the text dig would print, so raw
+// output looks the same whichever engine ran the query
+func formatDigOutput(ex *DNSExchange, recordType, name string) []string {
+	r := ex.Response
+	lines := []string{
+		fmt.Sprintf("; <<>> native <<>> %s %s @%s", recordType, name, r.Server),
+		fmt.Sprintf(";; ->>HEADER<<- opcode: %s, status: %s, id: %d", r.Opcode, r.Status, r.ID),
+		fmt.Sprintf(";; flags: %s; QUERY: 1, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d",
+			strings.Join(r.Flags, " "), len(r.Answer), len(r.Authority), len(r.Additional)),
+	}
+
+	for _, section := range []struct {
+		name    string
+		records []DNSRecord
+	}{
+		{"ANSWER", r.Answer},
+		{"AUTHORITY", r.Authority},
+		{"ADDITIONAL", r.Additional},
+	} {
+		if len(section.records) == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(";; %s SECTION:", section.name))
+		for _, rec := range section.records {
+			lines = append(lines, strings.Join([]string{rec.Name, strconv.FormatUint(uint64(rec.TTL), 10), rec.Class, rec.Type, rec.Data}, "\t"))
+		}
+	}
+
+	host, port, _ := net.SplitHostPort(r.Server)
+	lines = append(lines,
+		fmt.Sprintf(";; Query time: %d msec", r.QueryTime),
+		fmt.Sprintf(";; SERVER: %s#%s(%s) (%s)", host, port, host, ex.Transport),
+		fmt.Sprintf(";; MSG SIZE  rcvd: %d", r.MsgSize),
+	)
+	return lines
+}
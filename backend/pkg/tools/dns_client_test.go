@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testDNSServer answers queries over UDP and TCP on the same loopback
+// port. Names starting with "big." get a truncated reply over UDP and
+// the full answer over TCP; "missing." names get NXDOMAIN.
+type testDNSServer struct {
+	addr string
+	udp  net.PacketConn
+	tcp  net.Listener
+}
+
+func newTestDNSServer(t *testing.T) *testDNSServer {
+	t.Helper()
+
+	// The TCP listener takes the UDP socket's port, which may be in use
+	for attempt := 0; attempt < 10; attempt++ {
+		udp, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen udp: %v", err)
+		}
+		tcp, err := net.Listen("tcp4", udp.LocalAddr().String())
+		if err != nil {
+			udp.Close()
+			continue
+		}
+
+		s := &testDNSServer{addr: udp.LocalAddr().String(), udp: udp, tcp: tcp}
+		t.Cleanup(func() {
+			udp.Close()
+			tcp.Close()
+		})
+		go s.serveUDP(t)
+		go s.serveTCP(t)
+		return s
+	}
+	t.Fatal("no free port for UDP and TCP")
+	return nil
+}
+
+func (s *testDNSServer) serveUDP(t *testing.T) {
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if reply := answer(t, buf[:n], true); reply != nil {
+			s.udp.WriteTo(reply, from)
+		}
+	}
+}
+
+func (s *testDNSServer) serveTCP(t *testing.T) {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err != nil {
+				return
+			}
+			query := make([]byte, binary.BigEndian.Uint16(length[:]))
+			if _, err := io.ReadFull(conn, query); err != nil {
+				return
+			}
+			reply := answer(t, query, false)
+			binary.BigEndian.PutUint16(length[:], uint16(len(reply)))
+			conn.Write(append(length[:], reply...))
+		}()
+	}
+}
+
+// answer builds the reply to a query
+func answer(t *testing.T, query []byte, udp bool) []byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil {
+		t.Errorf("server: bad query: %v", err)
+		return nil
+	}
+	q := msg.Questions[0]
+	name := q.Name.String()
+
+	header := dnsmessage.Header{ID: msg.Header.ID, Response: true, RecursionDesired: true, RecursionAvailable: true}
+	var answers []dnsmessage.Resource
+	switch {
+	case name == "missing.example.":
+		header.RCode = dnsmessage.RCodeNameError
+	case name == "big.example." && udp:
+		header.Truncated = true
+	default:
+		count := 1
+		if name == "big.example." {
+			count = 3
+		}
+		for i := 0; i < count; i++ {
+			answers = append(answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 300},
+				Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, byte(i + 1)}},
+			})
+		}
+	}
+
+	reply := dnsmessage.Message{Header: header, Questions: msg.Questions, Answers: answers}
+	raw, err := reply.Pack()
+	if err != nil {
+		t.Errorf("server: pack reply: %v", err)
+		return nil
+	}
+	return raw
+}
+
+func TestDNSClientQuery(t *testing.T) {
+	server := newTestDNSServer(t)
+
+	tests := []struct {
+		name      string
+		query     string
+		transport string
+		status    string
+		answers   []string
+	}{
+		{"answer over UDP", "small.example", "UDP", "NOERROR", []string{"192.0.2.1"}},
+		{"truncated reply retried over TCP", "big.example", "TCP", "NOERROR", []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}},
+		{"name error", "missing.example", "UDP", "NXDOMAIN", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewDNSClient(server.addr, 2*time.Second)
+			ex, err := client.Query(context.Background(), tt.query, "A")
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if ex.Transport != tt.transport {
+				t.Errorf("transport = %s, want %s", ex.Transport, tt.transport)
+			}
+			if ex.Response.Status != tt.status {
+				t.Errorf("status = %s, want %s", ex.Response.Status, tt.status)
+			}
+			answers := []string{}
+			for _, r := range ex.Response.Answer {
+				if r.Name != tt.query+"." || r.Type != "A" || r.TTL != 300 {
+					t.Errorf("unexpected record %+v", r)
+				}
+				answers = append(answers, r.Data)
+			}
+			if !reflect.DeepEqual(answers, tt.answers) {
+				t.Errorf("answers = %v, want %v", answers, tt.answers)
+			}
+			if ex.Response.Server != server.addr {
+				t.Errorf("server = %s, want %s", ex.Response.Server, server.addr)
+			}
+		})
+	}
+}
+
+func TestDNSClientTimeout(t *testing.T) {
+	// A socket that never answers
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	defer conn.Close()
+
+	client := NewDNSClient(conn.LocalAddr().String(), 100*time.Millisecond)
+	start := time.Now()
+	if _, err := client.Query(context.Background(), "example.com", "A"); err == nil {
+		t.Fatal("Query succeeded without a server")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Query took %v, want about the 100ms timeout", elapsed)
+	}
+}
@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"net/netip"
+)
+
+// Guard decides which addresses in-process tools may connect to. The
+// server installs one in the run's context to enforce its target policy;
+// without one, tools resolve and dial hosts themselves.
+type Guard interface {
+	// Resolve returns the addresses the tool may connect to for host,
+	// which is a name or an address literal, or an error if it may not
+	// connect to host at all
+	Resolve(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// Destination is implemented by tools that do not connect to their
+// target, such as dig, which sends its query to a DNS server
+type Destination interface {
+	// Destination returns the host params direct the tool to connect
+	// to, or "" when it uses one chosen by the server's configuration
+	Destination(target string, params map[string]string) string
+}
+
+// guardKey is the context key of a run's Guard
+type guardKey struct{}
+
+// WithGuard returns ctx with g attached
+func WithGuard(ctx context.Context, g Guard) context.Context {
+	return context.WithValue(ctx, guardKey{}, g)
+}
+
+// guardFrom returns the Guard attached to ctx, if any
+func guardFrom(ctx context.Context) Guard {
+	g, _ := ctx.Value(guardKey{}).(Guard)
+	return g
+}
+
+// withoutGuard returns ctx with any guard removed, for connections to
+// hosts named by the server's configuration rather than by the user
+func withoutGuard(ctx context.Context) context.Context {
+	return context.WithValue(ctx, guardKey{}, nil)
+}
+
+// lookupHost returns the addresses of host, asking the guard in ctx when
+// there is one. Literal IPv6 addresses keep their zone.
+func lookupHost(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if g := guardFrom(ctx); g != nil {
+		addrs, err := g.Resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		ips := make([]net.IPAddr, len(addrs))
+		for i, addr := range addrs {
+			ips[i] = net.IPAddr{IP: net.IP(addr.AsSlice()), Zone: addr.Zone()}
+		}
+		return ips, nil
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []net.IPAddr{{IP: net.IP(addr.Unmap().AsSlice()), Zone: addr.Zone()}}, nil
+	}
+	return net.DefaultResolver.LookupIPAddr(ctx, host)
+}
+
+// dialGuarded connects to address with d. When ctx carries a guard it
+// dials the addresses the guard returns for the host, one after another,
+// instead of letting d resolve the host, so the connection cannot reach
+// an address the guard has not vetted.
+func dialGuarded(ctx context.Context, d *net.Dialer, network, address string) (net.Conn, error) {
+	g := guardFrom(ctx)
+	if g == nil {
+		return d.DialContext(ctx, network, address)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Report the lookup to an HTTP client trace, since d never sees the name
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	addrs, err := g.Resolve(ctx, host)
+	if trace != nil && trace.DNSDone != nil {
+		info := httptrace.DNSDoneInfo{Err: err}
+		for _, addr := range addrs {
+			info.Addrs = append(info.Addrs, net.IPAddr{IP: net.IP(addr.AsSlice()), Zone: addr.Zone()})
+		}
+		trace.DNSDone(info)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,315 @@
+package tools
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTP event types
+const (
+	EventHTTPRedirect = "http_redirect"
+	EventHTTPResponse = "http_response"
+)
+
+// maxHTTPBody caps how much of a response body is read to measure it
+const maxHTTPBody = 10 << 20
+
+// httpReportedHeaders lists the response headers included in results
+var httpReportedHeaders = []string{
+	"Age", "Cache-Control", "Content-Encoding", "Content-Length", "Content-Type",
+	"Date", "ETag", "Last-Modified", "Location", "Server",
+	"Strict-Transport-Security", "Via", "X-Cache",
+}
+
+// HTTPTiming breaks down where a request spent its time, in milliseconds
+type HTTPTiming struct {
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	TLS     float64 `json:"tls"`
+	TTFB    float64 `json:"ttfb"`
+	Total   float64 `json:"total"`
+}
+
+// HTTPRedirect is one hop of a redirect chain
+type HTTPRedirect struct {
+	URL      string     `json:"url"`
+	Status   int        `json:"status"`
+	Location string     `json:"location"`
+	Timing   HTTPTiming `json:"timing"`
+}
+
+// HTTPResponse is the structured result of an HTTP probe
+type HTTPResponse struct {
+	URL        string            `json:"url"`
+	Method     string            `json:"method"`
+	Status     int               `json:"status"`
+	StatusText string            `json:"statusText"`
+	Proto      string            `json:"proto"`
+	RemoteAddr string            `json:"remoteAddr,omitempty"`
+	Timing     HTTPTiming        `json:"timing"`
+	Headers    map[string]string `json:"headers"`
+	Redirects  []HTTPRedirect    `json:"redirects"`
+	BodySize   int64             `json:"bodySize"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// HTTPTool probes an HTTP or HTTPS endpoint
+type HTTPTool struct{}
+
+// NewHTTPTool creates a new HTTPTool instance
+func NewHTTPTool() *HTTPTool {
+	return &HTTPTool{}
+}
+
+// Name implements Tool
+func (h *HTTPTool) Name() string { return "http" }
+
+// Description implements Tool
+func (h *HTTPTool) Description() string { return "Probe an HTTP endpoint with a timing breakdown" }
+
+// Parameters implements Tool
+func (h *HTTPTool) Parameters() []Parameter {
+	return []Parameter{
+		{Name: "scheme", Type: ParameterEnum, Description: "URL scheme", Default: "https", Values: []string{"http", "https"}},
+		{Name: "port", Type: ParameterInt, Description: "Port to connect to", Min: 1, Max: 65535},
+		{Name: "path", Type: ParameterString, Description: "Request path and query", Default: "/"},
+		{Name: "method", Type: ParameterEnum, Description: "Request method", Default: "GET", Values: []string{"GET", "HEAD", "OPTIONS"}},
+		{Name: "headers", Type: ParameterString, Description: "Request headers, one \"Name: value\" per line"},
+		{Name: "redirects", Type: ParameterInt, Description: "Maximum redirects to follow", Default: "5", Min: 0, Max: 10},
+		{Name: "timeout", Type: ParameterInt, Description: "Seconds to wait for the whole probe", Default: "10", Min: 1, Max: 30},
+		{Name: "insecure", Type: ParameterBool, Description: "Skip TLS certificate verification", Default: "false"},
+	}
+}
+
+// Validate implements Tool
+func (h *HTTPTool) Validate(params map[string]string) error {
+	if scheme, ok := params["scheme"]; ok && scheme != "http" && scheme != "https" {
+		return fmt.Errorf("scheme must be http or https")
+	}
+	if path, ok := params["path"]; ok {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("path must start with /")
+		}
+		if len(path) > 2048 {
+			return fmt.Errorf("path too long")
+		}
+		if strings.IndexFunc(path, func(r rune) bool { return r <= ' ' || r == 0x7f }) >= 0 {
+			return fmt.Errorf("path contains invalid characters")
+		}
+		if _, err := url.ParseRequestURI(path); err != nil {
+			return fmt.Errorf("invalid path")
+		}
+	}
+	if err := validateIntRange(params, "redirects", 0, 10); err != nil {
+		return err
+	}
+	if err := validateIntRange(params, "timeout", 1, 30); err != nil {
+		return err
+	}
+	if _, err := ParseHeaderLines(params["headers"]); err != nil {
+		return err
+	}
+	return validateBool(params, "insecure")
+}
+
+// Command implements Tool
+func (h *HTTPTool) Command(target string, params map[string]string) (Command, error) {
+	return Command{}, fmt.Errorf("%s has no external command", h.Name())
+}
+
+// NewParser implements Tool
+func (h *HTTPTool) NewParser() Parser { return NopParser{} }
+
+// Native implements Runner
+func (h *HTTPTool) Native(params map[string]string) bool { return true }
+
+// Run implements Runner
+func (h *HTTPTool) Run(ctx context.Context, target string, params map[string]string, emit Emitter) error {
+	method := strings.ToUpper(paramOrDefault(params, "method", "GET"))
+	maxRedirects := intParam(params, "redirects", 5)
+	timeout := time.Duration(intParam(params, "timeout", 10)) * time.Second
+	headers, err := ParseHeaderLines(params["headers"])
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	transport := &http.Transport{
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialGuarded(ctx, dialer, network, address)
+		},
+		DisableKeepAlives: true,
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: params["insecure"] == "true"},
+	}
+	defer transport.CloseIdleConnections()
+
+	target = buildProbeURL(target, params)
+	emit(fmt.Sprintf("* Probing %s %s", method, target), nil)
+
+	result := HTTPResponse{
+		Method:    method,
+		Headers:   map[string]string{},
+		Redirects: []HTTPRedirect{},
+	}
+	start := time.Now()
+
+	for hop := 0; ; hop++ {
+		result.URL = target
+		resp, timing, remoteAddr, err := probeOnce(ctx, transport, method, target, headers)
+		if err != nil {
+			result.Error = err.Error()
+			result.Timing.Total = msSince(start)
+			emit(fmt.Sprintf("* Request failed: %v", err), &Event{Type: EventHTTPResponse, Data: result})
+			return err
+		}
+
+		location := resp.Header.Get("Location")
+		if isRedirect(resp.StatusCode) && location != "" && hop < maxRedirects {
+			resp.Body.Close()
+			next, err := resp.Request.URL.Parse(location)
+			if err != nil || (next.Scheme != "http" && next.Scheme != "https") {
+				result.Error = fmt.Sprintf("invalid redirect location: %s", location)
+				emit("* "+result.Error, &Event{Type: EventHTTPResponse, Data: result})
+				return fmt.Errorf("%s", result.Error)
+			}
+
+			redirect := HTTPRedirect{URL: target, Status: resp.StatusCode, Location: next.String(), Timing: timing}
+			result.Redirects = append(result.Redirects, redirect)
+			emit(fmt.Sprintf("< %s %d -> %s", resp.Proto, resp.StatusCode, redirect.Location),
+				&Event{Type: EventHTTPRedirect, Data: redirect})
+
+			target = next.String()
+			continue
+		}
+
+		size, _ := io.Copy(io.Discard, io.LimitReader(resp.Body, maxHTTPBody))
+		resp.Body.Close()
+
+		timing.Total = msSince(start)
+		result.Status = resp.StatusCode
+		result.StatusText = http.StatusText(resp.StatusCode)
+		result.Proto = resp.Proto
+		result.Timing = timing
+		result.BodySize = size
+		result.RemoteAddr = remoteAddr
+		for _, name := range httpReportedHeaders {
+			if v := resp.Header.Get(name); v != "" {
+				result.Headers[name] = v
+			}
+		}
+
+		emit(fmt.Sprintf("< %s %s", resp.Proto, resp.Status), nil)
+		for _, name := range httpReportedHeaders {
+			if v, ok := result.Headers[name]; ok {
+				emit(fmt.Sprintf("< %s: %s", name, v), nil)
+			}
+		}
+		emit(fmt.Sprintf("* dns=%.1fms connect=%.1fms tls=%.1fms ttfb=%.1fms total=%.1fms size=%d bytes",
+			timing.DNS, timing.Connect, timing.TLS, timing.TTFB, timing.Total, size),
+			&Event{Type: EventHTTPResponse, Data: result})
+		return nil
+	}
+}
+
+// probeOnce performs a single request without following redirects and
+// returns the response, its timing and the peer address
+func probeOnce(ctx context.Context, transport *http.Transport, method, target string, headers http.Header) (*http.Response, HTTPTiming, string, error) {
+	var (
+		timing                                  HTTPTiming
+		dnsStart, connStart, tlsStart, reqStart time.Time
+		remoteAddr                              string
+	)
+
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:           func(httptrace.DNSDoneInfo) { timing.DNS = msSince(dnsStart) },
+		ConnectStart:      func(string, string) { connStart = time.Now() },
+		ConnectDone:       func(string, string, error) { timing.Connect = msSince(connStart) },
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { timing.TLS = msSince(tlsStart) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			remoteAddr = info.Conn.RemoteAddr().String()
+		},
+		GotFirstResponseByte: func() { timing.TTFB = msSince(reqStart) },
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), method, target, nil)
+	if err != nil {
+		return nil, timing, "", err
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", "net-tools-gui")
+	}
+
+	reqStart = time.Now()
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, timing, "", err
+	}
+	timing.Total = msSince(reqStart)
+	return resp, timing, remoteAddr, nil
+}
+
+// buildProbeURL assembles the request URL from target and parameters
+func buildProbeURL(target string, params map[string]string) string {
+	host := target
+	if port := params["port"]; port != "" {
+		host = net.JoinHostPort(target, port)
+	} else if strings.Contains(target, ":") {
+		host = "[" + target + "]"
+	}
+	u := url.URL{Scheme: paramOrDefault(params, "scheme", "https"), Host: host}
+	return u.String() + paramOrDefault(params, "path", "/")
+}
+
+// ParseHeaderLines parses "Name: value" lines into a header set
+func ParseHeaderLines(s string) (http.Header, error) {
+	headers := http.Header{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header line: %q", line)
+		}
+		name = strings.TrimSpace(name)
+		if name == "" || strings.IndexFunc(name, func(r rune) bool { return r <= ' ' || r >= 0x7f }) >= 0 {
+			return nil, fmt.Errorf("invalid header name: %q", name)
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+// isRedirect reports whether status is a redirect carrying a Location
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// msSince returns the milliseconds elapsed since t
+func msSince(t time.Time) float64 {
+	return float64(time.Since(t).Microseconds()) / 1000
+}
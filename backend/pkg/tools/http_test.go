@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// ttfbDelay is how long the test server waits before answering the
+// final request of a redirect chain
+const ttfbDelay = 50 * time.Millisecond
+
+// newRedirectServer serves /a -> /b -> /c, where /c answers after
+// ttfbDelay with a short body
+func newRedirectServer(t *testing.T, tls bool) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/b", http.StatusFound)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/c?x=1", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(ttfbDelay)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello"))
+	})
+
+	var s *httptest.Server
+	if tls {
+		s = httptest.NewTLSServer(mux)
+	} else {
+		s = httptest.NewServer(mux)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// runHTTP probes path on s and returns the redirect and response events
+func runHTTP(t *testing.T, s *httptest.Server, params map[string]string) ([]HTTPRedirect, *HTTPResponse, error) {
+	t.Helper()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params["scheme"] = u.Scheme
+	params["port"] = u.Port()
+
+	var redirects []HTTPRedirect
+	var response *HTTPResponse
+	emit := func(line string, event *Event) {
+		if event == nil {
+			return
+		}
+		switch data := event.Data.(type) {
+		case HTTPRedirect:
+			redirects = append(redirects, data)
+		case HTTPResponse:
+			response = &data
+		}
+	}
+	err = NewHTTPTool().Run(context.Background(), u.Hostname(), params, emit)
+	return redirects, response, err
+}
+
+func TestHTTPRedirectChain(t *testing.T) {
+	s := newRedirectServer(t, false)
+
+	redirects, resp, err := runHTTP(t, s, map[string]string{"path": "/a"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := []struct {
+		url      string
+		status   int
+		location string
+	}{
+		{s.URL + "/a", http.StatusFound, s.URL + "/b"},
+		{s.URL + "/b", http.StatusMovedPermanently, s.URL + "/c?x=1"},
+	}
+	if len(redirects) != len(want) {
+		t.Fatalf("got %d redirects, want %d: %+v", len(redirects), len(want), redirects)
+	}
+	for i, w := range want {
+		r := redirects[i]
+		if r.URL != w.url || r.Status != w.status || r.Location != w.location {
+			t.Errorf("redirect %d = %s %d -> %s, want %s %d -> %s", i, r.URL, r.Status, r.Location, w.url, w.status, w.location)
+		}
+	}
+
+	if resp == nil {
+		t.Fatal("no response event")
+	}
+	if resp.URL != s.URL+"/c?x=1" || resp.Status != http.StatusOK || resp.Method != "GET" {
+		t.Errorf("response = %s %s %d, want GET %s/c?x=1 200", resp.Method, resp.URL, resp.Status, s.URL)
+	}
+	if resp.BodySize != 5 {
+		t.Errorf("body size = %d, want 5", resp.BodySize)
+	}
+	if resp.Headers["Content-Type"] != "text/plain" {
+		t.Errorf("Content-Type = %q, want text/plain", resp.Headers["Content-Type"])
+	}
+	if len(resp.Redirects) != 2 {
+		t.Errorf("response carries %d redirects, want 2", len(resp.Redirects))
+	}
+	if resp.RemoteAddr != s.Listener.Addr().String() {
+		t.Errorf("remote address = %s, want %s", resp.RemoteAddr, s.Listener.Addr())
+	}
+}
+
+func TestHTTPTiming(t *testing.T) {
+	s := newRedirectServer(t, true)
+
+	_, resp, err := runHTTP(t, s, map[string]string{"path": "/c", "insecure": "true"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	timing := resp.Timing
+	minTTFB := float64(ttfbDelay.Milliseconds())
+	if timing.TTFB < minTTFB {
+		t.Errorf("ttfb = %.1fms, want at least the server's %.0fms delay", timing.TTFB, minTTFB)
+	}
+	if timing.TLS <= 0 {
+		t.Errorf("tls = %.1fms, want a measured handshake", timing.TLS)
+	}
+	if timing.Total < timing.TTFB || timing.Total < timing.TLS {
+		t.Errorf("total %.1fms is less than a phase: %+v", timing.Total, timing)
+	}
+	if timing.DNS != 0 {
+		t.Errorf("dns = %.1fms, want 0 for an address literal", timing.DNS)
+	}
+}
+
+func TestHTTPRedirectLimit(t *testing.T) {
+	s := newRedirectServer(t, false)
+
+	redirects, resp, err := runHTTP(t, s, map[string]string{"path": "/a", "redirects": "1"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(redirects) != 1 {
+		t.Errorf("followed %d redirects, want 1", len(redirects))
+	}
+	if resp.Status != http.StatusMovedPermanently || resp.Headers["Location"] != "/c?x=1" {
+		t.Errorf("response = %d to %q, want the unfollowed 301 to /c?x=1", resp.Status, resp.Headers["Location"])
+	}
+}
@@ -1,17 +1,134 @@
 package tools
 
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+)
+
+// PingConfig holds ping tool settings
+type PingConfig struct {
+	// Engine is the default engine, either "exec" or "native"
+	Engine string `json:"engine"`
+}
+
 // PingTool handles ping commands
 type PingTool struct {
-	// TODO: Add ping configuration
+	path   string
+	config PingConfig
 }
 
 // NewPingTool creates a new PingTool instance
-func NewPingTool() *PingTool {
-	return &PingTool{}
+func NewPingTool(config PingConfig) *PingTool {
+	path := "/usr/bin/ping"
+	if runtime.GOOS == "windows" {
+		path = "ping"
+	}
+	if config.Engine == "" {
+		config.Engine = EngineExec
+	}
+	return &PingTool{path: path, config: config}
+}
+
+// Name implements Tool
+func (p *PingTool) Name() string { return "ping" }
+
+// Description implements Tool
+func (p *PingTool) Description() string { return "Send ICMP echo requests to a host" }
+
+// Parameters implements Tool
+func (p *PingTool) Parameters() []Parameter {
+	return []Parameter{
+		{Name: "count", Type: ParameterInt, Description: "Number of echo requests to send", Default: "4", Min: 1, Max: 10},
+		{Name: "interval", Type: ParameterInt, Description: "Milliseconds between requests", Default: "1000", Min: 200, Max: 5000},
+		{Name: "size", Type: ParameterInt, Description: "Payload size in bytes", Default: "56", Min: 0, Max: 1472},
+		{Name: "ttl", Type: ParameterInt, Description: "IP time to live", Default: "64", Min: 1, Max: 255},
+		{Name: "timeout", Type: ParameterInt, Description: "Seconds to wait for each reply", Default: "2", Min: 1, Max: 10},
+		{Name: "engine", Type: ParameterEnum, Description: "Run the system ping binary or the built-in pinger", Default: p.config.Engine, Values: []string{EngineExec, EngineNative}},
+		familyParameter,
+	}
+}
+
+// Validate implements Tool
+func (p *PingTool) Validate(params map[string]string) error {
+	if count, ok := params["count"]; ok {
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return fmt.Errorf("invalid ping count")
+		}
+		if n < 1 || n > 10 {
+			return fmt.Errorf("ping count must be between 1 and 10")
+		}
+	}
+	for _, param := range p.Parameters() {
+		if param.Type != ParameterInt || param.Name == "count" {
+			continue
+		}
+		if err := validateIntRange(params, param.Name, param.Min, param.Max); err != nil {
+			return err
+		}
+	}
+	if err := validateFamily(params); err != nil {
+		return err
+	}
+	return validateEngine(params)
+}
+
+// Command implements Tool
+func (p *PingTool) Command(target string, params map[string]string) (Command, error) {
+	count := paramOrDefault(params, "count", "4")
+	interval, size, ttl := params["interval"], params["size"], params["ttl"]
+	timeout := paramOrDefault(params, "timeout", "2")
+
+	var args []string
+	if family := params["family"]; family != "" {
+		if runtime.GOOS == "darwin" {
+			return Command{}, fmt.Errorf("address family selection is not supported on darwin")
+		}
+		args = append(args, "-"+family)
+	}
+	switch runtime.GOOS {
+	case "windows":
+		args = append(args, "-n", count, "-w", strconv.Itoa(intParam(params, "timeout", 2)*1000))
+		args = appendFlag(args, "-l", size)
+		args = appendFlag(args, "-i", ttl)
+	case "darwin":
+		args = append(args, "-c", count, "-t", "2")
+		args = appendFlag(args, "-i", msToSeconds(interval))
+		args = appendFlag(args, "-s", size)
+		args = appendFlag(args, "-m", ttl)
+	default: // linux
+		args = append(args, "-c", count, "-W", timeout, "-O")
+		args = appendFlag(args, "-i", msToSeconds(interval))
+		args = appendFlag(args, "-s", size)
+		args = appendFlag(args, "-t", ttl)
+	}
+	args = append(args, target)
+
+	return Command{Path: p.path, Args: args}, nil
+}
+
+// NewParser implements Tool
+func (p *PingTool) NewParser() Parser { return NewPingParser() }
+
+// Native implements Runner
+func (p *PingTool) Native(params map[string]string) bool {
+	return paramOrDefault(params, "engine", p.config.Engine) == EngineNative
+}
+
+// appendFlag appends flag and value to args when value is set
+func appendFlag(args []string, flag, value string) []string {
+	if value == "" {
+		return args
+	}
+	return append(args, flag, value)
 }
 
-// Execute runs a ping command
-func (p *PingTool) Execute(target string, params map[string]string) (string, error) {
-	// TODO: Implement ping command execution
-	return "", nil
+// msToSeconds converts a millisecond parameter to ping's seconds notation
+func msToSeconds(ms string) string {
+	n, err := strconv.Atoi(ms)
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatFloat(float64(n)/1000, 'f', -1, 64)
 }
@@ -0,0 +1,353 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// IANA protocol numbers used when parsing ICMP messages
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+)
+
+// pingPacket is an echo reply read from the socket
+type pingPacket struct {
+	seq   int
+	ttl   int
+	bytes int
+	from  net.IP
+	at    time.Time
+}
+
+// pinger sends ICMP echo requests over a single socket
+type pinger struct {
+	conn       *icmp.PacketConn
+	dst        net.IPAddr
+	ipv6       bool
+	privileged bool
+	id         int
+}
+
+// Run implements Runner using an in-process ICMP pinger
+func (p *PingTool) Run(ctx context.Context, target string, params map[string]string, emit Emitter) error {
+	count := intParam(params, "count", 4)
+	interval := time.Duration(intParam(params, "interval", 1000)) * time.Millisecond
+	size := intParam(params, "size", 56)
+	ttl := intParam(params, "ttl", 64)
+	timeout := time.Duration(intParam(params, "timeout", 2)) * time.Second
+
+	dst, err := resolveTarget(ctx, target, params["family"])
+	if err != nil {
+		return err
+	}
+
+	pg, err := newPinger(dst, ttl)
+	if err != nil {
+		return err
+	}
+	defer pg.conn.Close()
+
+	emit(fmt.Sprintf("PING %s (%s) %d(%d) bytes of data.", target, dst, size, size+pg.headerOverhead()), nil)
+
+	replies := make(chan pingPacket)
+	stop := make(chan struct{})
+	defer close(stop)
+	go pg.readReplies(replies, stop)
+
+	start := time.Now()
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	pending := make(map[int]time.Time)
+	var rtts []float64
+	sent := 0
+	nextSend := time.Now()
+
+	// A cancelled run still reports statistics for what it sent, like
+	// ping does when interrupted
+	var cancelled error
+loop:
+	for sent < count || len(pending) > 0 {
+		wait := time.Until(nextDeadline(sent < count, nextSend, pending, timeout))
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			cancelled = ctx.Err()
+			break loop
+
+		case r, ok := <-replies:
+			timer.Stop()
+			if !ok {
+				return fmt.Errorf("ICMP socket closed unexpectedly")
+			}
+			sentAt, ok := pending[r.seq]
+			if !ok {
+				continue
+			}
+			delete(pending, r.seq)
+
+			reply := PingReply{
+				Seq:   r.seq,
+				TTL:   r.ttl,
+				RTT:   float64(r.at.Sub(sentAt).Microseconds()) / 1000,
+				Bytes: r.bytes,
+				From:  r.from.String(),
+			}
+			rtts = append(rtts, reply.RTT)
+			emit(fmt.Sprintf("%d bytes from %s: icmp_seq=%d ttl=%d time=%.3f ms", reply.Bytes, reply.From, reply.Seq, reply.TTL, reply.RTT),
+				&Event{Type: EventPingReply, Data: reply})
+
+		case now := <-timer.C:
+			if sent < count && !now.Before(nextSend) {
+				sent++
+				if err := pg.send(sent, payload); err != nil {
+					emit(fmt.Sprintf("From %s icmp_seq=%d %v", dst, sent, err),
+						&Event{Type: EventPingError, Data: PingError{Seq: sent, Message: err.Error()}})
+				} else {
+					pending[sent] = now
+				}
+				nextSend = now.Add(interval)
+			}
+
+			for _, seq := range expired(pending, now, timeout) {
+				delete(pending, seq)
+				emit(fmt.Sprintf("no answer yet for icmp_seq=%d", seq),
+					&Event{Type: EventPingTimeout, Data: PingTimeout{Seq: seq}})
+			}
+		}
+	}
+
+	summary := PingSummary{
+		Transmitted: sent,
+		Received:    len(rtts),
+		Loss:        LossPercent(sent, len(rtts)),
+		RTT:         ComputeRTTStats(rtts),
+	}
+	emit(fmt.Sprintf("--- %s ping statistics ---", target), nil)
+	emit(fmt.Sprintf("%d packets transmitted, %d received, %g%% packet loss, time %dms",
+		summary.Transmitted, summary.Received, summary.Loss, time.Since(start).Milliseconds()), nil)
+	if summary.RTT != nil {
+		emit(fmt.Sprintf("rtt min/avg/max/mdev = %.3f/%.3f/%.3f/%.3f ms",
+			summary.RTT.Min, summary.RTT.Avg, summary.RTT.Max, summary.RTT.Mdev), nil)
+	}
+	emit("", &Event{Type: EventPingSummary, Data: summary})
+
+	return cancelled
+}
+
+// resolveTarget returns the address to ping in family, or preferring
+// IPv4 when family is empty
+func resolveTarget(ctx context.Context, target, family string) (net.IPAddr, error) {
+	addrs, err := lookupHost(ctx, target)
+	if err != nil {
+		return net.IPAddr{}, err
+	}
+	var v4, v6 *net.IPAddr
+	for i := range addrs {
+		if addrs[i].IP.To4() != nil {
+			if v4 == nil {
+				v4 = &addrs[i]
+			}
+		} else if v6 == nil {
+			v6 = &addrs[i]
+		}
+	}
+
+	switch {
+	case family != FamilyIPv6 && v4 != nil:
+		return *v4, nil
+	case family != FamilyIPv4 && v6 != nil:
+		return *v6, nil
+	case family != "":
+		return net.IPAddr{}, fmt.Errorf("no IPv%s addresses found for %s", family, target)
+	}
+	return net.IPAddr{}, fmt.Errorf("no addresses found for %s", target)
+}
+
+// newPinger opens an unprivileged ICMP datagram socket, falling back to a
+// raw socket when ping_group_range does not include our group
+func newPinger(dst net.IPAddr, ttl int) (*pinger, error) {
+	v6 := dst.IP.To4() == nil
+	network, address := "udp4", "0.0.0.0"
+	rawNetwork := "ip4:icmp"
+	if v6 {
+		network, address, rawNetwork = "udp6", "::", "ip6:ipv6-icmp"
+	}
+
+	pg := &pinger{dst: dst, ipv6: v6, id: os.Getpid() & 0xffff}
+
+	conn, err := icmp.ListenPacket(network, address)
+	if err != nil {
+		var rawErr error
+		if conn, rawErr = icmp.ListenPacket(rawNetwork, address); rawErr != nil {
+			return nil, fmt.Errorf("failed to open ICMP socket: %v (raw: %v)", err, rawErr)
+		}
+		pg.privileged = true
+	}
+	pg.conn = conn
+
+	// Options are best effort; not every platform supports them
+	if v6 {
+		pc := conn.IPv6PacketConn()
+		pc.SetHopLimit(ttl)
+		pc.SetControlMessage(ipv6.FlagHopLimit, true)
+	} else {
+		pc := conn.IPv4PacketConn()
+		pc.SetTTL(ttl)
+		pc.SetControlMessage(ipv4.FlagTTL, true)
+	}
+
+	return pg, nil
+}
+
+// headerOverhead returns the IP and ICMP header bytes added to the payload
+func (pg *pinger) headerOverhead() int {
+	if pg.ipv6 {
+		return 48
+	}
+	return 28
+}
+
+// send writes an echo request with sequence number seq
+func (pg *pinger) send(seq int, payload []byte) error {
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if pg.ipv6 {
+		typ = ipv6.ICMPTypeEchoRequest
+	}
+
+	msg := icmp.Message{
+		Type: typ,
+		Body: &icmp.Echo{ID: pg.id, Seq: seq & 0xffff, Data: payload},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+
+	var dst net.Addr = &net.UDPAddr{IP: pg.dst.IP, Zone: pg.dst.Zone}
+	if pg.privileged {
+		dst = &net.IPAddr{IP: pg.dst.IP, Zone: pg.dst.Zone}
+	}
+	_, err = pg.conn.WriteTo(b, dst)
+	return err
+}
+
+// readReplies forwards echo replies for this pinger until the socket
+// closes or stop is closed
+func (pg *pinger) readReplies(out chan<- pingPacket, stop <-chan struct{}) {
+	defer close(out)
+
+	proto := protocolICMP
+	if pg.ipv6 {
+		proto = protocolIPv6ICMP
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, ttl, src, err := pg.read(buf)
+		if err != nil {
+			return
+		}
+		at := time.Now()
+
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		if msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := msg.Body.(*icmp.Echo)
+		if !ok {
+			continue
+		}
+
+		// Raw sockets see every reply on the host; datagram sockets have
+		// their ID rewritten by the kernel and are already filtered
+		if pg.privileged && echo.ID != pg.id {
+			continue
+		}
+		if !src.Equal(pg.dst.IP) {
+			continue
+		}
+
+		select {
+		case out <- pingPacket{seq: echo.Seq, ttl: ttl, bytes: n, from: src, at: at}:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// read reads one ICMP message with the TTL or hop limit it arrived with
+func (pg *pinger) read(buf []byte) (int, int, net.IP, error) {
+	var (
+		n    int
+		ttl  int
+		addr net.Addr
+		err  error
+	)
+	if pg.ipv6 {
+		var cm *ipv6.ControlMessage
+		n, cm, addr, err = pg.conn.IPv6PacketConn().ReadFrom(buf)
+		if cm != nil {
+			ttl = cm.HopLimit
+		}
+	} else {
+		var cm *ipv4.ControlMessage
+		n, cm, addr, err = pg.conn.IPv4PacketConn().ReadFrom(buf)
+		if cm != nil {
+			ttl = cm.TTL
+		}
+	}
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return n, ttl, a.IP, nil
+	case *net.IPAddr:
+		return n, ttl, a.IP, nil
+	}
+	return n, ttl, nil, nil
+}
+
+// nextDeadline returns when the ping loop next needs to act
+func nextDeadline(sending bool, nextSend time.Time, pending map[int]time.Time, timeout time.Duration) time.Time {
+	var deadline time.Time
+	if sending {
+		deadline = nextSend
+	}
+	for _, sentAt := range pending {
+		if d := sentAt.Add(timeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	return deadline
+}
+
+// expired returns the sequence numbers in pending older than timeout
+func expired(pending map[int]time.Time, now time.Time, timeout time.Duration) []int {
+	var seqs []int
+	for seq, sentAt := range pending {
+		if !now.Before(sentAt.Add(timeout)) {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Ints(seqs)
+	return seqs
+}
@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+)
+
+// recordEvents returns an Emitter that keeps the events it is given
+func recordEvents() (Emitter, func() []Event) {
+	var (
+		mu     sync.Mutex
+		events []Event
+	)
+	emit := func(line string, event *Event) {
+		if event != nil {
+			mu.Lock()
+			events = append(events, *event)
+			mu.Unlock()
+		}
+	}
+	return emit, func() []Event {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Event(nil), events...)
+	}
+}
+
+// skipWithoutICMP skips tests that need an ICMP socket the sandbox may
+// not allow, either through ping_group_range or raw socket privileges
+func skipWithoutICMP(t *testing.T) {
+	t.Helper()
+	pg, err := newPinger(net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, 64)
+	if err != nil {
+		t.Skipf("ICMP sockets unavailable: %v", err)
+	}
+	pg.conn.Close()
+}
+
+func TestNativePingLoopback(t *testing.T) {
+	skipWithoutICMP(t)
+
+	emit, events := recordEvents()
+	params := map[string]string{"count": "3", "interval": "10", "timeout": "1"}
+	if err := NewPingTool(PingConfig{}).Run(context.Background(), "127.0.0.1", params, emit); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var replies int
+	var summary *PingSummary
+	for _, e := range events() {
+		switch data := e.Data.(type) {
+		case PingReply:
+			replies++
+			if data.From != "127.0.0.1" {
+				t.Errorf("reply from %s, want 127.0.0.1", data.From)
+			}
+			if data.Seq != replies {
+				t.Errorf("reply seq %d, want %d", data.Seq, replies)
+			}
+		case PingSummary:
+			summary = &data
+		}
+	}
+	if replies != 3 {
+		t.Errorf("got %d replies, want 3", replies)
+	}
+	if summary == nil {
+		t.Fatal("no summary event")
+	}
+	if summary.Transmitted != 3 || summary.Received != 3 || summary.Loss != 0 || summary.RTT == nil {
+		t.Errorf("summary = %+v, want 3 transmitted and received with RTT stats", *summary)
+	}
+}
+
+func TestNativePingCancelledStillSummarizes(t *testing.T) {
+	skipWithoutICMP(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var summary *PingSummary
+	emit := func(line string, event *Event) {
+		if event == nil {
+			return
+		}
+		switch data := event.Data.(type) {
+		case PingReply:
+			// Stop after the first reply, as a user pressing stop would
+			cancel()
+		case PingSummary:
+			summary = &data
+		}
+	}
+
+	params := map[string]string{"count": "10", "interval": "200", "timeout": "1"}
+	err := NewPingTool(PingConfig{}).Run(ctx, "127.0.0.1", params, emit)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run error = %v, want context.Canceled", err)
+	}
+	if summary == nil {
+		t.Fatal("no summary event after cancellation")
+	}
+	if summary.Transmitted < 1 || summary.Transmitted >= 10 || summary.Received < 1 {
+		t.Errorf("summary = %+v, want the partial run's counts", *summary)
+	}
+}
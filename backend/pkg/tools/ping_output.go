@@ -0,0 +1,202 @@
+package tools
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Ping event types
+const (
+	EventPingReply   = "ping_reply"
+	EventPingTimeout = "ping_timeout"
+	EventPingError   = "ping_error"
+	EventPingSummary = "ping_summary"
+)
+
+// PingReply is a single echo reply
+type PingReply struct {
+	Seq   int     `json:"seq"`
+	TTL   int     `json:"ttl"`
+	RTT   float64 `json:"rtt"`
+	Bytes int     `json:"bytes"`
+	From  string  `json:"from"`
+	Host  string  `json:"host,omitempty"`
+}
+
+// PingTimeout reports an echo request that received no reply in time
+type PingTimeout struct {
+	Seq int `json:"seq"`
+}
+
+// PingError reports an ICMP error received in place of a reply
+type PingError struct {
+	Seq     int    `json:"seq"`
+	From    string `json:"from,omitempty"`
+	Message string `json:"message"`
+}
+
+// RTTStats holds round-trip time statistics in milliseconds
+type RTTStats struct {
+	Min  float64 `json:"min"`
+	Avg  float64 `json:"avg"`
+	Max  float64 `json:"max"`
+	Mdev float64 `json:"mdev"`
+}
+
+// PingSummary holds the final statistics of a ping run
+type PingSummary struct {
+	Transmitted int       `json:"transmitted"`
+	Received    int       `json:"received"`
+	Errors      int       `json:"errors,omitempty"`
+	Loss        float64   `json:"loss"`
+	RTT         *RTTStats `json:"rtt,omitempty"`
+}
+
+var (
+	// 64 bytes from host (1.2.3.4): icmp_seq=1 ttl=56 time=11.2 ms
+	// 64 bytes from 1.2.3.4: seq=0 ttl=56 time=11.2 ms (busybox)
+	pingReplyRegex = regexp.MustCompile(`^(\d+) bytes from (\S+?)(?: \(([^)]+)\))?: (?:icmp_)?seq=(\d+) ttl=(\d+) time[=<]([\d.]+) ?ms`)
+	// no answer yet for icmp_seq=2 / Request timeout for icmp_seq 2
+	pingTimeoutRegex = regexp.MustCompile(`^(?:no answer yet for icmp_seq=|Request timeout for icmp_seq )(\d+)`)
+	// From 10.0.0.1 icmp_seq=1 Destination Host Unreachable
+	pingErrorRegex = regexp.MustCompile(`^From (\S+?)(?: \(([^)]+)\))?:? icmp_seq=(\d+) (.+)$`)
+	// 4 packets transmitted, 3 received, +1 errors, 25% packet loss, time 3004ms
+	pingCountsRegex = regexp.MustCompile(`^(\d+) packets transmitted, (\d+) (?:packets )?received,(?: \+(\d+) errors,)?(?: \+\d+ duplicates,)? ([\d.]+)% packet loss`)
+	// rtt min/avg/max/mdev = 1.0/2.0/3.0/0.5 ms
+	pingRTTRegex = regexp.MustCompile(`^(?:rtt|round-trip) min/avg/max(?:/(?:mdev|stddev))? = ([\d.]+)/([\d.]+)/([\d.]+)(?:/([\d.]+))? ms`)
+)
+
+// PingParser parses ping output into structured events
+type PingParser struct {
+	summary     *PingSummary
+	summarySent bool
+	sent        int
+	rtts        []float64
+}
+
+// NewPingParser creates a new PingParser instance
+func NewPingParser() *PingParser {
+	return &PingParser{}
+}
+
+// ParseLine implements Parser
+func (p *PingParser) ParseLine(line string) *Event {
+	if m := pingReplyRegex.FindStringSubmatch(line); m != nil {
+		reply := PingReply{
+			Bytes: atoi(m[1]),
+			From:  m[2],
+			Seq:   atoi(m[4]),
+			TTL:   atoi(m[5]),
+			RTT:   atof(m[6]),
+		}
+		if m[3] != "" {
+			reply.Host = m[2]
+			reply.From = m[3]
+		}
+		p.sent++
+		p.rtts = append(p.rtts, reply.RTT)
+		return &Event{Type: EventPingReply, Data: reply}
+	}
+
+	if m := pingTimeoutRegex.FindStringSubmatch(line); m != nil {
+		p.sent++
+		return &Event{Type: EventPingTimeout, Data: PingTimeout{Seq: atoi(m[1])}}
+	}
+
+	if m := pingErrorRegex.FindStringSubmatch(line); m != nil {
+		from := m[1]
+		if m[2] != "" {
+			from = m[2]
+		}
+		p.sent++
+		return &Event{Type: EventPingError, Data: PingError{Seq: atoi(m[3]), From: from, Message: m[4]}}
+	}
+
+	if m := pingCountsRegex.FindStringSubmatch(line); m != nil {
+		p.summary = &PingSummary{
+			Transmitted: atoi(m[1]),
+			Received:    atoi(m[2]),
+			Errors:      atoi(m[3]),
+			Loss:        atof(m[4]),
+		}
+		return nil
+	}
+
+	if m := pingRTTRegex.FindStringSubmatch(line); m != nil && p.summary != nil {
+		p.summary.RTT = &RTTStats{
+			Min:  atof(m[1]),
+			Avg:  atof(m[2]),
+			Max:  atof(m[3]),
+			Mdev: atof(m[4]),
+		}
+		p.summarySent = true
+		return &Event{Type: EventPingSummary, Data: *p.summary}
+	}
+
+	return nil
+}
+
+// Finish implements Parser
+func (p *PingParser) Finish() []Event {
+	if p.summarySent {
+		return nil
+	}
+
+	// Fall back to statistics computed from the replies seen so far when
+	// ping was interrupted before printing its own
+	summary := p.summary
+	if summary == nil {
+		summary = &PingSummary{
+			Transmitted: p.sent,
+			Received:    len(p.rtts),
+			Loss:        LossPercent(p.sent, len(p.rtts)),
+		}
+	}
+	summary.RTT = ComputeRTTStats(p.rtts)
+
+	p.summarySent = true
+	return []Event{{Type: EventPingSummary, Data: *summary}}
+}
+
+// LossPercent returns the percentage of transmitted packets not received
+func LossPercent(transmitted, received int) float64 {
+	if transmitted == 0 {
+		return 0
+	}
+	return float64(transmitted-received) / float64(transmitted) * 100
+}
+
+// ComputeRTTStats returns statistics for rtts, or nil if there are none
+func ComputeRTTStats(rtts []float64) *RTTStats {
+	if len(rtts) == 0 {
+		return nil
+	}
+
+	stats := &RTTStats{Min: rtts[0], Max: rtts[0]}
+	var sum, sumSq float64
+	for _, rtt := range rtts {
+		stats.Min = math.Min(stats.Min, rtt)
+		stats.Max = math.Max(stats.Max, rtt)
+		sum += rtt
+		sumSq += rtt * rtt
+	}
+
+	n := float64(len(rtts))
+	stats.Avg = sum / n
+	stats.Mdev = math.Sqrt(math.Max(sumSq/n-stats.Avg*stats.Avg, 0))
+	return stats
+}
+
+// atoi parses s as an int, returning 0 on failure
+func atoi(s string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(s))
+	return n
+}
+
+// atof parses s as a float64, returning 0 on failure
+func atof(s string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f
+}
@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseOutput feeds output to p line by line and returns every event
+// it produces, including those from Finish
+func parseOutput(p Parser, output string) []Event {
+	events := []Event{}
+	for _, line := range strings.Split(output, "\n") {
+		if e := p.ParseLine(line); e != nil {
+			events = append(events, *e)
+		}
+	}
+	return append(events, p.Finish()...)
+}
+
+func TestPingParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []Event
+	}{
+		{
+			name: "linux iputils with reverse names and a timeout",
+			output: `PING google.com (142.250.187.206) 56(84) bytes of data.
+64 bytes from lhr25s34-in-f14.1e100.net (142.250.187.206): icmp_seq=1 ttl=117 time=9.81 ms
+no answer yet for icmp_seq=2
+64 bytes from lhr25s34-in-f14.1e100.net (142.250.187.206): icmp_seq=3 ttl=117 time=10.2 ms
+
+--- google.com ping statistics ---
+3 packets transmitted, 2 received, 33.3333% packet loss, time 2003ms
+rtt min/avg/max/mdev = 9.810/10.005/10.200/0.195 ms`,
+			want: []Event{
+				{Type: EventPingReply, Data: PingReply{Seq: 1, TTL: 117, RTT: 9.81, Bytes: 64, From: "142.250.187.206", Host: "lhr25s34-in-f14.1e100.net"}},
+				{Type: EventPingTimeout, Data: PingTimeout{Seq: 2}},
+				{Type: EventPingReply, Data: PingReply{Seq: 3, TTL: 117, RTT: 10.2, Bytes: 64, From: "142.250.187.206", Host: "lhr25s34-in-f14.1e100.net"}},
+				{Type: EventPingSummary, Data: PingSummary{Transmitted: 3, Received: 2, Loss: 33.3333,
+					RTT: &RTTStats{Min: 9.81, Avg: 10.005, Max: 10.2, Mdev: 0.195}}},
+			},
+		},
+		{
+			name: "linux iputils with ICMP errors and no replies",
+			output: `PING 10.0.0.99 (10.0.0.99) 56(84) bytes of data.
+From 10.0.0.1 icmp_seq=1 Destination Host Unreachable
+From 10.0.0.1 icmp_seq=2 Destination Host Unreachable
+
+--- 10.0.0.99 ping statistics ---
+2 packets transmitted, 0 received, +2 errors, 100% packet loss, time 1015ms
+`,
+			want: []Event{
+				{Type: EventPingError, Data: PingError{Seq: 1, From: "10.0.0.1", Message: "Destination Host Unreachable"}},
+				{Type: EventPingError, Data: PingError{Seq: 2, From: "10.0.0.1", Message: "Destination Host Unreachable"}},
+				{Type: EventPingSummary, Data: PingSummary{Transmitted: 2, Received: 0, Errors: 2, Loss: 100}},
+			},
+		},
+		{
+			name: "macos",
+			output: `PING example.com (93.184.216.34): 56 data bytes
+64 bytes from 93.184.216.34: icmp_seq=0 ttl=56 time=11.632 ms
+Request timeout for icmp_seq 1
+64 bytes from 93.184.216.34: icmp_seq=2 ttl=56 time=12.168 ms
+
+--- example.com ping statistics ---
+3 packets transmitted, 2 packets received, 33.3% packet loss
+round-trip min/avg/max/stddev = 11.632/11.900/12.168/0.268 ms`,
+			want: []Event{
+				{Type: EventPingReply, Data: PingReply{Seq: 0, TTL: 56, RTT: 11.632, Bytes: 64, From: "93.184.216.34"}},
+				{Type: EventPingTimeout, Data: PingTimeout{Seq: 1}},
+				{Type: EventPingReply, Data: PingReply{Seq: 2, TTL: 56, RTT: 12.168, Bytes: 64, From: "93.184.216.34"}},
+				{Type: EventPingSummary, Data: PingSummary{Transmitted: 3, Received: 2, Loss: 33.3,
+					RTT: &RTTStats{Min: 11.632, Avg: 11.9, Max: 12.168, Mdev: 0.268}}},
+			},
+		},
+		{
+			name: "busybox without mdev",
+			output: `PING 1.1.1.1 (1.1.1.1): 56 data bytes
+64 bytes from 1.1.1.1: seq=0 ttl=57 time=1.234 ms
+64 bytes from 1.1.1.1: seq=1 ttl=57 time=1.366 ms
+
+--- 1.1.1.1 ping statistics ---
+2 packets transmitted, 2 packets received, 0% packet loss
+round-trip min/avg/max = 1.234/1.300/1.366 ms`,
+			want: []Event{
+				{Type: EventPingReply, Data: PingReply{Seq: 0, TTL: 57, RTT: 1.234, Bytes: 64, From: "1.1.1.1"}},
+				{Type: EventPingReply, Data: PingReply{Seq: 1, TTL: 57, RTT: 1.366, Bytes: 64, From: "1.1.1.1"}},
+				{Type: EventPingSummary, Data: PingSummary{Transmitted: 2, Received: 2, Loss: 0,
+					RTT: &RTTStats{Min: 1.234, Avg: 1.3, Max: 1.366}}},
+			},
+		},
+		{
+			name: "interrupted before the summary",
+			output: `PING 192.0.2.10 (192.0.2.10) 56(84) bytes of data.
+64 bytes from 192.0.2.10: icmp_seq=1 ttl=64 time=2 ms
+no answer yet for icmp_seq=2
+64 bytes from 192.0.2.10: icmp_seq=3 ttl=64 time=4 ms`,
+			want: []Event{
+				{Type: EventPingReply, Data: PingReply{Seq: 1, TTL: 64, RTT: 2, Bytes: 64, From: "192.0.2.10"}},
+				{Type: EventPingTimeout, Data: PingTimeout{Seq: 2}},
+				{Type: EventPingReply, Data: PingReply{Seq: 3, TTL: 64, RTT: 4, Bytes: 64, From: "192.0.2.10"}},
+				{Type: EventPingSummary, Data: PingSummary{Transmitted: 3, Received: 2, Loss: LossPercent(3, 2),
+					RTT: &RTTStats{Min: 2, Avg: 3, Max: 4, Mdev: 1}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseOutput(NewPingParser(), tt.output)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events:\n got  %+v\n want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeRTTStats(t *testing.T) {
+	if got := ComputeRTTStats(nil); got != nil {
+		t.Errorf("ComputeRTTStats(nil) = %+v, want nil", got)
+	}
+	got := ComputeRTTStats([]float64{1, 2, 3, 4})
+	want := &RTTStats{Min: 1, Avg: 2.5, Max: 4, Mdev: 1.118033988749895}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ComputeRTTStats = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Definition is the public description of a registered tool
+type Definition struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Parameters  []Parameter `json:"parameters"`
+}
+
+// Config holds settings for the built-in tools
+type Config struct {
+	Ping PingConfig `json:"ping"`
+	Dig  DigConfig  `json:"dig"`
+}
+
+// Registry holds the set of tools available to the server
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+	order []string
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		tools: make(map[string]Tool),
+	}
+}
+
+// NewDefaultRegistry creates a Registry with the built-in tools
+func NewDefaultRegistry(config Config) *Registry {
+	r := NewRegistry()
+	r.MustRegister(NewPingTool(config.Ping))
+	r.MustRegister(NewDigTool(config.Dig))
+	r.MustRegister(NewTracerouteTool())
+	r.MustRegister(NewTCPingTool())
+	r.MustRegister(NewHTTPTool())
+	r.MustRegister(NewTLSTool())
+	return r
+}
+
+// Register adds a tool to the registry
+func (r *Registry) Register(t Tool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := t.Name()
+	if name == "" {
+		return fmt.Errorf("tool name must not be empty")
+	}
+	if _, exists := r.tools[name]; exists {
+		return fmt.Errorf("tool already registered: %s", name)
+	}
+
+	r.tools[name] = t
+	r.order = append(r.order, name)
+	return nil
+}
+
+// MustRegister adds a tool to the registry and panics on failure
+func (r *Registry) MustRegister(t Tool) {
+	if err := r.Register(t); err != nil {
+		panic(err)
+	}
+}
+
+// Get returns the tool registered under name
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// Names returns the registered tool names in registration order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, len(r.order))
+	copy(names, r.order)
+	return names
+}
+
+// Definitions returns descriptions of all registered tools
+func (r *Registry) Definitions() []Definition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]Definition, 0, len(r.order))
+	for _, name := range r.order {
+		t := r.tools[name]
+		defs = append(defs, Definition{
+			Name:        t.Name(),
+			Description: t.Description(),
+			Parameters:  t.Parameters(),
+		})
+	}
+	return defs
+}
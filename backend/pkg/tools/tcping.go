@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// TCPing event types
+const (
+	EventTCPingAttempt = "tcping_attempt"
+	EventTCPingSummary = "tcping_summary"
+)
+
+// Outcomes of a single TCP connection attempt
+const (
+	TCPingOpen    = "open"
+	TCPingRefused = "refused"
+	TCPingTimeout = "timeout"
+	TCPingError   = "error"
+)
+
+// TCPingAttempt is the outcome of a single TCP connection attempt
+type TCPingAttempt struct {
+	Seq     int     `json:"seq"`
+	Address string  `json:"address"`
+	Port    int     `json:"port"`
+	Status  string  `json:"status"`
+	RTT     float64 `json:"rtt,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// TCPingTool measures TCP connect latency to a port
+type TCPingTool struct{}
+
+// NewTCPingTool creates a new TCPingTool instance
+func NewTCPingTool() *TCPingTool {
+	return &TCPingTool{}
+}
+
+// Name implements Tool
+func (t *TCPingTool) Name() string { return "tcping" }
+
+// Description implements Tool
+func (t *TCPingTool) Description() string { return "Measure TCP connect latency to a port" }
+
+// Parameters implements Tool
+func (t *TCPingTool) Parameters() []Parameter {
+	return []Parameter{
+		{Name: "port", Type: ParameterInt, Description: "TCP port to connect to", Default: "443", Min: 1, Max: 65535},
+		{Name: "count", Type: ParameterInt, Description: "Number of connection attempts", Default: "4", Min: 1, Max: 10},
+		{Name: "interval", Type: ParameterInt, Description: "Milliseconds between attempts", Default: "1000", Min: 200, Max: 5000},
+		{Name: "timeout", Type: ParameterInt, Description: "Seconds to wait for each connection", Default: "2", Min: 1, Max: 10},
+	}
+}
+
+// Validate implements Tool
+func (t *TCPingTool) Validate(params map[string]string) error {
+	for _, param := range t.Parameters() {
+		if err := validateIntRange(params, param.Name, param.Min, param.Max); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Command implements Tool
+func (t *TCPingTool) Command(target string, params map[string]string) (Command, error) {
+	return Command{}, fmt.Errorf("%s has no external command", t.Name())
+}
+
+// NewParser implements Tool
+func (t *TCPingTool) NewParser() Parser { return NopParser{} }
+
+// Native implements Runner
+func (t *TCPingTool) Native(params map[string]string) bool { return true }
+
+// Run implements Runner
+func (t *TCPingTool) Run(ctx context.Context, target string, params map[string]string, emit Emitter) error {
+	port := intParam(params, "port", 443)
+	count := intParam(params, "count", 4)
+	interval := time.Duration(intParam(params, "interval", 1000)) * time.Millisecond
+	timeout := time.Duration(intParam(params, "timeout", 2)) * time.Second
+
+	ip, err := resolveTarget(ctx, target, "")
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+
+	emit(fmt.Sprintf("TCPING %s (%s) port %d", target, ip, port), nil)
+
+	start := time.Now()
+	var rtts []float64
+	attempted := 0
+
+	// A cancelled run still reports statistics for the attempts it
+	// finished, as ping does when interrupted
+	var cancelled error
+loop:
+	for seq := 1; seq <= count; seq++ {
+		if seq > 1 {
+			select {
+			case <-ctx.Done():
+				cancelled = ctx.Err()
+				break loop
+			case <-time.After(interval):
+			}
+		}
+
+		attempt := tcpConnect(ctx, addr, timeout)
+		if err := ctx.Err(); err != nil {
+			// The attempt was cut short, so its outcome says nothing
+			// about the target
+			cancelled = err
+			break
+		}
+		attempted++
+		attempt.Seq = seq
+		attempt.Address = ip.String()
+		attempt.Port = port
+		if attempt.Status == TCPingOpen {
+			rtts = append(rtts, attempt.RTT)
+		}
+
+		emit(formatTCPingAttempt(attempt), &Event{Type: EventTCPingAttempt, Data: attempt})
+	}
+
+	summary := PingSummary{
+		Transmitted: attempted,
+		Received:    len(rtts),
+		Loss:        LossPercent(attempted, len(rtts)),
+		RTT:         ComputeRTTStats(rtts),
+	}
+	emit(fmt.Sprintf("--- %s tcping statistics ---", target), nil)
+	emit(fmt.Sprintf("%d connections attempted, %d succeeded, %g%% failed, time %dms",
+		summary.Transmitted, summary.Received, summary.Loss, time.Since(start).Milliseconds()), nil)
+	if summary.RTT != nil {
+		emit(fmt.Sprintf("rtt min/avg/max/mdev = %.3f/%.3f/%.3f/%.3f ms",
+			summary.RTT.Min, summary.RTT.Avg, summary.RTT.Max, summary.RTT.Mdev), nil)
+	}
+	emit("", &Event{Type: EventTCPingSummary, Data: summary})
+
+	return cancelled
+}
+
+// tcpConnect opens and immediately closes a TCP connection to addr
+func tcpConnect(ctx context.Context, addr string, timeout time.Duration) TCPingAttempt {
+	d := net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	rtt := float64(time.Since(start).Microseconds()) / 1000
+
+	if err == nil {
+		conn.Close()
+		return TCPingAttempt{Status: TCPingOpen, RTT: rtt}
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return TCPingAttempt{Status: TCPingRefused, RTT: rtt, Error: "connection refused"}
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return TCPingAttempt{Status: TCPingTimeout, Error: "connection timed out"}
+	default:
+		return TCPingAttempt{Status: TCPingError, Error: err.Error()}
+	}
+}
+
+// formatTCPingAttempt renders an attempt as a line of text output
+func formatTCPingAttempt(a TCPingAttempt) string {
+	switch a.Status {
+	case TCPingOpen:
+		return fmt.Sprintf("Connected to %s: seq=%d time=%.3f ms", net.JoinHostPort(a.Address, strconv.Itoa(a.Port)), a.Seq, a.RTT)
+	case TCPingRefused:
+		return fmt.Sprintf("Connection refused by %s: seq=%d time=%.3f ms", net.JoinHostPort(a.Address, strconv.Itoa(a.Port)), a.Seq, a.RTT)
+	case TCPingTimeout:
+		return fmt.Sprintf("Timeout connecting to %s: seq=%d", net.JoinHostPort(a.Address, strconv.Itoa(a.Port)), a.Seq)
+	}
+	return fmt.Sprintf("Failed to connect to %s: seq=%d %s", net.JoinHostPort(a.Address, strconv.Itoa(a.Port)), a.Seq, a.Error)
+}
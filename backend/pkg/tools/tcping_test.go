@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// listenLoopback accepts and closes TCP connections on a loopback port
+// until the test ends, and returns the port
+func listenLoopback(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return port
+}
+
+func TestTCPingLoopback(t *testing.T) {
+	emit, events := recordEvents()
+	params := map[string]string{"port": listenLoopback(t), "count": "3", "interval": "200", "timeout": "1"}
+	if err := NewTCPingTool().Run(context.Background(), "127.0.0.1", params, emit); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var attempts int
+	var summary *PingSummary
+	for _, e := range events() {
+		switch data := e.Data.(type) {
+		case TCPingAttempt:
+			attempts++
+			if data.Status != TCPingOpen || data.Seq != attempts {
+				t.Errorf("attempt = %+v, want seq %d open", data, attempts)
+			}
+		case PingSummary:
+			summary = &data
+		}
+	}
+	if attempts != 3 {
+		t.Errorf("got %d attempts, want 3", attempts)
+	}
+	if summary == nil {
+		t.Fatal("no summary event")
+	}
+	if summary.Transmitted != 3 || summary.Received != 3 || summary.Loss != 0 || summary.RTT == nil {
+		t.Errorf("summary = %+v, want 3 attempted and succeeded with RTT stats", *summary)
+	}
+}
+
+func TestTCPingCancelledStillSummarizes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var summary *PingSummary
+	emit := func(line string, event *Event) {
+		if event == nil {
+			return
+		}
+		switch data := event.Data.(type) {
+		case TCPingAttempt:
+			// Stop after the first attempt, as a user pressing stop would
+			cancel()
+		case PingSummary:
+			summary = &data
+		}
+	}
+
+	params := map[string]string{"port": listenLoopback(t), "count": "10", "interval": "200", "timeout": "1"}
+	err := NewTCPingTool().Run(ctx, "127.0.0.1", params, emit)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run error = %v, want context.Canceled", err)
+	}
+	if summary == nil {
+		t.Fatal("no summary event after cancellation")
+	}
+	if summary.Transmitted != 1 || summary.Received != 1 || summary.Loss != 0 {
+		t.Errorf("summary = %+v, want the one finished attempt", *summary)
+	}
+}
@@ -0,0 +1,337 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// EventTLSResult is the event type carrying a TLS inspection result
+const EventTLSResult = "tls_result"
+
+// expiryWarningDays is how close to expiry a certificate is flagged
+const expiryWarningDays = 30
+
+// TLSCertificate describes one certificate of the presented chain
+type TLSCertificate struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	SerialNumber       string    `json:"serialNumber"`
+	DNSNames           []string  `json:"dnsNames"`
+	IPAddresses        []string  `json:"ipAddresses"`
+	NotBefore          time.Time `json:"notBefore"`
+	NotAfter           time.Time `json:"notAfter"`
+	DaysToExpiry       int       `json:"daysToExpiry"`
+	KeyType            string    `json:"keyType"`
+	KeySize            int       `json:"keySize"`
+	SignatureAlgorithm string    `json:"signatureAlgorithm"`
+	IsCA               bool      `json:"isCA"`
+	SHA256             string    `json:"sha256"`
+	SHA1               string    `json:"sha1"`
+}
+
+// TLSResult is the structured result of a TLS inspection
+type TLSResult struct {
+	Address       string           `json:"address"`
+	ServerName    string           `json:"serverName,omitempty"`
+	Version       string           `json:"version"`
+	CipherSuite   string           `json:"cipherSuite"`
+	ALPN          string           `json:"alpn,omitempty"`
+	Certificates  []TLSCertificate `json:"certificates"`
+	HostnameValid bool             `json:"hostnameValid"`
+	HostnameError string           `json:"hostnameError,omitempty"`
+	ChainValid    bool             `json:"chainValid"`
+	ChainError    string           `json:"chainError,omitempty"`
+	DaysToExpiry  int              `json:"daysToExpiry"`
+	Problems      []string         `json:"problems"`
+}
+
+// TLSTool inspects the certificate chain and session of a TLS endpoint
+type TLSTool struct{}
+
+// NewTLSTool creates a new TLSTool instance
+func NewTLSTool() *TLSTool {
+	return &TLSTool{}
+}
+
+// Name implements Tool
+func (t *TLSTool) Name() string { return "tls" }
+
+// Description implements Tool
+func (t *TLSTool) Description() string { return "Inspect the TLS certificate chain of an endpoint" }
+
+// Parameters implements Tool
+func (t *TLSTool) Parameters() []Parameter {
+	return []Parameter{
+		{Name: "port", Type: ParameterInt, Description: "TCP port to connect to", Default: "443", Min: 1, Max: 65535},
+		{Name: "sni", Type: ParameterString, Description: "Server name to send and verify instead of the target"},
+		{Name: "timeout", Type: ParameterInt, Description: "Seconds to wait for the handshake", Default: "10", Min: 1, Max: 30},
+	}
+}
+
+// Validate implements Tool
+func (t *TLSTool) Validate(params map[string]string) error {
+	if err := validateIntRange(params, "port", 1, 65535); err != nil {
+		return err
+	}
+	if err := validateIntRange(params, "timeout", 1, 30); err != nil {
+		return err
+	}
+	if sni, ok := params["sni"]; ok && sni != "" {
+		if len(sni) > 253 || net.ParseIP(sni) != nil || strings.IndexFunc(sni, func(r rune) bool {
+			return !(r == '-' || r == '.' || r == '*' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'))
+		}) >= 0 {
+			return fmt.Errorf("invalid SNI hostname")
+		}
+	}
+	return nil
+}
+
+// Command implements Tool
+func (t *TLSTool) Command(target string, params map[string]string) (Command, error) {
+	return Command{}, fmt.Errorf("%s has no external command", t.Name())
+}
+
+// NewParser implements Tool
+func (t *TLSTool) NewParser() Parser { return NopParser{} }
+
+// Native implements Runner
+func (t *TLSTool) Native(params map[string]string) bool { return true }
+
+// Run implements Runner
+func (t *TLSTool) Run(ctx context.Context, target string, params map[string]string, emit Emitter) error {
+	port := paramOrDefault(params, "port", "443")
+	timeout := time.Duration(intParam(params, "timeout", 10)) * time.Second
+	serverName := params["sni"]
+	if serverName == "" && net.ParseIP(target) == nil {
+		serverName = target
+	}
+
+	address := net.JoinHostPort(target, port)
+	emit(fmt.Sprintf("* Connecting to %s (SNI %q)", address, serverName), nil)
+
+	// Verification is done separately so a broken chain can still be reported
+	config := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"h2", "http/1.1"},
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	raw, err := dialGuarded(ctx, &net.Dialer{Timeout: timeout}, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("TLS handshake failed: %v", err)
+	}
+	conn := tls.Client(raw, config)
+	err = conn.HandshakeContext(ctx)
+	state := conn.ConnectionState()
+	conn.Close()
+	if err != nil {
+		return fmt.Errorf("TLS handshake failed: %v", err)
+	}
+
+	result := inspectTLS(state, address, serverName, target, time.Now())
+
+	emit(fmt.Sprintf("* %s, cipher %s", result.Version, result.CipherSuite), nil)
+	for i, cert := range result.Certificates {
+		emit(fmt.Sprintf("* [%d] subject: %s", i, cert.Subject), nil)
+		emit(fmt.Sprintf("*     issuer: %s", cert.Issuer), nil)
+		if len(cert.DNSNames)+len(cert.IPAddresses) > 0 {
+			emit(fmt.Sprintf("*     SANs: %s", strings.Join(append(append([]string{}, cert.DNSNames...), cert.IPAddresses...), ", ")), nil)
+		}
+		emit(fmt.Sprintf("*     valid: %s to %s (%d days left)", cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339), cert.DaysToExpiry), nil)
+		emit(fmt.Sprintf("*     key: %s %d bits, signature: %s", cert.KeyType, cert.KeySize, cert.SignatureAlgorithm), nil)
+		emit(fmt.Sprintf("*     sha256: %s", cert.SHA256), nil)
+	}
+	emit(fmt.Sprintf("* hostname verification: %s", verdict(result.HostnameValid, result.HostnameError)), nil)
+	emit(fmt.Sprintf("* chain verification: %s", verdict(result.ChainValid, result.ChainError)), nil)
+	for _, problem := range result.Problems {
+		emit("! "+problem, nil)
+	}
+	emit("", &Event{Type: EventTLSResult, Data: result})
+
+	return nil
+}
+
+// inspectTLS builds a TLSResult from a completed handshake
+func inspectTLS(state tls.ConnectionState, address, serverName, target string, now time.Time) TLSResult {
+	result := TLSResult{
+		Address:      address,
+		ServerName:   serverName,
+		Version:      tls.VersionName(state.Version),
+		CipherSuite:  tls.CipherSuiteName(state.CipherSuite),
+		ALPN:         state.NegotiatedProtocol,
+		Certificates: []TLSCertificate{},
+		Problems:     []string{},
+	}
+
+	certs := state.PeerCertificates
+	if len(certs) == 0 {
+		result.Problems = append(result.Problems, "server presented no certificates")
+		return result
+	}
+
+	for _, cert := range certs {
+		result.Certificates = append(result.Certificates, describeCertificate(cert, now))
+	}
+	leaf := certs[0]
+	result.DaysToExpiry = result.Certificates[0].DaysToExpiry
+
+	// Hostname verification against the SNI name, or the address when none
+	host := serverName
+	if host == "" {
+		host = target
+	}
+	if err := leaf.VerifyHostname(host); err != nil {
+		result.HostnameError = err.Error()
+		result.Problems = append(result.Problems, "hostname mismatch: "+err.Error())
+	} else {
+		result.HostnameValid = true
+	}
+
+	// Chain verification against the system roots
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Intermediates: intermediates, CurrentTime: now}); err != nil {
+		result.ChainError = err.Error()
+		result.Problems = append(result.Problems, "chain verification failed: "+err.Error())
+	} else {
+		result.ChainValid = true
+	}
+
+	result.Problems = append(result.Problems, chainProblems(certs, now)...)
+	return result
+}
+
+// chainProblems flags issues in the presented chain that verification
+// alone does not describe
+func chainProblems(certs []*x509.Certificate, now time.Time) []string {
+	var problems []string
+
+	for i, cert := range certs {
+		name := cert.Subject.CommonName
+		if name == "" {
+			name = fmt.Sprintf("certificate %d", i)
+		}
+
+		switch {
+		case now.After(cert.NotAfter):
+			problems = append(problems, fmt.Sprintf("%s expired on %s", name, cert.NotAfter.Format("2006-01-02")))
+		case now.Before(cert.NotBefore):
+			problems = append(problems, fmt.Sprintf("%s is not valid until %s", name, cert.NotBefore.Format("2006-01-02")))
+		case cert.NotAfter.Sub(now) < expiryWarningDays*24*time.Hour:
+			problems = append(problems, fmt.Sprintf("%s expires in %d days", name, daysUntil(cert.NotAfter, now)))
+		}
+
+		switch cert.SignatureAlgorithm {
+		case x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+			if !isSelfSigned(cert) {
+				problems = append(problems, fmt.Sprintf("%s uses weak signature algorithm %s", name, cert.SignatureAlgorithm))
+			}
+		}
+
+		if key, ok := cert.PublicKey.(*rsa.PublicKey); ok && key.N.BitLen() < 2048 {
+			problems = append(problems, fmt.Sprintf("%s has a weak %d-bit RSA key", name, key.N.BitLen()))
+		}
+
+		if i+1 < len(certs) && !bytes.Equal(cert.RawIssuer, certs[i+1].RawSubject) {
+			problems = append(problems, fmt.Sprintf("%s is not issued by the next certificate in the chain", name))
+		}
+	}
+
+	if len(certs) == 1 && isSelfSigned(certs[0]) {
+		problems = append(problems, "leaf certificate is self-signed")
+	}
+	if last := certs[len(certs)-1]; !isSelfSigned(last) && len(certs) > 1 && !last.IsCA {
+		problems = append(problems, "chain ends in a certificate that is not a CA")
+	}
+
+	return problems
+}
+
+// describeCertificate extracts the reported fields of a certificate
+func describeCertificate(cert *x509.Certificate, now time.Time) TLSCertificate {
+	keyType, keySize := publicKeyInfo(cert)
+	sha256Sum := sha256.Sum256(cert.Raw)
+	sha1Sum := sha1.Sum(cert.Raw)
+
+	ips := make([]string, 0, len(cert.IPAddresses))
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	dnsNames := cert.DNSNames
+	if dnsNames == nil {
+		dnsNames = []string{}
+	}
+
+	return TLSCertificate{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       cert.SerialNumber.Text(16),
+		DNSNames:           dnsNames,
+		IPAddresses:        ips,
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		DaysToExpiry:       daysUntil(cert.NotAfter, now),
+		KeyType:            keyType,
+		KeySize:            keySize,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		IsCA:               cert.IsCA,
+		SHA256:             colonHex(sha256Sum[:]),
+		SHA1:               colonHex(sha1Sum[:]),
+	}
+}
+
+// publicKeyInfo returns the algorithm and size in bits of the public key
+func publicKeyInfo(cert *x509.Certificate) (string, int) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	}
+	return cert.PublicKeyAlgorithm.String(), 0
+}
+
+// isSelfSigned reports whether cert's issuer is its own subject
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// daysUntil returns whole days from now until t, negative once t has passed
+func daysUntil(t, now time.Time) int {
+	return int(t.Sub(now).Hours() / 24)
+}
+
+// colonHex formats b as upper-case hex bytes separated by colons
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = strings.ToUpper(hex.EncodeToString([]byte{c}))
+	}
+	return strings.Join(parts, ":")
+}
+
+// verdict renders a verification outcome for text output
+func verdict(ok bool, reason string) string {
+	if ok {
+		return "ok"
+	}
+	return "failed (" + reason + ")"
+}
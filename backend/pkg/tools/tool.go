@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Tool describes a network diagnostic that can be run by the executor
+type Tool interface {
+	// Name returns the identifier clients use to request the tool
+	Name() string
+	// Description returns a short human-readable summary
+	Description() string
+	// Parameters returns the schema of accepted parameters
+	Parameters() []Parameter
+	// Validate checks tool-specific parameters
+	Validate(params map[string]string) error
+	// Command builds the argv used to run the tool against target
+	Command(target string, params map[string]string) (Command, error)
+	// NewParser returns a parser for a single run's output
+	NewParser() Parser
+}
+
+// Engines selectable for tools that implement Runner
+const (
+	EngineExec   = "exec"
+	EngineNative = "native"
+)
+
+// Address families selectable with the family parameter
+const (
+	FamilyIPv4 = "4"
+	FamilyIPv6 = "6"
+)
+
+// Emitter receives output produced by an in-process tool run. Either
+// argument may be empty. It delivers output even after the run's context
+// is cancelled, so a tool can report what it gathered before stopping.
+type Emitter func(line string, event *Event)
+
+// Runner is implemented by tools that can run in-process instead of
+// spawning an external command
+type Runner interface {
+	// Native reports whether params select the in-process implementation
+	Native(params map[string]string) bool
+	// Run executes the tool against target, passing output to emit
+	Run(ctx context.Context, target string, params map[string]string, emit Emitter) error
+}
+
+// ParameterType identifies the kind of value a parameter accepts
+type ParameterType string
+
+const (
+	ParameterInt    ParameterType = "int"
+	ParameterString ParameterType = "string"
+	ParameterEnum   ParameterType = "enum"
+	ParameterBool   ParameterType = "bool"
+)
+
+// Parameter describes a single tool parameter
+type Parameter struct {
+	Name        string        `json:"name"`
+	Type        ParameterType `json:"type"`
+	Description string        `json:"description"`
+	Default     string        `json:"default,omitempty"`
+	Min         int           `json:"min,omitempty"`
+	Max         int           `json:"max,omitempty"`
+	Values      []string      `json:"values,omitempty"`
+}
+
+// Command is an executable and its arguments
+type Command struct {
+	Path string
+	Args []string
+}
+
+// String returns a human-readable form of the command
+func (c Command) String() string {
+	parts := append([]string{filepath.Base(c.Path)}, c.Args...)
+	return strings.Join(parts, " ")
+}
+
+// Event is a structured item parsed from tool output
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// Parser turns raw output lines into structured events
+type Parser interface {
+	// ParseLine consumes one line of output and returns any event it completes
+	ParseLine(line string) *Event
+	// Finish is called once output ends and returns any remaining events
+	Finish() []Event
+}
+
+// NopParser is a Parser that produces no events
+type NopParser struct{}
+
+// ParseLine implements Parser
+func (NopParser) ParseLine(line string) *Event { return nil }
+
+// Finish implements Parser
+func (NopParser) Finish() []Event { return nil }
+
+// paramOrDefault returns params[name] or def when it is missing or empty
+func paramOrDefault(params map[string]string, name, def string) string {
+	if v, ok := params[name]; ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// intParam returns params[name] as an int, or def when it is missing or invalid
+func intParam(params map[string]string, name string, def int) int {
+	n, err := strconv.Atoi(paramOrDefault(params, name, strconv.Itoa(def)))
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// validateIntRange checks that params[name], when present, is an integer
+// between min and max
+func validateIntRange(params map[string]string, name string, min, max int) error {
+	v, ok := params[name]
+	if !ok || v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s value", name)
+	}
+	if n < min || n > max {
+		return fmt.Errorf("%s must be between %d and %d", name, min, max)
+	}
+	return nil
+}
+
+// validateBool checks that params[name], when present, is "true" or "false"
+func validateBool(params map[string]string, name string) error {
+	if v, ok := params[name]; ok && v != "" && v != "true" && v != "false" {
+		return fmt.Errorf("%s must be true or false", name)
+	}
+	return nil
+}
+
+// validateEngine checks that params["engine"], when present, names an engine
+func validateEngine(params map[string]string) error {
+	if engine, ok := params["engine"]; ok && engine != "" {
+		if engine != EngineExec && engine != EngineNative {
+			return fmt.Errorf("invalid engine: %s", engine)
+		}
+	}
+	return nil
+}
+
+// familyParameter describes the family parameter of tools that can be
+// limited to one address family
+var familyParameter = Parameter{
+	Name:        "family",
+	Type:        ParameterEnum,
+	Description: "Use only IPv4 or only IPv6",
+	Values:      []string{FamilyIPv4, FamilyIPv6},
+}
+
+// validateFamily checks that params["family"], when present, names an
+// address family
+func validateFamily(params map[string]string) error {
+	if family, ok := params["family"]; ok && family != "" {
+		if family != FamilyIPv4 && family != FamilyIPv6 {
+			return fmt.Errorf("invalid address family: %s", family)
+		}
+	}
+	return nil
+}
+
+// familyNetwork returns network limited to an address family, so "udp"
+// becomes "udp6" for FamilyIPv6
+func familyNetwork(network, family string) string {
+	switch family {
+	case FamilyIPv4:
+		return network + "4"
+	case FamilyIPv6:
+		return network + "6"
+	}
+	return network
+}
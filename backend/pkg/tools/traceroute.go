@@ -1,17 +1,70 @@
 package tools
 
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+)
+
 // TracerouteTool handles traceroute commands
 type TracerouteTool struct {
-	// TODO: Add traceroute configuration
+	path string
 }
 
 // NewTracerouteTool creates a new TracerouteTool instance
 func NewTracerouteTool() *TracerouteTool {
-	return &TracerouteTool{}
+	path := "/usr/bin/traceroute"
+	if runtime.GOOS == "windows" {
+		path = "tracert"
+	}
+	return &TracerouteTool{path: path}
+}
+
+// Name implements Tool
+func (t *TracerouteTool) Name() string { return "traceroute" }
+
+// Description implements Tool
+func (t *TracerouteTool) Description() string { return "Trace the network path to a host" }
+
+// Parameters implements Tool
+func (t *TracerouteTool) Parameters() []Parameter {
+	return []Parameter{
+		{Name: "maxHops", Type: ParameterInt, Description: "Maximum number of hops to probe", Default: "30", Min: 1, Max: 30},
+		familyParameter,
+	}
 }
 
-// Execute runs a traceroute command
-func (t *TracerouteTool) Execute(target string, params map[string]string) (string, error) {
-	// TODO: Implement traceroute command execution
-	return "", nil
+// Validate implements Tool
+func (t *TracerouteTool) Validate(params map[string]string) error {
+	if maxHops, ok := params["maxHops"]; ok {
+		n, err := strconv.Atoi(maxHops)
+		if err != nil {
+			return fmt.Errorf("invalid max hops value")
+		}
+		if n < 1 || n > 30 {
+			return fmt.Errorf("max hops must be between 1 and 30")
+		}
+	}
+	return validateFamily(params)
 }
+
+// Command implements Tool
+func (t *TracerouteTool) Command(target string, params map[string]string) (Command, error) {
+	maxHops := paramOrDefault(params, "maxHops", "30")
+
+	var args []string
+	if family := params["family"]; family != "" {
+		args = append(args, "-"+family)
+	}
+	switch runtime.GOOS {
+	case "windows":
+		args = append(args, "-h", maxHops, "-w", "2000", target)
+	default:
+		args = append(args, "-m", maxHops, "-w", "2", target)
+	}
+
+	return Command{Path: t.path, Args: args}, nil
+}
+
+// NewParser implements Tool
+func (t *TracerouteTool) NewParser() Parser { return NewTracerouteParser() }
@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"strconv"
+	"strings"
+)
+
+// EventTracerouteHop is the event type carrying a parsed traceroute hop
+const EventTracerouteHop = "traceroute_hop"
+
+// tracerouteAnnotations maps traceroute's ICMP annotations to descriptions
+var tracerouteAnnotations = map[string]string{
+	"!H": "host unreachable",
+	"!N": "network unreachable",
+	"!P": "protocol unreachable",
+	"!S": "source route failed",
+	"!F": "fragmentation needed",
+	"!X": "communication administratively prohibited",
+	"!V": "host precedence violation",
+	"!C": "precedence cutoff in effect",
+}
+
+// TracerouteProbe is the outcome of a single probe sent to a hop
+type TracerouteProbe struct {
+	Address    string  `json:"address,omitempty"`
+	Host       string  `json:"host,omitempty"`
+	RTT        float64 `json:"rtt,omitempty"`
+	Timeout    bool    `json:"timeout,omitempty"`
+	Annotation string  `json:"annotation,omitempty"`
+	Note       string  `json:"note,omitempty"`
+}
+
+// TracerouteHop holds all probes sent with the same TTL
+type TracerouteHop struct {
+	Hop    int               `json:"hop"`
+	Probes []TracerouteProbe `json:"probes"`
+}
+
+// TracerouteParser parses traceroute output into hop events
+type TracerouteParser struct{}
+
+// NewTracerouteParser creates a new TracerouteParser instance
+func NewTracerouteParser() *TracerouteParser {
+	return &TracerouteParser{}
+}
+
+// ParseLine implements Parser
+func (t *TracerouteParser) ParseLine(line string) *Event {
+	hop, ok := ParseTracerouteHop(line)
+	if !ok {
+		return nil
+	}
+	return &Event{Type: EventTracerouteHop, Data: hop}
+}
+
+// Finish implements Parser
+func (t *TracerouteParser) Finish() []Event { return nil }
+
+// ParseTracerouteHop parses a single hop line such as
+// " 3  gw (10.0.0.1)  5.1 ms 10.0.0.2 (10.0.0.2)  5.3 ms !H  *", or in
+// the Windows tracert form "  3    <1 ms     5 ms     *     gw [10.0.0.1]",
+// which gives the hop's address after its RTTs
+func ParseTracerouteHop(line string) (TracerouteHop, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return TracerouteHop{}, false
+	}
+
+	n, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return TracerouteHop{}, false
+	}
+
+	hop := TracerouteHop{Hop: n, Probes: []TracerouteProbe{}}
+	var address, host string
+
+	for i := 1; i < len(fields); i++ {
+		field := fields[i]
+
+		switch {
+		case field == "*":
+			hop.Probes = append(hop.Probes, TracerouteProbe{Timeout: true})
+
+		case strings.HasPrefix(field, "!"):
+			// Annotations apply to the probe they follow
+			if len(hop.Probes) > 0 {
+				last := &hop.Probes[len(hop.Probes)-1]
+				last.Annotation = field
+				last.Note = tracerouteAnnotations[field]
+			}
+
+		case strings.HasPrefix(field, "(") && strings.HasSuffix(field, ")"),
+			strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]"):
+			// "name (address)" form, or "name [address]" on Windows: the
+			// preceding field was the reverse name
+			host = address
+			address = strings.Trim(field, "()[]")
+			if host == address {
+				host = ""
+			}
+
+		case field == "Request":
+			// Windows reports "Request timed out." after the probes' "*"
+			i = len(fields)
+
+		case field == "reports:":
+			// Windows: "10.0.0.5  reports: Destination host unreachable."
+			hop.Probes = append(hop.Probes, TracerouteProbe{
+				Address: address,
+				Host:    host,
+				Note:    strings.Join(fields[i+1:], " "),
+			})
+			i = len(fields)
+
+		default:
+			if rtt, ok := parseTracerouteRTT(fields, &i); ok {
+				hop.Probes = append(hop.Probes, TracerouteProbe{
+					Address: address,
+					Host:    host,
+					RTT:     rtt,
+				})
+				continue
+			}
+			address, host = field, ""
+		}
+	}
+
+	// Windows prints the address after the RTTs it applies to
+	for j := range hop.Probes {
+		p := &hop.Probes[j]
+		if p.Address == "" && !p.Timeout {
+			p.Address, p.Host = address, host
+		}
+	}
+
+	return hop, true
+}
+
+// parseTracerouteRTT reads an RTT at fields[*i], given as either "1.23 ms"
+// or "1.23ms", advancing *i past the unit. Windows reports RTTs below its
+// timer resolution as "<1 ms", which is read as the 1 ms bound.
+func parseTracerouteRTT(fields []string, i *int) (float64, bool) {
+	field := strings.TrimPrefix(fields[*i], "<")
+	if strings.HasSuffix(field, "ms") {
+		rtt, err := strconv.ParseFloat(strings.TrimSuffix(field, "ms"), 64)
+		return rtt, err == nil
+	}
+
+	if *i+1 < len(fields) && fields[*i+1] == "ms" {
+		rtt, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return 0, false
+		}
+		*i++
+		return rtt, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,138 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTracerouteHop(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want TracerouteHop
+		ok   bool
+	}{
+		{
+			name: "linux name and address",
+			line: " 1  _gateway (192.168.1.1)  0.512 ms  0.468 ms  0.441 ms",
+			want: TracerouteHop{Hop: 1, Probes: []TracerouteProbe{
+				{Address: "192.168.1.1", Host: "_gateway", RTT: 0.512},
+				{Address: "192.168.1.1", Host: "_gateway", RTT: 0.468},
+				{Address: "192.168.1.1", Host: "_gateway", RTT: 0.441},
+			}},
+			ok: true,
+		},
+		{
+			name: "linux probes answered by different routers",
+			line: " 7  ae-1.r20.londen12.uk.bb.gin.ntt.net (129.250.2.26)  9.114 ms 129.250.4.141 (129.250.4.141)  9.380 ms  9.402 ms",
+			want: TracerouteHop{Hop: 7, Probes: []TracerouteProbe{
+				{Address: "129.250.2.26", Host: "ae-1.r20.londen12.uk.bb.gin.ntt.net", RTT: 9.114},
+				{Address: "129.250.4.141", RTT: 9.38},
+				{Address: "129.250.4.141", RTT: 9.402},
+			}},
+			ok: true,
+		},
+		{
+			name: "all probes timed out",
+			line: " 4  * * *",
+			want: TracerouteHop{Hop: 4, Probes: []TracerouteProbe{
+				{Timeout: true}, {Timeout: true}, {Timeout: true},
+			}},
+			ok: true,
+		},
+		{
+			name: "annotations and a timeout",
+			line: "12  10.0.0.2 (10.0.0.2)  5.301 ms !H  5.296 ms !X *",
+			want: TracerouteHop{Hop: 12, Probes: []TracerouteProbe{
+				{Address: "10.0.0.2", RTT: 5.301, Annotation: "!H", Note: "host unreachable"},
+				{Address: "10.0.0.2", RTT: 5.296, Annotation: "!X", Note: "communication administratively prohibited"},
+				{Timeout: true},
+			}},
+			ok: true,
+		},
+		{
+			name: "numeric output with IPv6 addresses",
+			line: " 2  2001:db8:0:1::1  1.204 ms  1.187 ms  1.150 ms",
+			want: TracerouteHop{Hop: 2, Probes: []TracerouteProbe{
+				{Address: "2001:db8:0:1::1", RTT: 1.204},
+				{Address: "2001:db8:0:1::1", RTT: 1.187},
+				{Address: "2001:db8:0:1::1", RTT: 1.15},
+			}},
+			ok: true,
+		},
+		{
+			name: "busybox unit without a space",
+			line: " 3  10.20.0.1 (10.20.0.1)  3.112ms  2.980ms  3.004ms",
+			want: TracerouteHop{Hop: 3, Probes: []TracerouteProbe{
+				{Address: "10.20.0.1", RTT: 3.112},
+				{Address: "10.20.0.1", RTT: 2.98},
+				{Address: "10.20.0.1", RTT: 3.004},
+			}},
+			ok: true,
+		},
+		{
+			name: "windows below timer resolution",
+			line: "  1    <1 ms    <1 ms    <1 ms  192.168.1.1",
+			want: TracerouteHop{Hop: 1, Probes: []TracerouteProbe{
+				{Address: "192.168.1.1", RTT: 1},
+				{Address: "192.168.1.1", RTT: 1},
+				{Address: "192.168.1.1", RTT: 1},
+			}},
+			ok: true,
+		},
+		{
+			name: "windows name and address with a lost probe",
+			line: "  5    12 ms     *       11 ms  be-1.core1.example.net [203.0.113.9]",
+			want: TracerouteHop{Hop: 5, Probes: []TracerouteProbe{
+				{Address: "203.0.113.9", Host: "be-1.core1.example.net", RTT: 12},
+				{Timeout: true},
+				{Address: "203.0.113.9", Host: "be-1.core1.example.net", RTT: 11},
+			}},
+			ok: true,
+		},
+		{
+			name: "windows request timed out",
+			line: "  6     *        *        *     Request timed out.",
+			want: TracerouteHop{Hop: 6, Probes: []TracerouteProbe{
+				{Timeout: true}, {Timeout: true}, {Timeout: true},
+			}},
+			ok: true,
+		},
+		{
+			name: "windows unreachable report",
+			line: "  8  10.0.0.5  reports: Destination host unreachable.",
+			want: TracerouteHop{Hop: 8, Probes: []TracerouteProbe{
+				{Address: "10.0.0.5", Note: "Destination host unreachable."},
+			}},
+			ok: true,
+		},
+		{
+			name: "linux header",
+			line: "traceroute to example.com (93.184.215.14), 30 hops max, 60 byte packets",
+		},
+		{
+			name: "windows header",
+			line: "Tracing route to example.com [93.184.215.14]",
+		},
+		{
+			name: "windows footer",
+			line: "Trace complete.",
+		},
+		{
+			name: "empty line",
+			line: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseTracerouteHop(tt.line)
+			if ok != tt.ok {
+				t.Fatalf("ParseTracerouteHop ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hop:\n got  %+v\n want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,24 +1,63 @@
 import { useState, useEffect, useCallback, useRef } from 'react'
 
+const PROTOCOL = 'nettools.v1'
+
+// Convert a protocol envelope into the message shape the tool pages use
+function toMessage(envelope, job) {
+  const base = {
+    jobId: envelope.jobId,
+    tool: job.tool,
+    target: job.target,
+    parameters: job.parameters
+  }
+  const payload = envelope.payload || {}
+
+  switch (envelope.type) {
+    case 'queued':
+      return { ...base, queuePosition: payload.position }
+    case 'output':
+      return { ...base, output: payload.line }
+    case 'stderr':
+      return { ...base, error: payload.line }
+    case 'event':
+      return { ...base, event: payload }
+    case 'error':
+      return { ...base, error: payload.message }
+    case 'completed':
+      return { ...base, error: payload.error, endTime: payload.endTime }
+    case 'cancelled':
+      return { ...base, error: 'command execution cancelled', endTime: payload.endTime }
+    default:
+      return null
+  }
+}
+
 export function useWebSocket(url) {
   const [connected, setConnected] = useState(false)
   const [lastMessage, setLastMessage] = useState(null)
   const wsRef = useRef(null)
   const reconnectTimeoutRef = useRef(null)
+  // Jobs still running, with the last sequence number seen for each
+  const jobsRef = useRef({})
 
   const connect = useCallback(() => {
     try {
-      const ws = new WebSocket(url)
-      
+      const ws = new WebSocket(url, [PROTOCOL])
+
       ws.onopen = () => {
         setConnected(true)
         console.log('WebSocket connected')
+
+        // Pick up output from jobs that kept running while disconnected
+        Object.entries(jobsRef.current).forEach(([jobId, job]) => {
+          ws.send(JSON.stringify({ type: 'resume', jobId, lastSeq: job.lastSeq }))
+        })
       }
 
       ws.onclose = () => {
         setConnected(false)
         console.log('WebSocket disconnected')
-        
+
         // Attempt to reconnect after 3 seconds
         reconnectTimeoutRef.current = setTimeout(() => {
           console.log('Attempting to reconnect...')
@@ -28,8 +67,31 @@ export function useWebSocket(url) {
 
       ws.onmessage = (event) => {
         try {
-          const data = JSON.parse(event.data)
-          setLastMessage(data)
+          const envelope = JSON.parse(event.data)
+          const jobs = jobsRef.current
+
+          if (!envelope.jobId) {
+            setLastMessage({ error: envelope.payload?.message })
+            return
+          }
+
+          if (envelope.type === 'started') {
+            jobs[envelope.jobId] = { ...envelope.payload, lastSeq: 0 }
+          }
+          const job = jobs[envelope.jobId]
+          if (!job || envelope.seq <= job.lastSeq) {
+            return
+          }
+          job.lastSeq = envelope.seq
+
+          if (envelope.type === 'completed' || envelope.type === 'cancelled') {
+            delete jobs[envelope.jobId]
+          }
+
+          const message = toMessage(envelope, job)
+          if (message) {
+            setLastMessage(message)
+          }
         } catch (error) {
           console.error('Failed to parse WebSocket message:', error)
         }
@@ -48,7 +110,7 @@ export function useWebSocket(url) {
 
   useEffect(() => {
     connect()
-    
+
     return () => {
       if (wsRef.current) {
         wsRef.current.close()
@@ -67,9 +129,14 @@ export function useWebSocket(url) {
     }
   }, [])
 
+  const cancelJob = useCallback((jobId) => {
+    sendMessage({ type: 'cancel', jobId })
+  }, [sendMessage])
+
   return {
     connected,
     sendMessage,
+    cancelJob,
     lastMessage
   }
-}
\ No newline at end of file
+}
@@ -29,21 +29,18 @@ export default function Dig({ onExecute, lastMessage, toolState, onStateChange }
             output: toolState.output + '\n' + line
           })
         }
-        
-        // Parse DNS records from output
-        if (line.includes('IN')) {
-          const recordMatch = line.match(/^(\S+)\s+(\d+)\s+IN\s+(\S+)\s+(.+)/)
-          if (recordMatch) {
-            const [, name, ttl, type, value] = recordMatch
-            setParsedRecords(prev => [...prev, {
-              name: name === '@' ? toolState.target : name,
-              ttl: parseInt(ttl),
-              type,
-              value: value.trim(),
-              timestamp: new Date()
-            }])
-          }
-        }
+      }
+
+      // Take records from the structured response parsed by the backend
+      if (lastMessage.event?.type === 'dns_response') {
+        const response = lastMessage.event.data
+        setParsedRecords(response.answer.map(record => ({
+          name: record.name === '@' ? toolState.target : record.name,
+          ttl: record.ttl,
+          type: record.type,
+          value: record.data,
+          timestamp: new Date()
+        })))
       }
       
       if (lastMessage.endTime) {
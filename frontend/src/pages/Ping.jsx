@@ -35,36 +35,34 @@ export default function Ping({ onExecute, lastMessage, toolState, onStateChange
             output: toolState.output + '\n' + line
           })
         }
-        
-        // Parse ping statistics
-        if (line.includes('bytes from')) {
-          const timeMatch = line.match(/time=([\d.]+)\s*ms/)
-          if (timeMatch) {
-            const time = parseFloat(timeMatch[1])
-            setStats(prev => {
-              const newTimes = [...prev.times, time]
-              const newReceived = prev.received + 1
-              return {
-                sent: prev.sent + 1,
-                received: newReceived,
-                times: newTimes,
-                avgTime: newTimes.reduce((a, b) => a + b, 0) / newReceived,
-                min: Math.min(...newTimes),
-                max: Math.max(...newTimes),
-                lastUpdate: new Date()
-              }
-            })
-          } else {
-            setStats(prev => ({
-              ...prev,
-              sent: prev.sent + 1,
-              lastUpdate: new Date()
-            }))
+      }
+
+      // Update statistics from structured events parsed by the backend
+      const event = lastMessage.event
+      if (event?.type === 'ping_reply') {
+        const time = event.data.rtt
+        setStats(prev => {
+          const newTimes = [...prev.times, time]
+          const newReceived = prev.received + 1
+          return {
+            sent: prev.sent + 1,
+            received: newReceived,
+            times: newTimes,
+            avgTime: newTimes.reduce((a, b) => a + b, 0) / newReceived,
+            min: Math.min(...newTimes),
+            max: Math.max(...newTimes),
+            lastUpdate: new Date()
           }
-        } else if (line.match(/\d+\s+packets transmitted/)) {
-          // Final statistics line
-          setIsRunning(false)
-        }
+        })
+      } else if (event?.type === 'ping_timeout' || event?.type === 'ping_error') {
+        setStats(prev => ({
+          ...prev,
+          sent: prev.sent + 1,
+          lastUpdate: new Date()
+        }))
+      } else if (event?.type === 'ping_summary') {
+        // Final statistics
+        setIsRunning(false)
       }
       
       if (lastMessage.endTime) {
@@ -29,32 +29,32 @@ export default function Traceroute({ onExecute, lastMessage, toolState, onStateC
             output: toolState.output + '\n' + line
           })
         }
-        
-        // Parse hop information
-        const hopMatch = line.match(/^\s*(\d+)\s+(?:(\S+)\s+\(([\d.]+)\)|(\*))(?:\s+([\d.]+)\s+ms)?/)
-        if (hopMatch) {
-          const [, hopNum, hostname, ip, timeoutMark, latency] = hopMatch
-          const hopNumber = parseInt(hopNum)
-          setHopCount(hopNumber)
-          
-          const newHop = {
-            number: hopNumber,
-            hostname: hostname || (timeoutMark ? null : ''),
-            ip: ip || null,
-            latency: latency ? parseFloat(latency) : null,
-            timeout: !!timeoutMark,
-            timestamp: Date.now()
-          }
+      }
 
-          setHops(prev => {
-            // Update existing hop or add new one
-            const existing = prev.find(h => h.number === hopNumber)
-            if (existing) {
-              return prev.map(h => h.number === hopNumber ? newHop : h)
-            }
-            return [...prev, newHop]
-          })
+      // Build hop information from structured events parsed by the backend
+      if (lastMessage.event?.type === 'traceroute_hop') {
+        const { hop: hopNumber, probes } = lastMessage.event.data
+        const answered = probes.find(probe => !probe.timeout)
+        setHopCount(hopNumber)
+
+        const newHop = {
+          number: hopNumber,
+          hostname: answered ? (answered.host || '') : null,
+          ip: answered ? answered.address : null,
+          latency: answered ? answered.rtt : null,
+          timeout: !answered,
+          probes,
+          timestamp: Date.now()
         }
+
+        setHops(prev => {
+          // Update existing hop or add new one
+          const existing = prev.find(h => h.number === hopNumber)
+          if (existing) {
+            return prev.map(h => h.number === hopNumber ? newHop : h)
+          }
+          return [...prev, newHop]
+        })
       }
       
       if (lastMessage.endTime) {